@@ -0,0 +1,197 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Activation modes: how the active version of a provider is put at its original path
+const (
+	activationCopy    = "copy"    // the version is copied to the original path
+	activationSymlink = "symlink" // the original path links to a working copy of the version
+)
+
+// activation returns the provider's activation mode
+func (p Provider) activation() string {
+	if p.Activation == "" {
+		return activationCopy
+	}
+	return p.Activation
+}
+
+// getActiveDir returns the directory holding the working copies that the
+// original paths of symlink providers link to
+func getActiveDir() (string, error) {
+	configDir, err := getConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "active"), nil
+}
+
+// newWorkingCopy materializes a version in a new directory below the active
+// directory and returns the path the original path should link to
+func newWorkingCopy(provider Provider, versionName string) (string, error) {
+	activeDir, err := getActiveDir()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(activeDir, privateDirMode); err != nil {
+		return "", fmt.Errorf("failed to create active directory: %w", err)
+	}
+	dir, err := os.MkdirTemp(activeDir, provider.Name+"-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create working copy: %w", err)
+	}
+
+	workingCopy := filepath.Join(dir, filepath.Base(provider.OriginalPath))
+	if err := materializeVersion(provider.Name, versionName, workingCopy); err != nil {
+		removeAll(dir)
+		return "", err
+	}
+	return workingCopy, nil
+}
+
+// linkedWorkingCopy returns the working copy that path links to, or "" if path
+// is not a symlink into the active directory
+func linkedWorkingCopy(path string) string {
+	target, err := os.Readlink(path)
+	if err != nil || !filepath.IsAbs(target) {
+		return ""
+	}
+	activeDir, err := getActiveDir()
+	if err != nil {
+		return ""
+	}
+	rel, err := filepath.Rel(activeDir, target)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return ""
+	}
+	return target
+}
+
+// removeWorkingCopy deletes the working copy that path links to, if any
+func removeWorkingCopy(path string) error {
+	if workingCopy := linkedWorkingCopy(path); workingCopy != "" {
+		return removeAll(filepath.Dir(workingCopy))
+	}
+	return nil
+}
+
+// discardStaged removes content staged for a switch, along with the working
+// copy a staged link points to
+func discardStaged(path string) error {
+	if err := removeWorkingCopy(path); err != nil {
+		return err
+	}
+	return removeAll(path)
+}
+
+// isLinked reports whether the original path of a symlink provider links to a
+// working copy, rather than having been replaced by a tool
+func (p Provider) isLinked() bool {
+	return p.activation() == activationSymlink && linkedWorkingCopy(p.OriginalPath) != ""
+}
+
+// syncLinkedVersion saves writes made through the link of a symlink provider
+// into its active version, so they are kept when the provider is switched. It
+// reports whether anything was saved. The caller holds the lock.
+func syncLinkedVersion(config *ProvidersConfig, provider *Provider) (bool, error) {
+	if !provider.isLinked() || provider.CurrentVersion == "" {
+		return false, nil
+	}
+
+	stored, err := versionTree(provider.Name, provider.CurrentVersion)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	live, err := hashTree(provider.OriginalPath, provider.Type, provider.symlinkPolicy())
+	if err != nil {
+		return false, err
+	}
+	if compareManifests(stored, live).Equal {
+		return false, nil
+	}
+
+	if _, err := captureVersion(config, provider, provider.CurrentVersion, ""); err != nil {
+		return false, fmt.Errorf("failed to save changes to version '%s' of '%s': %w", provider.CurrentVersion, provider.Name, err)
+	}
+	config.Providers[provider.Name] = *provider
+	if err := config.saveProviders(); err != nil {
+		return false, fmt.Errorf("failed to save providers config: %w", err)
+	}
+	recordHistory(historyEvent{Action: historyAddVersion, Provider: provider.Name, Version: provider.CurrentVersion})
+	return true, nil
+}
+
+// stageVersion writes what swapInVersion moves to the original path: a copy of
+// the version, or for symlink providers a link to a new working copy of it
+func stageVersion(provider Provider, versionName, stagingPath string) error {
+	if provider.activation() != activationSymlink {
+		return materializeVersion(provider.Name, versionName, stagingPath)
+	}
+
+	workingCopy, err := newWorkingCopy(provider, versionName)
+	if err != nil {
+		return err
+	}
+	if err := os.Symlink(workingCopy, stagingPath); err != nil {
+		removeAll(filepath.Dir(workingCopy))
+		return pathError("symlink", stagingPath, err)
+	}
+	return nil
+}
+
+// unlinkWorkingCopy replaces the link at the original path of a symlink
+// provider with a copy of its working copy. The journal is left for the caller
+// to finish or roll back, as with swapInVersion.
+func unlinkWorkingCopy(provider Provider) (*switchJournal, error) {
+	workingCopy := linkedWorkingCopy(provider.OriginalPath)
+	if workingCopy == "" {
+		return nil, fmt.Errorf("'%s' is not linked to a working copy", provider.OriginalPath)
+	}
+	return swapIn(provider, provider.CurrentVersion, "", func(stagingPath string) error {
+		return copyPath(workingCopy, stagingPath, provider.Type, provider.symlinkPolicy())
+	})
+}
+
+// changeActivation switches a provider between copy and symlink activation,
+// replacing its original path accordingly, and saves the new mode. The caller
+// holds the lock and has made sure the active version holds the live content.
+func changeActivation(config *ProvidersConfig, provider Provider, mode string) error {
+	var journal *switchJournal
+	var err error
+	switch {
+	case mode == activationSymlink:
+		provider.Activation = activationSymlink
+		journal, err = swapInVersion(provider, provider.CurrentVersion, "")
+	case provider.isLinked():
+		journal, err = unlinkWorkingCopy(provider)
+		provider.Activation = ""
+	default:
+		// The link was already replaced by a copy
+		provider.Activation = ""
+	}
+	if err != nil {
+		return err
+	}
+
+	config.Providers[provider.Name] = provider
+	if err := config.saveProviders(); err != nil {
+		if journal != nil {
+			rollbackSwitch(journal)
+		}
+		return fmt.Errorf("failed to save providers config: %w", err)
+	}
+	if journal != nil {
+		return finishSwitch(journal)
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// setupActivationTest converts the switch test provider to symlink activation
+func setupActivationTest(t *testing.T) Provider {
+	t.Helper()
+	_, _, provider := setupSwitchTest(t)
+	if err := runActivation(activationCmd, []string{provider.Name, activationSymlink}); err != nil {
+		t.Fatalf("activation failed: %v", err)
+	}
+	config, _ := loadProviders()
+	return config.Providers[provider.Name]
+}
+
+// activeEntries returns the working copy directories in the active directory
+func activeEntries(t *testing.T) []os.DirEntry {
+	t.Helper()
+	activeDir, _ := getActiveDir()
+	entries, err := os.ReadDir(activeDir)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatalf("Failed to read active directory: %v", err)
+	}
+	return entries
+}
+
+func TestActivationSymlink(t *testing.T) {
+	provider := setupActivationTest(t)
+
+	workingCopy := linkedWorkingCopy(provider.OriginalPath)
+	if workingCopy == "" {
+		t.Fatalf("Expected '%s' to link to a working copy", provider.OriginalPath)
+	}
+	assertFileContent(t, workingCopy, "work")
+
+	// A tool writing through the link changes the working copy
+	if err := os.WriteFile(provider.OriginalPath, []byte("refreshed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	status, err := getProviderStatus(provider)
+	if err != nil {
+		t.Fatalf("getProviderStatus failed: %v", err)
+	}
+	if status.State != stateUnsynced {
+		t.Errorf("Expected state %s, got %s", stateUnsynced, status.State)
+	}
+
+	// Switching saves the change into the version it was made in
+	if err := runSetVersion(setVersionCmd, []string{provider.Name, "personal"}); err != nil {
+		t.Fatalf("set-version failed: %v", err)
+	}
+	assertVersionContent(t, provider.Name, "work", "refreshed")
+	assertLive(t, map[string]string{provider.Name: "personal"})
+
+	if _, err := os.Stat(workingCopy); !os.IsNotExist(err) {
+		t.Errorf("Expected the old working copy to be removed, got %v", err)
+	}
+	if entries := activeEntries(t); len(entries) != 1 {
+		t.Errorf("Expected one working copy, got %d", len(entries))
+	}
+}
+
+func TestActivationUnlinked(t *testing.T) {
+	provider := setupActivationTest(t)
+
+	// A tool replacing the file replaces the link as well
+	if err := os.Remove(provider.OriginalPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(provider.OriginalPath, []byte("work"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	status, err := getProviderStatus(provider)
+	if err != nil {
+		t.Fatalf("getProviderStatus failed: %v", err)
+	}
+	if status.State != stateUnlinked {
+		t.Errorf("Expected state %s, got %s", stateUnlinked, status.State)
+	}
+
+	config, _ := loadProviders()
+	findings, err := checkStore(config)
+	if err != nil {
+		t.Fatalf("checkStore failed: %v", err)
+	}
+	if len(findings) != 1 || findings[0].fix == nil {
+		t.Fatalf("Expected one fixable stray working copy, got %v", findings)
+	}
+
+	// Writes the tool made to the copy before replacing the link are kept
+	activeDir, _ := getActiveDir()
+	entries := activeEntries(t)
+	if len(entries) != 1 {
+		t.Fatalf("Expected one working copy, got %d", len(entries))
+	}
+	stray := filepath.Join(activeDir, entries[0].Name(), filepath.Base(provider.OriginalPath))
+	if err := os.WriteFile(stray, []byte("unsaved"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	findings, err = checkStore(config)
+	if err != nil {
+		t.Fatalf("checkStore failed: %v", err)
+	}
+	if len(findings) != 1 || findings[0].fix != nil {
+		t.Fatalf("Expected one unfixable stray working copy, got %v", findings)
+	}
+
+	// Linking again replaces the file with a link
+	if err := runActivation(activationCmd, []string{provider.Name, activationSymlink}); err != nil {
+		t.Fatalf("activation failed: %v", err)
+	}
+	if !provider.isLinked() {
+		t.Errorf("Expected '%s' to be linked again", provider.OriginalPath)
+	}
+}
+
+func TestActivationCopy(t *testing.T) {
+	provider := setupActivationTest(t)
+	if err := os.WriteFile(provider.OriginalPath, []byte("refreshed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runActivation(activationCmd, []string{provider.Name, activationCopy}); err != nil {
+		t.Fatalf("activation failed: %v", err)
+	}
+
+	info, err := os.Lstat(provider.OriginalPath)
+	if err != nil || info.Mode()&os.ModeSymlink != 0 {
+		t.Fatalf("Expected a regular file at '%s' (%v)", provider.OriginalPath, err)
+	}
+	assertFileContent(t, provider.OriginalPath, "refreshed")
+	assertVersionContent(t, provider.Name, "work", "refreshed")
+	if entries := activeEntries(t); len(entries) != 0 {
+		t.Errorf("Expected the working copy to be removed, found %d", len(entries))
+	}
+
+	config, _ := loadProviders()
+	if mode := config.Providers[provider.Name].Activation; mode != "" {
+		t.Errorf("Expected copy activation, got %q", mode)
+	}
+}
+
+func TestActivationRequiresSavedState(t *testing.T) {
+	tempDir, _, provider := setupSwitchTest(t)
+	if err := os.WriteFile(provider.OriginalPath, []byte("unsaved"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runActivation(activationCmd, []string{provider.Name, activationSymlink}); err == nil {
+		t.Fatal("Expected unsaved live content to be refused")
+	}
+	assertFileContent(t, filepath.Join(tempDir, "config.yaml"), "unsaved")
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// autoSnapshotPrefix starts the name of every auto-snapshot
+const autoSnapshotPrefix = "auto-"
+
+// autoSnapshotName returns an unused version name for an auto-snapshot taken at t
+func autoSnapshotName(provider Provider, t time.Time) (string, error) {
+	base := autoSnapshotPrefix + t.UTC().Format("2006-01-02T15-04-05")
+	name := base
+	for i := 2; ; i++ {
+		versionPath, err := getVersionPath(provider.Name, name)
+		if err != nil {
+			return "", err
+		}
+		if _, err := os.Lstat(versionPath); os.IsNotExist(err) {
+			return name, nil
+		}
+		name = fmt.Sprintf("%s-%d", base, i)
+	}
+}
+
+// takeAutoSnapshot saves the live state of a provider as a new auto-snapshot
+// and returns its name
+func takeAutoSnapshot(config *ProvidersConfig, provider *Provider, note string) (string, error) {
+	name, err := autoSnapshotName(*provider, time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := captureVersion(config, provider, name, note); err != nil {
+		return "", fmt.Errorf("failed to save auto-snapshot: %w", err)
+	}
+	meta := provider.Versions[name]
+	meta.Auto = true
+	provider.Versions[name] = meta
+
+	config.Providers[provider.Name] = *provider
+	if err := config.saveProviders(); err != nil {
+		return "", fmt.Errorf("failed to save providers config: %w", err)
+	}
+	recordHistory(historyEvent{Action: historyAddVersion, Provider: provider.Name, Version: name})
+	return name, nil
+}
+
+// autoSnapshots returns the auto-snapshots of a provider, oldest first
+func autoSnapshots(provider Provider) []string {
+	var names []string
+	for name, meta := range provider.Versions {
+		if meta.Auto {
+			names = append(names, name)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := provider.Versions[names[i]], provider.Versions[names[j]]
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// pruneAutoSnapshots deletes the oldest auto-snapshots of a provider beyond
+// its keep-auto limit and returns their names. The active and pinned versions,
+// and versions used by profiles, are never deleted.
+func pruneAutoSnapshots(config *ProvidersConfig, providerName string) ([]string, error) {
+	provider := config.retentionView(providerName)
+	return removeVersions(config, providerName, excessAutoSnapshots(provider, provider.keepAuto(config.settings())))
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSetVersionAutosave(t *testing.T) {
+	_, config, provider := setupSwitchTest(t)
+
+	config.Settings = &Settings{Autosave: true, AutoSnapshotLimit: 1}
+	if err := config.saveProviders(); err != nil {
+		t.Fatalf("saveProviders failed: %v", err)
+	}
+
+	// Two unbacked edits, each saved before switching away from it
+	for _, content := range []string{"edit-1", "edit-2"} {
+		if err := os.WriteFile(provider.OriginalPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := runSetVersion(setVersionCmd, []string{provider.Name, "personal"}); err != nil {
+			t.Fatalf("runSetVersion failed: %v", err)
+		}
+		assertFileContent(t, provider.OriginalPath, "personal")
+	}
+
+	config, err := loadProviders()
+	if err != nil {
+		t.Fatalf("loadProviders failed: %v", err)
+	}
+	snapshots := autoSnapshots(config.Providers[provider.Name])
+	if len(snapshots) != 1 || !strings.HasPrefix(snapshots[0], autoSnapshotPrefix) {
+		t.Fatalf("Auto-snapshots = %v, want one kept by the limit", snapshots)
+	}
+
+	// The newest snapshot is kept and is where "-" goes back to
+	assertVersionContent(t, provider.Name, snapshots[0], "edit-2")
+	if err := runSetVersion(setVersionCmd, []string{provider.Name, "-"}); err != nil {
+		t.Fatalf("runSetVersion with '-' failed: %v", err)
+	}
+	assertFileContent(t, provider.OriginalPath, "edit-2")
+}
+
+func TestSettingsRoundTrip(t *testing.T) {
+	var settings Settings
+	if err := settings.UnmarshalJSON([]byte(`{"autosave":true,"future_option":[1,2]}`)); err != nil {
+		t.Fatalf("UnmarshalJSON failed: %v", err)
+	}
+	if !settings.Autosave || settings.autoSnapshotLimit() != defaultAutoSnapshotLimit {
+		t.Errorf("Unexpected settings: %+v", settings)
+	}
+
+	data, err := settings.MarshalJSON()
+	if err != nil {
+		t.Fatalf("MarshalJSON failed: %v", err)
+	}
+	if want := `{"autosave":true,"future_option":[1,2]}`; string(data) != want {
+		t.Errorf("MarshalJSON = %s, want %s", data, want)
+	}
+
+	key, err := findSettingKey(settingKeys, "auto-snapshot-limit")
+	if err != nil {
+		t.Fatalf("findSettingKey failed: %v", err)
+	}
+	if err := key.set(&ProvidersConfig{}, nil, "0"); err == nil {
+		t.Error("Expected error for a limit below 1")
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/spf13/cobra"
+)
+
+var activationCmd = &cobra.Command{
+	Use:   "activation <provider_name> [copy|symlink]",
+	Short: "Show or change how the active version is put at the original path",
+	Long: `Show or change how the active version of a provider is put at its original path:
+
+  copy     the version is copied to the original path (the default); changes a
+           tool makes there are only kept by saving them with add-version
+  symlink  the original path is a symbolic link to a working copy of the version
+           in ~/.llmctx/active, so changes a tool makes land in the active
+           version; they are saved into it on the next switch
+
+Changing to symlink requires the live configuration to match the active version.
+Changing back to copy saves the changes made through the link into the active
+version and replaces the link with a copy. Running "activation <name> symlink"
+again links a provider whose link was replaced.
+
+Tools that replace their configuration file rather than writing to it replace
+the link as well, so symlink activation suits configuration directories best.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: runActivation,
+}
+
+func init() {
+	rootCmd.AddCommand(activationCmd)
+}
+
+// activationResult is the structured output of activation without a new mode
+type activationResult struct {
+	Provider   string `json:"provider"`
+	Activation string `json:"activation"`
+	LinkTarget string `json:"link_target,omitempty"`
+}
+
+func runActivation(cmd *cobra.Command, args []string) error {
+	providerName := args[0]
+	if len(args) == 1 {
+		return showActivation(providerName)
+	}
+
+	mode := args[1]
+	if mode != activationCopy && mode != activationSymlink {
+		return fmt.Errorf("invalid activation '%s': must be \"copy\" or \"symlink\"", mode)
+	}
+
+	unlock, err := lockProviders()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// Load providers config
+	config, err := loadProviders()
+	if err != nil {
+		return fmt.Errorf("failed to load providers: %w", err)
+	}
+
+	provider, exists := config.Providers[providerName]
+	if !exists {
+		return fmt.Errorf("provider '%s' not found", providerName)
+	}
+	if err := checkProviderIdle(providerName); err != nil {
+		return err
+	}
+
+	done := func(message string) error {
+		return renderAction(actionResult{
+			Action:   "activation",
+			Provider: providerName,
+			Version:  provider.CurrentVersion,
+			Message:  message,
+		})
+	}
+
+	if mode == activationCopy {
+		if provider.activation() == activationCopy {
+			return done(fmt.Sprintf("'%s' already uses copy activation", providerName))
+		}
+		if _, err := syncLinkedVersion(config, &provider); err != nil {
+			return err
+		}
+		if err := changeActivation(config, provider, activationCopy); err != nil {
+			return err
+		}
+		return done(fmt.Sprintf("Successfully changed '%s' to copy activation", providerName))
+	}
+
+	if provider.isLinked() {
+		return done(fmt.Sprintf("'%s' already uses symlink activation", providerName))
+	}
+	if provider.CurrentVersion == "" {
+		return fmt.Errorf("'%s' has no active version to link to; switch to a version first", providerName)
+	}
+	if _, err := os.Stat(provider.OriginalPath); os.IsNotExist(err) {
+		return fmt.Errorf("original path '%s' no longer exists", provider.OriginalPath)
+	}
+
+	// The live content is replaced by the link, so the active version must hold it
+	matches, err := findMatchingVersions(provider)
+	if err != nil {
+		return fmt.Errorf("failed to check current state: %w", err)
+	}
+	if !slices.Contains(matches, provider.CurrentVersion) {
+		return fmt.Errorf("current state of '%s' does not match its active version '%s'. Use 'llmctx add-version %s %s' to save it into the version first", provider.OriginalPath, provider.CurrentVersion, providerName, provider.CurrentVersion)
+	}
+
+	if err := changeActivation(config, provider, activationSymlink); err != nil {
+		return err
+	}
+	return done(fmt.Sprintf("Successfully changed '%s' to symlink activation", providerName))
+}
+
+// showActivation prints the activation mode of a provider
+func showActivation(providerName string) error {
+	config, err := loadProviders()
+	if err != nil {
+		return fmt.Errorf("failed to load providers: %w", err)
+	}
+	provider, exists := config.Providers[providerName]
+	if !exists {
+		return fmt.Errorf("provider '%s' not found", providerName)
+	}
+
+	result := activationResult{
+		Provider:   providerName,
+		Activation: provider.activation(),
+		LinkTarget: linkedWorkingCopy(provider.OriginalPath),
+	}
+	return render(result, func() {
+		switch {
+		case result.Activation == activationCopy:
+			fmt.Println(result.Activation)
+		case result.LinkTarget != "":
+			fmt.Printf("%s (%s -> %s)\n", result.Activation, provider.OriginalPath, result.LinkTarget)
+		default:
+			fmt.Printf("%s (not linked; run 'llmctx activation %s symlink' to link it again)\n", result.Activation, providerName)
+		}
+	})
+}
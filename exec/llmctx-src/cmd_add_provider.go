@@ -4,8 +4,6 @@ import (
 	"bufio"
 	"fmt"
 	"os"
-	"os/exec"
-	"path/filepath"
 	"strings"
 
 	"github.com/spf13/cobra"
@@ -14,26 +12,62 @@ import (
 var addProviderCmd = &cobra.Command{
 	Use:   "add-provider",
 	Short: "Register a new configuration file or directory to be managed",
-	Long:  `Register a new configuration file or directory to be managed by llmctx.`,
-	RunE:  runAddProvider,
+	Long: `Register a new configuration file or directory to be managed by llmctx.
+
+Values not supplied through flags are prompted for when stdin is a terminal.
+When stdin is not a terminal every value must be supplied through flags.
+
+Exit codes:
+  3  a provider with the given name already exists
+  4  the path to manage does not exist`,
+	Args: cobra.NoArgs,
+	RunE: runAddProvider,
 }
 
+var (
+	addProviderName           string
+	addProviderPath           string
+	addProviderInitialVersion string
+	addProviderType           string
+	addProviderSymlinks       string
+	addProviderEnvVar         string
+)
+
 func init() {
+	addProviderCmd.Flags().StringVar(&addProviderName, "name", "", "Name for the provider")
+	addProviderCmd.Flags().StringVar(&addProviderPath, "path", "", "Path to the configuration file or directory to manage")
+	addProviderCmd.Flags().StringVar(&addProviderInitialVersion, "initial-version", "", "Name for the initial version")
+	addProviderCmd.Flags().StringVar(&addProviderType, "type", "", "Expected type of the path (\"file\" or \"directory\"); detected when omitted")
+	addProviderCmd.Flags().StringVar(&addProviderSymlinks, "symlinks", "copy", "How to copy symlinks inside a managed directory: \"copy\", \"follow\" or \"reject\"")
+	addProviderCmd.Flags().StringVar(&addProviderEnvVar, "env-var", "", "Environment variable the tool reads its configuration path from, e.g. CLAUDE_CONFIG_DIR (used by env and shell)")
 	rootCmd.AddCommand(addProviderCmd)
 }
 
 func runAddProvider(cmd *cobra.Command, args []string) error {
-	reader := bufio.NewReader(os.Stdin)
+	prompt := newPrompter(os.Stdin)
+
+	if addProviderType != "" && addProviderType != "file" && addProviderType != "directory" {
+		return fmt.Errorf("invalid type '%s': must be \"file\" or \"directory\"", addProviderType)
+	}
+
+	symlinkPolicy, err := parseSymlinkPolicy(addProviderSymlinks)
+	if err != nil {
+		return err
+	}
+
+	if addProviderEnvVar != "" {
+		if err := validateEnvVarName(addProviderEnvVar); err != nil {
+			return err
+		}
+	}
 
 	// Get provider name
-	fmt.Print("Enter a name for the provider: ")
-	providerName, err := reader.ReadString('\n')
+	providerName, err := prompt.value(addProviderName, "name", "Enter a name for the provider: ")
 	if err != nil {
 		return fmt.Errorf("failed to read provider name: %w", err)
 	}
-	providerName = strings.TrimSpace(providerName)
-	if providerName == "" {
-		return fmt.Errorf("provider name cannot be empty")
+	if err := validateProviderName(providerName); err != nil {
+		return err
 	}
 
 	// Check if provider already exists
@@ -43,16 +77,14 @@ func runAddProvider(cmd *cobra.Command, args []string) error {
 	}
 
 	if _, exists := config.Providers[providerName]; exists {
-		return fmt.Errorf("provider '%s' already exists", providerName)
+		return withExitCode(exitProviderExists, fmt.Errorf("provider '%s' already exists", providerName))
 	}
 
 	// Get original path
-	fmt.Print("Enter the absolute path to the configuration file or directory to manage: ")
-	originalPath, err := reader.ReadString('\n')
+	originalPath, err := prompt.value(addProviderPath, "path", "Enter the absolute path to the configuration file or directory to manage: ")
 	if err != nil {
 		return fmt.Errorf("failed to read original path: %w", err)
 	}
-	originalPath = strings.TrimSpace(originalPath)
 	if originalPath == "" {
 		return fmt.Errorf("original path cannot be empty")
 	}
@@ -63,13 +95,11 @@ func runAddProvider(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to expand path: %w", err)
 	}
 
-	// Check if path exists
-	if _, err := os.Stat(expandedPath); os.IsNotExist(err) {
-		return fmt.Errorf("Path '%s' does not exist. First create the file and then import it!", expandedPath)
-	}
-
-	// Determine type (file or directory)
+	// Check if path exists and determine its type (file or directory)
 	fileInfo, err := os.Stat(expandedPath)
+	if os.IsNotExist(err) {
+		return withExitCode(exitPathMissing, fmt.Errorf("Path '%s' does not exist. First create the file and then import it!", expandedPath))
+	}
 	if err != nil {
 		return fmt.Errorf("failed to stat path: %w", err)
 	}
@@ -79,31 +109,33 @@ func runAddProvider(cmd *cobra.Command, args []string) error {
 		pathType = "directory"
 	}
 
+	if addProviderType != "" && addProviderType != pathType {
+		return fmt.Errorf("path '%s' is a %s, not a %s", expandedPath, pathType, addProviderType)
+	}
+
 	// Get initial version name
-	fmt.Print("Enter a name for the initial version: ")
-	initialVersion, err := reader.ReadString('\n')
+	initialVersion, err := prompt.value(addProviderInitialVersion, "initial-version", "Enter a name for the initial version: ")
 	if err != nil {
 		return fmt.Errorf("failed to read initial version: %w", err)
 	}
-	initialVersion = strings.TrimSpace(initialVersion)
-	if initialVersion == "" {
-		return fmt.Errorf("initial version name cannot be empty")
+	if err := validateVersionName(initialVersion); err != nil {
+		return err
 	}
 
-	// Create version directory
-	versionPath, err := getVersionPath(providerName, initialVersion)
+	// Reload under the lock, as another process may have added the provider
+	// while we were prompting
+	unlock, err := lockProviders()
 	if err != nil {
-		return fmt.Errorf("failed to get version path: %w", err)
+		return err
 	}
+	defer unlock()
 
-	versionDir := filepath.Dir(versionPath)
-	if err := os.MkdirAll(versionDir, 0755); err != nil {
-		return fmt.Errorf("failed to create version directory: %w", err)
+	config, err = loadProviders()
+	if err != nil {
+		return fmt.Errorf("failed to load providers: %w", err)
 	}
-
-	// Copy the original file/directory to version storage
-	if err := copyPath(expandedPath, versionPath, pathType); err != nil {
-		return fmt.Errorf("failed to copy original path to version storage: %w", err)
+	if _, exists := config.Providers[providerName]; exists {
+		return withExitCode(exitProviderExists, fmt.Errorf("provider '%s' already exists", providerName))
 	}
 
 	// Add provider to config
@@ -112,6 +144,27 @@ func runAddProvider(cmd *cobra.Command, args []string) error {
 		OriginalPath:   expandedPath,
 		Type:           pathType,
 		CurrentVersion: initialVersion,
+		EnvVar:         addProviderEnvVar,
+	}
+	if symlinkPolicy != SymlinkCopy {
+		provider.Symlinks = string(symlinkPolicy)
+	}
+
+	// Storage left behind by a removed provider would otherwise be overwritten
+	versionPath, err := getVersionPath(providerName, initialVersion)
+	if err != nil {
+		return fmt.Errorf("failed to get version path: %w", err)
+	}
+	if _, err := os.Lstat(versionPath); err == nil {
+		return fmt.Errorf("version '%s' of '%s' is already stored at '%s'", initialVersion, providerName, versionPath)
+	}
+
+	// Save the original file/directory as the initial version
+	if err := saveVersion(provider, initialVersion); err != nil {
+		return err
+	}
+	if err := provider.recordVersion(initialVersion, ""); err != nil {
+		return err
 	}
 
 	config.Providers[providerName] = provider
@@ -120,18 +173,57 @@ func runAddProvider(cmd *cobra.Command, args []string) error {
 	if err := config.saveProviders(); err != nil {
 		return fmt.Errorf("failed to save providers config: %w", err)
 	}
+	recordHistory(historyEvent{Action: historyAddProvider, Provider: providerName, Version: initialVersion})
+
+	return renderAction(actionResult{
+		Action:   "add-provider",
+		Provider: providerName,
+		Version:  initialVersion,
+		Message:  fmt.Sprintf("Successfully added provider '%s' with initial version '%s'", providerName, initialVersion),
+	})
+}
 
-	fmt.Printf("Successfully added provider '%s' with initial version '%s'\n", providerName, initialVersion)
-	return nil
+// prompter reads missing values interactively, but only when stdin is a terminal
+type prompter struct {
+	reader      *bufio.Reader
+	interactive bool
 }
 
-// copyPath copies a file or directory from src to dst
-func copyPath(src, dst, pathType string) error {
-	if pathType == "directory" {
-		cmd := exec.Command("cp", "-r", src, dst)
-		return cmd.Run()
+// newPrompter creates a prompter reading from the given file
+func newPrompter(in *os.File) *prompter {
+	return &prompter{
+		reader:      bufio.NewReader(in),
+		interactive: isTerminal(in),
+	}
+}
+
+// value returns the flag value if set, otherwise prompts for it on a terminal
+func (p *prompter) value(flagValue, flagName, message string) (string, error) {
+	if flagValue != "" {
+		return strings.TrimSpace(flagValue), nil
+	}
+	if !p.interactive {
+		return "", fmt.Errorf("--%s is required when stdin is not a terminal", flagName)
+	}
+
+	// Keep stdout clean for JSON or YAML output
+	if structuredOutput() {
+		fmt.Fprint(os.Stderr, message)
 	} else {
-		cmd := exec.Command("cp", src, dst)
-		return cmd.Run()
+		fmt.Print(message)
+	}
+	input, err := p.reader.ReadString('\n')
+	if err != nil {
+		return "", err
 	}
-}
\ No newline at end of file
+	return strings.TrimSpace(input), nil
+}
+
+// isTerminal reports whether the file is attached to a terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
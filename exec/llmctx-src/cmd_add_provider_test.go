@@ -6,71 +6,6 @@ import (
 	"testing"
 )
 
-func TestCopyPath(t *testing.T) {
-	// Create a temporary directory for testing
-	tempDir, err := os.MkdirTemp("", "llmctx-test")
-	if err != nil {
-		t.Fatalf("Failed to create temp dir: %v", err)
-	}
-	defer os.RemoveAll(tempDir)
-
-	t.Run("copy file", func(t *testing.T) {
-		// Create a test file
-		srcFile := filepath.Join(tempDir, "test.txt")
-		content := "test content"
-		if err := os.WriteFile(srcFile, []byte(content), 0644); err != nil {
-			t.Fatalf("Failed to create test file: %v", err)
-		}
-
-		// Copy the file
-		dstFile := filepath.Join(tempDir, "test_copy.txt")
-		if err := copyPath(srcFile, dstFile, "file"); err != nil {
-			t.Fatalf("copyPath failed: %v", err)
-		}
-
-		// Verify the copy
-		copiedContent, err := os.ReadFile(dstFile)
-		if err != nil {
-			t.Fatalf("Failed to read copied file: %v", err)
-		}
-
-		if string(copiedContent) != content {
-			t.Errorf("Content mismatch: got %q, want %q", string(copiedContent), content)
-		}
-	})
-
-	t.Run("copy directory", func(t *testing.T) {
-		// Create a test directory with a file
-		srcDir := filepath.Join(tempDir, "testdir")
-		if err := os.MkdirAll(srcDir, 0755); err != nil {
-			t.Fatalf("Failed to create test directory: %v", err)
-		}
-
-		testFile := filepath.Join(srcDir, "file.txt")
-		content := "directory test content"
-		if err := os.WriteFile(testFile, []byte(content), 0644); err != nil {
-			t.Fatalf("Failed to create test file in directory: %v", err)
-		}
-
-		// Copy the directory
-		dstDir := filepath.Join(tempDir, "testdir_copy")
-		if err := copyPath(srcDir, dstDir, "directory"); err != nil {
-			t.Fatalf("copyPath failed: %v", err)
-		}
-
-		// Verify the copy
-		copiedFile := filepath.Join(dstDir, "file.txt")
-		copiedContent, err := os.ReadFile(copiedFile)
-		if err != nil {
-			t.Fatalf("Failed to read copied file: %v", err)
-		}
-
-		if string(copiedContent) != content {
-			t.Errorf("Content mismatch: got %q, want %q", string(copiedContent), content)
-		}
-	})
-}
-
 func TestAddProviderValidation(t *testing.T) {
 	// Create a temporary directory for testing
 	tempDir, err := os.MkdirTemp("", "llmctx-test")
@@ -112,4 +47,81 @@ func TestAddProviderValidation(t *testing.T) {
 	if !dirInfo.IsDir() {
 		t.Error("Test directory should be detected as directory")
 	}
-}
\ No newline at end of file
+}
+
+func TestAddProviderNonInteractive(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "llmctx-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Override home directory for testing
+	originalHome := os.Getenv("HOME")
+	os.Setenv("HOME", tempDir)
+	defer os.Setenv("HOME", originalHome)
+
+	testFile := filepath.Join(tempDir, "config.yaml")
+	if err := os.WriteFile(testFile, []byte("api_key: one\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	setFlags := func(name, path, version, pathType string) {
+		addProviderName = name
+		addProviderPath = path
+		addProviderInitialVersion = version
+		addProviderType = pathType
+	}
+	defer setFlags("", "", "", "")
+
+	t.Run("registers provider from flags", func(t *testing.T) {
+		setFlags("test-provider", testFile, "initial", "file")
+		if err := runAddProvider(addProviderCmd, nil); err != nil {
+			t.Fatalf("runAddProvider failed: %v", err)
+		}
+
+		config, err := loadProviders()
+		if err != nil {
+			t.Fatalf("loadProviders failed: %v", err)
+		}
+		provider, exists := config.Providers["test-provider"]
+		if !exists {
+			t.Fatal("test-provider not found after add-provider")
+		}
+		if provider.CurrentVersion != "initial" || provider.Type != "file" {
+			t.Errorf("Unexpected provider: %+v", provider)
+		}
+	})
+
+	t.Run("existing provider exits with dedicated code", func(t *testing.T) {
+		setFlags("test-provider", testFile, "other", "")
+		err := runAddProvider(addProviderCmd, nil)
+		if code := exitCodeFor(err); code != exitProviderExists {
+			t.Errorf("exit code = %d, want %d (err: %v)", code, exitProviderExists, err)
+		}
+	})
+
+	t.Run("missing path exits with dedicated code", func(t *testing.T) {
+		setFlags("missing-provider", filepath.Join(tempDir, "nope"), "initial", "")
+		err := runAddProvider(addProviderCmd, nil)
+		if code := exitCodeFor(err); code != exitPathMissing {
+			t.Errorf("exit code = %d, want %d (err: %v)", code, exitPathMissing, err)
+		}
+	})
+
+	t.Run("type mismatch is rejected", func(t *testing.T) {
+		setFlags("dir-provider", testFile, "initial", "directory")
+		if err := runAddProvider(addProviderCmd, nil); err == nil {
+			t.Error("Expected error when --type does not match the path")
+		}
+	})
+
+	t.Run("missing flag without terminal is rejected", func(t *testing.T) {
+		setFlags("no-version", testFile, "", "")
+		prompt := &prompter{interactive: false}
+		if _, err := prompt.value(addProviderInitialVersion, "initial-version", ""); err == nil {
+			t.Error("Expected error for missing flag when not interactive")
+		}
+	})
+}
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 
 	"github.com/spf13/cobra"
@@ -10,12 +12,19 @@ import (
 var addVersionCmd = &cobra.Command{
 	Use:   "add-version <provider_name> <version_name>",
 	Short: "Save the current state of a managed configuration as a new version",
-	Long:  `Save the current state of a managed configuration file or directory as a new named version.`,
-	Args:  cobra.ExactArgs(2),
-	RunE:  runAddVersion,
+	Long: `Save the current state of a managed configuration file or directory as a new named version.
+
+Saving different content under the name of an existing version keeps the content
+it replaces as a revision of the version. See "llmctx revisions" and
+"llmctx restore".`,
+	Args: cobra.ExactArgs(2),
+	RunE: runAddVersion,
 }
 
+var addVersionNote string
+
 func init() {
+	addVersionCmd.Flags().StringVar(&addVersionNote, "note", "", "Free-text note describing the version")
 	rootCmd.AddCommand(addVersionCmd)
 }
 
@@ -23,6 +32,17 @@ func runAddVersion(cmd *cobra.Command, args []string) error {
 	providerName := args[0]
 	versionName := args[1]
 
+	if err := validateVersionName(versionName); err != nil {
+		return err
+	}
+
+	// Keep other llmctx processes from switching while the version is captured
+	unlock, err := lockProviders()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	// Load providers config
 	config, err := loadProviders()
 	if err != nil {
@@ -34,45 +54,67 @@ func runAddVersion(cmd *cobra.Command, args []string) error {
 	if !exists {
 		return fmt.Errorf("provider '%s' not found", providerName)
 	}
+	if err := checkProviderIdle(providerName); err != nil {
+		return err
+	}
 
 	// Check if original path still exists
 	if _, err := os.Stat(provider.OriginalPath); os.IsNotExist(err) {
 		return fmt.Errorf("original path '%s' no longer exists", provider.OriginalPath)
 	}
 
-	// Get version path
-	versionPath, err := getVersionPath(providerName, versionName)
+	revision, err := captureVersion(config, &provider, versionName, addVersionNote)
 	if err != nil {
-		return fmt.Errorf("failed to get version path: %w", err)
+		return err
 	}
+	config.Providers[providerName] = provider
+	if err := config.saveProviders(); err != nil {
+		return fmt.Errorf("failed to save providers config: %w", err)
+	}
+	recordHistory(historyEvent{Action: historyAddVersion, Provider: providerName, Version: versionName})
 
-	// If version already exists, remove it first (for directories)
-	if _, err := os.Stat(versionPath); err == nil {
-		if provider.Type == "directory" {
-			if err := os.RemoveAll(versionPath); err != nil {
-				return fmt.Errorf("failed to remove existing version: %w", err)
-			}
-		} else {
-			if err := os.Remove(versionPath); err != nil {
-				return fmt.Errorf("failed to remove existing version: %w", err)
-			}
-		}
+	message := fmt.Sprintf("Successfully saved current state of '%s' as version '%s'", providerName, versionName)
+	if revision > 0 {
+		message += fmt.Sprintf(", keeping its previous content as revision %d", revision)
 	}
+	return renderAction(actionResult{
+		Action:   "add-version",
+		Provider: providerName,
+		Version:  versionName,
+		Revision: revision,
+		Message:  message,
+	})
+}
 
-	// Ensure version directory exists
-	versionDir, err := getVersionDir(providerName)
+// captureVersion saves the live state of a provider as a version and records
+// its metadata. Different content already stored under the name is kept as a
+// revision, whose number is returned; 0 means nothing was replaced. The caller
+// saves providers.json.
+func captureVersion(config *ProvidersConfig, provider *Provider, versionName, note string) (int, error) {
+	versionPath, err := getVersionPath(provider.Name, versionName)
 	if err != nil {
-		return fmt.Errorf("failed to get version directory: %w", err)
+		return 0, fmt.Errorf("failed to get version path: %w", err)
 	}
-	if err := os.MkdirAll(versionDir, 0755); err != nil {
-		return fmt.Errorf("failed to create version directory: %w", err)
+	version, err := ingestTree(provider.OriginalPath, provider.Type, provider.symlinkPolicy())
+	if err != nil {
+		return 0, fmt.Errorf("failed to copy current state to version storage: %w", err)
 	}
 
-	// Copy current state to version storage
-	if err := copyPath(provider.OriginalPath, versionPath, provider.Type); err != nil {
-		return fmt.Errorf("failed to copy current state to version storage: %w", err)
+	revision := 0
+	previous, err := versionTree(provider.Name, versionName)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return 0, err
+	}
+	if err == nil && !compareManifests(previous, version.tree()).Equal {
+		revision, err = keepRevision(*provider, versionName, config.settings().revisionLimit())
+		if err != nil {
+			return 0, err
+		}
+	}
+	if err := writeVersionManifest(versionPath, version); err != nil {
+		return 0, err
 	}
 
-	fmt.Printf("Successfully saved current state of '%s' as version '%s'\n", providerName, versionName)
-	return nil
-}
\ No newline at end of file
+	// Record when and where the version was captured
+	return revision, provider.recordVersion(versionName, note)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config [key] [value]",
+	Short: "Show or change global and per-provider settings",
+	Long: `Show or change settings stored in providers.json.
+
+With no arguments all settings are listed, with a key only that setting is shown,
+and with a key and a value the setting is changed. With --provider the settings
+of that provider are used instead of the global settings.
+
+Global settings:
+  autosave             save unbacked live state as an auto-snapshot before set-version
+                       switches away from it (true or false, default false)
+  auto-snapshot-limit  number of auto-snapshots kept per provider (default 10)
+  revision-limit       number of earlier revisions kept per version when it is
+                       overwritten (default 10)
+
+Provider settings:
+  env-var              environment variable the tool reads its configuration path
+                       from, e.g. CLAUDE_CONFIG_DIR; used by env and shell
+  capture-on-leave     save changes a tool made to the active version into it
+                       before switching away (true or false, default false)
+
+Provider retention settings (applied by gc; 0 removes the limit):
+  keep-auto            number of auto-snapshots kept (0 uses auto-snapshot-limit)
+  max-age              auto-snapshots last saved longer ago are deleted, e.g. 36h,
+                       30d or 2w
+  max-size             the oldest auto-snapshots are deleted while all stored
+                       versions together are larger, e.g. 500MB or 2GB
+  prune-named          let max-age and max-size delete versions saved by name as
+                       well (true or false, default false)`,
+	Args: cobra.MaximumNArgs(2),
+	RunE: runConfig,
+}
+
+var configProvider string
+
+func init() {
+	configCmd.Flags().StringVar(&configProvider, "provider", "", "Show or change the settings of this provider")
+	rootCmd.AddCommand(configCmd)
+}
+
+// settingValue is one setting in the structured output of config
+type settingValue struct {
+	Key         string `json:"key"`
+	Value       string `json:"value"`
+	Description string `json:"description"`
+}
+
+// configResult is the structured output of config
+type configResult struct {
+	Provider string         `json:"provider,omitempty"`
+	Settings []settingValue `json:"settings"`
+}
+
+func runConfig(cmd *cobra.Command, args []string) error {
+	keys := settingKeys
+	if configProvider != "" {
+		keys = providerSettingKeys
+	}
+	if len(args) > 0 {
+		key, err := findSettingKey(keys, args[0])
+		if err != nil {
+			return err
+		}
+		keys = []settingKey{key}
+	}
+
+	// Only changes need the lock
+	if len(args) == 2 {
+		unlock, err := lockProviders()
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+
+	config, err := loadProviders()
+	if err != nil {
+		return fmt.Errorf("failed to load providers: %w", err)
+	}
+
+	var provider *Provider
+	if configProvider != "" {
+		p, exists := config.Providers[configProvider]
+		if !exists {
+			return fmt.Errorf("provider '%s' not found", configProvider)
+		}
+		provider = &p
+	}
+
+	if len(args) == 2 {
+		if err := keys[0].set(config, provider, args[1]); err != nil {
+			return err
+		}
+		if provider != nil {
+			config.Providers[provider.Name] = *provider
+		}
+		if err := config.saveProviders(); err != nil {
+			return fmt.Errorf("failed to save providers config: %w", err)
+		}
+	}
+
+	result := configResult{Provider: configProvider, Settings: []settingValue{}}
+	for _, key := range keys {
+		result.Settings = append(result.Settings, settingValue{
+			Key:         key.name,
+			Value:       key.get(config, provider),
+			Description: key.description,
+		})
+	}
+
+	return render(result, func() {
+		if len(args) == 2 {
+			fmt.Printf("Set %s to %s\n", result.Settings[0].Key, result.Settings[0].Value)
+			return
+		}
+		if len(args) == 1 {
+			fmt.Println(result.Settings[0].Value)
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "KEY\tVALUE\tDESCRIPTION")
+		for _, setting := range result.Settings {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", setting.Key, setting.Value, setting.Description)
+		}
+		w.Flush()
+	})
+}
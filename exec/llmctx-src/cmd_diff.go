@@ -0,0 +1,254 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <provider_name> [<version_a>] [<version_b>]",
+	Short: "Show differences between versions or against the live configuration",
+	Long: `Show differences between stored versions or against the live configuration.
+
+With only a provider name, the active version is compared with the live
+configuration. With one version, that version is compared with the live
+configuration. With two versions, they are compared with each other.
+
+Directory providers get a summary of added, removed and changed files followed by
+unified diffs of changed text files. Values that look like secrets (API keys,
+tokens, passwords) are masked unless --show-secrets is given.`,
+	Args: cobra.RangeArgs(1, 3),
+	RunE: runDiff,
+}
+
+var diffShowSecrets bool
+
+func init() {
+	diffCmd.Flags().BoolVar(&diffShowSecrets, "show-secrets", false, "Show values that look like secrets instead of masking them")
+	rootCmd.AddCommand(diffCmd)
+}
+
+// diffSide is one of the two trees being compared
+type diffSide struct {
+	label    string
+	path     string
+	endpoint diffEndpoint
+	stored   *storedVersion // nil for the live configuration
+}
+
+// tree hashes the side for comparison
+func (s diffSide) tree(provider Provider) (treeManifest, error) {
+	if s.stored != nil {
+		return s.stored.tree(), nil
+	}
+	return hashTree(s.path, provider.Type, provider.symlinkPolicy())
+}
+
+// readFile returns the contents of a file of the side
+func (s diffSide) readFile(entry treeEntry) ([]byte, error) {
+	if s.stored != nil {
+		return readObject(entry.Digest)
+	}
+	return os.ReadFile(filepath.Join(s.path, filepath.FromSlash(entry.Path)))
+}
+
+func runDiff(cmd *cobra.Command, args []string) error {
+	providerName := args[0]
+
+	// Load providers config
+	config, err := loadProviders()
+	if err != nil {
+		return fmt.Errorf("failed to load providers: %w", err)
+	}
+
+	// Check if provider exists
+	provider, exists := config.Providers[providerName]
+	if !exists {
+		return fmt.Errorf("provider '%s' not found", providerName)
+	}
+
+	live := diffSide{
+		label:    "live",
+		path:     provider.OriginalPath,
+		endpoint: diffEndpoint{Kind: "live", Path: provider.OriginalPath},
+	}
+	var from, to diffSide
+	switch len(args) {
+	case 1:
+		if provider.CurrentVersion == "" {
+			return fmt.Errorf("provider '%s' has no active version; name a version to compare with", providerName)
+		}
+		if from, err = versionDiffSide(provider, provider.CurrentVersion); err != nil {
+			return err
+		}
+		to = live
+	case 2:
+		if from, err = versionDiffSide(provider, args[1]); err != nil {
+			return err
+		}
+		to = live
+	default:
+		if from, err = versionDiffSide(provider, args[1]); err != nil {
+			return err
+		}
+		if to, err = versionDiffSide(provider, args[2]); err != nil {
+			return err
+		}
+	}
+
+	if to.endpoint.Kind == "live" {
+		if _, err := os.Stat(live.path); os.IsNotExist(err) {
+			return fmt.Errorf("original path '%s' no longer exists", live.path)
+		}
+	}
+
+	fromTree, err := from.tree(provider)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", from.label, err)
+	}
+	toTree, err := to.tree(provider)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", to.label, err)
+	}
+
+	comparison := compareManifests(fromTree, toTree)
+	result := diffResult{
+		Provider:    providerName,
+		From:        from.endpoint,
+		To:          to.endpoint,
+		Equal:       comparison.Equal,
+		Differences: comparison.Differences,
+	}
+
+	for _, diff := range comparison.Differences {
+		if diff.Kind != DiffModified || diff.Detail != "content" || fromTree[diff.Path].Kind != entryFile {
+			continue
+		}
+
+		patch, err := diffFileContents(from, to, fromTree[diff.Path], toTree[diff.Path], !diffShowSecrets)
+		if err != nil {
+			return err
+		}
+		result.Patches = append(result.Patches, patch)
+	}
+
+	return render(result, func() {
+		if result.Equal {
+			fmt.Printf("No differences between %s and %s\n", from.label, to.label)
+			return
+		}
+
+		if provider.Type == "directory" {
+			fmt.Printf("Differences between %s and %s:\n", from.label, to.label)
+			for _, diff := range result.Differences {
+				fmt.Printf("  %-8s %s%s\n", diff.Kind, diff.Path, describeDifference(diff, fromTree, toTree))
+			}
+			fmt.Println()
+		} else if diff := result.Differences[0]; diff.Detail != "content" {
+			fmt.Printf("%s and %s differ%s\n", from.label, to.label, describeDifference(diff, fromTree, toTree))
+		}
+
+		for _, patch := range result.Patches {
+			fmt.Print(patch.Diff)
+		}
+	})
+}
+
+// diffResult is the structured output of diff
+type diffResult struct {
+	Provider    string           `json:"provider"`
+	From        diffEndpoint     `json:"from"`
+	To          diffEndpoint     `json:"to"`
+	Equal       bool             `json:"equal"`
+	Differences []FileDifference `json:"differences,omitempty"`
+	Patches     []filePatch      `json:"patches,omitempty"`
+}
+
+// diffEndpoint identifies one side of a diff: a stored version or the live configuration
+type diffEndpoint struct {
+	Kind    string `json:"kind"` // "version" or "live"
+	Version string `json:"version,omitempty"`
+	Path    string `json:"path"`
+}
+
+// filePatch is the unified diff of one changed file
+type filePatch struct {
+	Path   string `json:"path"`
+	Binary bool   `json:"binary,omitempty"`
+	Diff   string `json:"diff"`
+}
+
+// versionDiffSide resolves a stored version of a provider for comparison
+func versionDiffSide(provider Provider, versionName string) (diffSide, error) {
+	versionPath, err := getVersionPath(provider.Name, versionName)
+	if err != nil {
+		return diffSide{}, fmt.Errorf("failed to get version path: %w", err)
+	}
+	stored, err := loadVersion(provider.Name, versionName)
+	if err != nil {
+		return diffSide{}, err
+	}
+	return diffSide{
+		label:    "version " + versionName,
+		path:     versionPath,
+		endpoint: diffEndpoint{Kind: "version", Version: versionName, Path: versionPath},
+		stored:   stored,
+	}, nil
+}
+
+// describeDifference adds detail about how a changed entry differs
+func describeDifference(diff FileDifference, fromTree, toTree treeManifest) string {
+	if diff.Kind != DiffModified {
+		return ""
+	}
+	from, to := fromTree[diff.Path], toTree[diff.Path]
+	switch diff.Detail {
+	case "type":
+		return fmt.Sprintf(" (%s -> %s)", from.Kind, to.Kind)
+	case "mode":
+		return fmt.Sprintf(" (mode %04o -> %04o)", from.Mode, to.Mode)
+	}
+	return ""
+}
+
+// diffFileContents returns a unified diff of one file present on both sides.
+// With mask, secrets are masked in both contents before they are compared, so
+// no line of a private key or multi-line value shows up in the diff.
+func diffFileContents(from, to diffSide, fromEntry, toEntry treeEntry, mask bool) (filePatch, error) {
+	relPath := fromEntry.Path
+	fromLabel, toLabel := from.label, to.label
+	if relPath != "" {
+		fromLabel += ": " + relPath
+		toLabel += ": " + relPath
+	}
+
+	patch := filePatch{Path: relPath}
+
+	fromContent, err := from.readFile(fromEntry)
+	if err != nil {
+		return patch, fmt.Errorf("failed to read %s: %w", fromLabel, err)
+	}
+	toContent, err := to.readFile(toEntry)
+	if err != nil {
+		return patch, fmt.Errorf("failed to read %s: %w", toLabel, err)
+	}
+
+	if isBinary(fromContent) || isBinary(toContent) {
+		patch.Binary = true
+		patch.Diff = fmt.Sprintf("Binary files %s and %s differ\n", fromLabel, toLabel)
+		return patch, nil
+	}
+	fromText, toText := string(fromContent), string(toContent)
+	if mask {
+		fromText, toText = maskSecretsInText(fromText), maskSecretsInText(toText)
+		if fromText == toText {
+			patch.Diff = fmt.Sprintf("Masked secrets in %s and %s differ\n", fromLabel, toLabel)
+			return patch, nil
+		}
+	}
+	patch.Diff = unifiedDiff(fromLabel, toLabel, fromText, toText)
+	return patch, nil
+}
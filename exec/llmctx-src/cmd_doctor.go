@@ -0,0 +1,732 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the llmctx configuration for problems",
+	Long: `Check providers.json and the version store for problems:
+
+  names        provider and version names that break the naming rules
+  json         providers.json, its backup, version manifests and encryption
+               settings that cannot be parsed
+  permissions  files under ~/.llmctx, or the encryption key file, that other
+               users can access or that are owned by another user
+  paths        managed paths that are missing or are no longer a file or a
+               directory as recorded, and symlink providers whose link was
+               replaced
+  versions     active versions without storage, metadata, pins and profiles
+               referring to versions that are not stored, and storage not
+               referenced by providers.json
+  store        versions whose contents are missing from the object store,
+               contents no version uses, and working copies in ~/.llmctx/active
+               no provider links to
+
+With --fix the problems that can be repaired without losing data are repaired:
+providers.json is restored from its last known good copy (keeping the broken
+file), permissions are restricted to the owner, an active version without
+storage is pointed at a stored version matching the live content, stale
+metadata and pins are dropped, unused contents are deleted, and so are unlinked
+working copies whose content is stored as a version.
+
+Exits with code 6 if any problem is left.`,
+	Args: cobra.NoArgs,
+	RunE: runDoctor,
+}
+
+var doctorFix bool
+
+func init() {
+	doctorCmd.Flags().BoolVar(&doctorFix, "fix", false, "Repair the problems that can be repaired safely")
+	rootCmd.AddCommand(doctorCmd)
+}
+
+// doctorFinding is a problem reported by doctor
+type doctorFinding struct {
+	Check    string `json:"check"`
+	Provider string `json:"provider,omitempty"`
+	Version  string `json:"version,omitempty"`
+	Message  string `json:"message"`
+	Fixable  bool   `json:"fixable"`
+	Fixed    bool   `json:"fixed"`
+
+	// fix repairs the problem, changing config if needed; nil when the
+	// problem cannot be repaired safely
+	fix func(config *ProvidersConfig) error
+}
+
+// doctorCheck inspects the configuration and reports the problems it finds
+type doctorCheck struct {
+	name string
+	run  func(config *ProvidersConfig) ([]doctorFinding, error)
+}
+
+// doctorChecks are run in order by doctor
+var doctorChecks = []doctorCheck{
+	{name: "names", run: checkNames},
+	{name: "json", run: checkJSON},
+	{name: "permissions", run: checkPermissions},
+	{name: "paths", run: checkPaths},
+	{name: "versions", run: checkVersions},
+	{name: "store", run: checkStore},
+}
+
+// doctorResult is the structured output of doctor
+type doctorResult struct {
+	Findings []doctorFinding `json:"findings"`
+}
+
+func runDoctor(cmd *cobra.Command, args []string) error {
+	if doctorFix {
+		unlock, err := lockProviders()
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+
+	result := doctorResult{Findings: []doctorFinding{}}
+
+	// Load providers config; nothing else can be checked without it
+	config, err := loadProviders()
+	if err != nil {
+		result.Findings = append(result.Findings, doctorFinding{Check: "json", Message: err.Error()})
+	}
+	for _, check := range doctorChecks {
+		if config == nil {
+			break
+		}
+		findings, err := check.run(config)
+		if err != nil {
+			return fmt.Errorf("%s check failed: %w", check.name, err)
+		}
+		result.Findings = append(result.Findings, findings...)
+	}
+
+	remaining := 0
+	fixed := false
+	for i := range result.Findings {
+		finding := &result.Findings[i]
+		finding.Fixable = finding.fix != nil
+		if doctorFix && finding.fix != nil {
+			if err := finding.fix(config); err != nil {
+				return fmt.Errorf("failed to fix '%s': %w", finding.Message, err)
+			}
+			finding.Fixed = true
+			fixed = true
+		}
+		if !finding.Fixed {
+			remaining++
+		}
+	}
+	if fixed {
+		if err := config.saveProviders(); err != nil {
+			return fmt.Errorf("failed to save providers config: %w", err)
+		}
+	}
+
+	err = render(result, func() {
+		if len(result.Findings) == 0 {
+			fmt.Println("No problems found.")
+			return
+		}
+		for _, finding := range result.Findings {
+			switch {
+			case finding.Fixed:
+				fmt.Printf("[%s] %s (fixed)\n", finding.Check, finding.Message)
+			case finding.Fixable:
+				fmt.Printf("[%s] %s (fixable with --fix)\n", finding.Check, finding.Message)
+			default:
+				fmt.Printf("[%s] %s\n", finding.Check, finding.Message)
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	if remaining > 0 {
+		return silentExit(exitProblemsFound, fmt.Errorf("%d problem(s) found", remaining))
+	}
+	return nil
+}
+
+// sortedProviderNames returns the names of all providers in a stable order
+func sortedProviderNames(config *ProvidersConfig) []string {
+	var names []string
+	for name := range config.Providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// checkNames flags provider and version names that break the naming rules,
+// e.g. entries created before the rules were enforced
+func checkNames(config *ProvidersConfig) ([]doctorFinding, error) {
+	var findings []doctorFinding
+
+	for _, name := range sortedProviderNames(config) {
+		provider := config.Providers[name]
+
+		if err := validateProviderName(name); err != nil {
+			findings = append(findings, doctorFinding{Check: "names", Provider: name, Message: err.Error()})
+			// Storage of an unsafe provider name must not be touched
+			if checkPathComponent("provider", name) != nil {
+				continue
+			}
+		}
+		if provider.Name != name {
+			findings = append(findings, doctorFinding{
+				Check:    "names",
+				Provider: name,
+				Message:  fmt.Sprintf("provider '%s' is stored with mismatching name '%s'", name, provider.Name),
+			})
+		}
+		if provider.CurrentVersion != "" {
+			if err := validateVersionName(provider.CurrentVersion); err != nil {
+				findings = append(findings, doctorFinding{
+					Check:    "names",
+					Provider: name,
+					Version:  provider.CurrentVersion,
+					Message:  fmt.Sprintf("current version of '%s': %v", name, err),
+				})
+			}
+		}
+
+		versions, err := getAvailableVersions(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, version := range versions {
+			if version == provider.CurrentVersion {
+				continue
+			}
+			if err := validateVersionName(version); err != nil {
+				findings = append(findings, doctorFinding{
+					Check:    "names",
+					Provider: name,
+					Version:  version,
+					Message:  fmt.Sprintf("stored version of '%s': %v", name, err),
+				})
+			}
+		}
+	}
+
+	return findings, nil
+}
+
+// storableProviderNames returns the providers whose names can be used in
+// storage paths, in a stable order
+func storableProviderNames(config *ProvidersConfig) []string {
+	var names []string
+	for _, name := range sortedProviderNames(config) {
+		if checkPathComponent("provider", name) == nil {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// checkJSON flags configuration files that cannot be parsed
+func checkJSON(config *ProvidersConfig) ([]doctorFinding, error) {
+	var findings []doctorFinding
+
+	providersFile, err := getProvidersFilePath()
+	if err != nil {
+		return nil, err
+	}
+	backupFile, err := getBackupFilePath()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(providersFile); err == nil {
+		if _, err := readProvidersFile(providersFile); err != nil {
+			findings = append(findings, doctorFinding{
+				Check:   "json",
+				Message: fmt.Sprintf("'%s': %v; the last known good copy is in use", providersFile, err),
+				// Keep the broken file; saving the configuration writes the copy in use
+				fix: func(config *ProvidersConfig) error {
+					return os.Rename(providersFile, providersFile+".broken")
+				},
+			})
+		} else if _, err := os.Stat(backupFile); err == nil {
+			if _, err := readProvidersFile(backupFile); err != nil {
+				findings = append(findings, doctorFinding{
+					Check:   "json",
+					Message: fmt.Sprintf("'%s': %v", backupFile, err),
+					// Saving the configuration rewrites the backup
+					fix: func(config *ProvidersConfig) error { return nil },
+				})
+			}
+		}
+	}
+
+	for _, name := range storableProviderNames(config) {
+		versions, err := getAvailableVersions(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, version := range versions {
+			if _, err := loadVersion(name, version); err != nil {
+				findings = append(findings, doctorFinding{
+					Check:    "json",
+					Provider: name,
+					Version:  version,
+					Message:  fmt.Sprintf("version '%s' of '%s': %v", version, name, err),
+				})
+			}
+		}
+	}
+
+	encryption, err := loadEncryptionConfig()
+	if err != nil {
+		findings = append(findings, doctorFinding{Check: "json", Message: err.Error()})
+	} else if encryption != nil {
+		if _, err := readIdentity(encryption.Identity); err != nil {
+			findings = append(findings, doctorFinding{
+				Check:   "json",
+				Message: fmt.Sprintf("stored versions are encrypted, but their key cannot be used: %v", err),
+			})
+		}
+	}
+
+	return findings, nil
+}
+
+// checkPermissions flags files under ~/.llmctx, and the encryption key file,
+// that other users can access or that belong to another user
+func checkPermissions(config *ProvidersConfig) ([]doctorFinding, error) {
+	configDir, err := getConfigDir()
+	if err != nil {
+		return nil, err
+	}
+
+	activeDir, err := getActiveDir()
+	if err != nil {
+		return nil, err
+	}
+
+	providersDir := filepath.Join(configDir, "providers")
+
+	var paths []string
+	err = filepath.WalkDir(configDir, func(path string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && path == configDir {
+			return filepath.SkipDir
+		}
+		if err != nil {
+			return err
+		}
+		// Working copies, active or overlays, are written by the tools; their
+		// private parent protects them
+		overlays := filepath.Dir(path)
+		if d.IsDir() && (overlays == activeDir ||
+			filepath.Base(overlays) == "overlays" && filepath.Dir(filepath.Dir(overlays)) == providersDir) {
+			paths = append(paths, path)
+			return filepath.SkipDir
+		}
+		// The modes of symlinks are not used
+		if d.Type()&fs.ModeSymlink == 0 {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read '%s': %w", configDir, err)
+	}
+
+	if encryption, err := loadEncryptionConfig(); err == nil && encryption != nil {
+		if rel, err := filepath.Rel(configDir, encryption.Identity); err != nil || strings.HasPrefix(rel, "..") {
+			if _, err := os.Stat(encryption.Identity); err == nil {
+				paths = append(paths, encryption.Identity)
+			}
+		}
+	}
+
+	var findings []doctorFinding
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if uid, ok := fileOwner(info); ok && uid != os.Getuid() {
+			findings = append(findings, doctorFinding{
+				Check:   "permissions",
+				Message: fmt.Sprintf("'%s' is owned by user %d, not by the current user", path, uid),
+			})
+		}
+		if mode := info.Mode().Perm(); mode&0077 != 0 {
+			findings = append(findings, doctorFinding{
+				Check:   "permissions",
+				Message: fmt.Sprintf("'%s' is accessible by other users (mode %04o)", path, mode),
+				fix: func(config *ProvidersConfig) error {
+					return os.Chmod(path, mode&^0077)
+				},
+			})
+		}
+	}
+	return findings, nil
+}
+
+// checkPaths flags managed paths that are missing or no longer have the
+// recorded type
+func checkPaths(config *ProvidersConfig) ([]doctorFinding, error) {
+	var findings []doctorFinding
+	for _, name := range sortedProviderNames(config) {
+		provider := config.Providers[name]
+
+		info, err := os.Stat(provider.OriginalPath)
+		if err != nil {
+			message := fmt.Sprintf("managed path '%s' of '%s': %v", provider.OriginalPath, name, err)
+			if os.IsNotExist(err) {
+				message = fmt.Sprintf("managed path '%s' of '%s' does not exist", provider.OriginalPath, name)
+			}
+			findings = append(findings, doctorFinding{Check: "paths", Provider: name, Message: message})
+			continue
+		}
+
+		pathType := "file"
+		if info.IsDir() {
+			pathType = "directory"
+		}
+		if pathType != provider.Type {
+			findings = append(findings, doctorFinding{
+				Check:    "paths",
+				Provider: name,
+				Message:  fmt.Sprintf("managed path '%s' of '%s' is a %s, but is recorded as a %s", provider.OriginalPath, name, pathType, provider.Type),
+			})
+		}
+
+		if provider.activation() == activationSymlink && !provider.isLinked() {
+			findings = append(findings, doctorFinding{
+				Check:    "paths",
+				Provider: name,
+				Message:  fmt.Sprintf("managed path '%s' of '%s' no longer links to its active version; run 'llmctx activation %s symlink' to link it again", provider.OriginalPath, name, name),
+			})
+		}
+	}
+	return findings, nil
+}
+
+// checkVersions flags active versions without storage, metadata and pins of
+// versions that are not stored, and storage providers.json does not refer to
+func checkVersions(config *ProvidersConfig) ([]doctorFinding, error) {
+	var findings []doctorFinding
+
+	for _, name := range storableProviderNames(config) {
+		provider := config.Providers[name]
+		versions, err := getAvailableVersions(name)
+		if err != nil {
+			return nil, err
+		}
+
+		if current := provider.CurrentVersion; current != "" && !slices.Contains(versions, current) {
+			finding := doctorFinding{
+				Check:    "versions",
+				Provider: name,
+				Version:  current,
+				Message:  fmt.Sprintf("active version '%s' of '%s' is not stored", current, name),
+			}
+			// The live content may still match another stored version; if
+			// it cannot be read there is nothing to match
+			if matches, err := findMatchingVersions(provider); err == nil && len(matches) > 0 {
+				match := matches[0]
+				finding.Message += fmt.Sprintf("; the live content matches version '%s'", match)
+				finding.fix = func(config *ProvidersConfig) error {
+					provider := config.Providers[name]
+					provider.CurrentVersion = match
+					config.Providers[name] = provider
+					return nil
+				}
+			}
+			findings = append(findings, finding)
+		}
+
+		for _, version := range slices.Sorted(maps.Keys(provider.Versions)) {
+			if slices.Contains(versions, version) {
+				continue
+			}
+			findings = append(findings, doctorFinding{
+				Check:    "versions",
+				Provider: name,
+				Version:  version,
+				Message:  fmt.Sprintf("metadata of version '%s' of '%s' refers to a version that is not stored", version, name),
+				fix: func(config *ProvidersConfig) error {
+					delete(config.Providers[name].Versions, version)
+					return nil
+				},
+			})
+		}
+
+		for _, version := range provider.Pinned {
+			if slices.Contains(versions, version) {
+				continue
+			}
+			findings = append(findings, doctorFinding{
+				Check:    "versions",
+				Provider: name,
+				Version:  version,
+				Message:  fmt.Sprintf("pinned version '%s' of '%s' is not stored", version, name),
+				fix: func(config *ProvidersConfig) error {
+					provider := config.Providers[name]
+					provider.Pinned = slices.DeleteFunc(provider.Pinned, func(pinned string) bool { return pinned == version })
+					config.Providers[name] = provider
+					return nil
+				},
+			})
+		}
+
+		// Directories are plain copies that were never moved into the store
+		versionDir, err := getVersionDir(name)
+		if err != nil {
+			return nil, err
+		}
+		entries, err := os.ReadDir(versionDir)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read version directory: %w", err)
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				findings = append(findings, doctorFinding{
+					Check:    "versions",
+					Provider: name,
+					Message:  fmt.Sprintf("'%s' is not a stored version of '%s'", filepath.Join(versionDir, entry.Name()), name),
+				})
+			}
+		}
+	}
+
+	for _, profileName := range slices.Sorted(maps.Keys(config.Profiles)) {
+		profile := config.Profiles[profileName]
+		for _, providerName := range profile.members() {
+			version := profile.Versions[providerName]
+			message := ""
+			if _, exists := config.Providers[providerName]; !exists {
+				message = fmt.Sprintf("profile '%s' refers to provider '%s', which is not managed", profileName, providerName)
+			} else if _, err := loadVersion(providerName, version); errors.Is(err, fs.ErrNotExist) {
+				message = fmt.Sprintf("profile '%s' refers to version '%s' of '%s', which is not stored", profileName, version, providerName)
+			}
+			if message != "" {
+				findings = append(findings, doctorFinding{Check: "versions", Provider: providerName, Version: version, Message: message})
+			}
+		}
+	}
+
+	// Storage of removed providers, e.g. after remove-provider without --purge
+	orphans, err := unreferencedProviderDirs(config)
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range orphans {
+		findings = append(findings, doctorFinding{
+			Check:   "versions",
+			Message: fmt.Sprintf("'%s' holds versions of a provider that is not in providers.json; add the provider again to use them, or delete the directory", dir),
+		})
+	}
+
+	return findings, nil
+}
+
+// unreferencedProviderDirs returns the provider storage directories that no
+// provider in config uses
+func unreferencedProviderDirs(config *ProvidersConfig) ([]string, error) {
+	configDir, err := getConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	providersDir := filepath.Join(configDir, "providers")
+	entries, err := os.ReadDir(providersDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read providers directory: %w", err)
+	}
+
+	var dirs []string
+	for _, entry := range entries {
+		if _, exists := config.Providers[entry.Name()]; !exists {
+			dirs = append(dirs, filepath.Join(providersDir, entry.Name()))
+		}
+	}
+	return dirs, nil
+}
+
+// checkStore flags stored versions whose contents are missing from the object
+// store, versions never moved into it, and objects no stored version uses
+func checkStore(config *ProvidersConfig) ([]doctorFinding, error) {
+	configDir, err := getConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(filepath.Join(configDir, "providers"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read providers directory: %w", err)
+	}
+
+	var findings []doctorFinding
+	complete := true
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() || checkPathComponent("provider", name) != nil {
+			continue
+		}
+		legacy, err := isLegacyVersionDir(name)
+		if err != nil {
+			return nil, err
+		}
+		if legacy {
+			provider := Provider{Name: name}
+			if configured, exists := config.Providers[name]; exists {
+				provider = configured
+			}
+			findings = append(findings, doctorFinding{
+				Check:    "store",
+				Provider: name,
+				Message:  fmt.Sprintf("versions of '%s' are still plain copies from before the object store", name),
+				fix: func(config *ProvidersConfig) error {
+					return migrateProviderVersions(provider)
+				},
+			})
+			continue
+		}
+		versions, err := getAvailableVersions(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, version := range versions {
+			tree, err := versionTree(name, version)
+			if err != nil {
+				// Reported by the json check
+				complete = false
+				continue
+			}
+			missing := 0
+			for digest := range objectSizes(tree) {
+				objectPath, err := getObjectPath(digest)
+				if err != nil {
+					return nil, err
+				}
+				if _, err := os.Stat(objectPath); os.IsNotExist(err) {
+					missing++
+				}
+			}
+			if missing > 0 {
+				findings = append(findings, doctorFinding{
+					Check:    "store",
+					Provider: name,
+					Version:  version,
+					Message:  fmt.Sprintf("version '%s' of '%s' is missing the contents of %d file(s)", version, name, missing),
+				})
+			}
+		}
+	}
+
+	stray, err := strayWorkingCopies(config)
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range stray {
+		// A tool may have written to the copy after it was unlinked
+		if !isSavedWorkingCopy(config, dir) {
+			findings = append(findings, doctorFinding{
+				Check:   "store",
+				Message: fmt.Sprintf("working copy '%s' is not linked by any provider and holds content no version stores; save what is needed and delete it", dir),
+			})
+			continue
+		}
+		findings = append(findings, doctorFinding{
+			Check:   "store",
+			Message: fmt.Sprintf("working copy '%s' is not linked by any provider", dir),
+			fix: func(config *ProvidersConfig) error {
+				return removeAll(dir)
+			},
+		})
+	}
+
+	// Objects of an unreadable manifest would look unused
+	if complete {
+		count, size, err := sweepObjects(nil, true)
+		if err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			findings = append(findings, doctorFinding{
+				Check:   "store",
+				Message: fmt.Sprintf("%d stored object(s) (%s) are not used by any version", count, formatSize(size)),
+				fix: func(config *ProvidersConfig) error {
+					_, _, err := sweepObjects(nil, false)
+					return err
+				},
+			})
+		}
+	}
+
+	return findings, nil
+}
+
+// strayWorkingCopies returns the directories in the active directory that no
+// provider links to
+func strayWorkingCopies(config *ProvidersConfig) ([]string, error) {
+	activeDir, err := getActiveDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(activeDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read active directory: %w", err)
+	}
+
+	linked := make(map[string]bool)
+	for _, provider := range config.Providers {
+		if workingCopy := linkedWorkingCopy(provider.OriginalPath); workingCopy != "" {
+			linked[filepath.Dir(workingCopy)] = true
+		}
+	}
+
+	var stray []string
+	for _, entry := range entries {
+		dir := filepath.Join(activeDir, entry.Name())
+		if !linked[dir] {
+			stray = append(stray, dir)
+		}
+	}
+	return stray, nil
+}
+
+// isSavedWorkingCopy reports whether deleting a working copy loses nothing:
+// it is empty, or its content is stored as a version of its provider
+func isSavedWorkingCopy(config *ProvidersConfig, dir string) bool {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return false
+	}
+	if len(entries) == 0 {
+		return true
+	}
+	for _, provider := range config.Providers {
+		if !strings.HasPrefix(filepath.Base(dir), provider.Name+"-") {
+			continue
+		}
+		provider.OriginalPath = filepath.Join(dir, filepath.Base(provider.OriginalPath))
+		if _, err := os.Lstat(provider.OriginalPath); err != nil {
+			continue
+		}
+		if matches, err := findMatchingVersions(provider); err == nil && len(matches) > 0 {
+			return true
+		}
+	}
+	return false
+}
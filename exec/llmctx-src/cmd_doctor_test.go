@@ -0,0 +1,149 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDoctorNames(t *testing.T) {
+	_, config, provider := setupSwitchTest(t)
+
+	if err := runDoctor(doctorCmd, nil); err != nil {
+		t.Fatalf("Expected healthy configuration to pass, got: %v", err)
+	}
+
+	// Entries written before names were validated
+	config.Providers[".."] = Provider{Name: "..", OriginalPath: provider.OriginalPath, Type: "file"}
+	config.Providers["has space"] = Provider{Name: "has space", OriginalPath: provider.OriginalPath, Type: "file", CurrentVersion: "../x"}
+	if err := config.saveProviders(); err != nil {
+		t.Fatalf("saveProviders failed: %v", err)
+	}
+
+	findings, err := checkNames(config)
+	if err != nil {
+		t.Fatalf("checkNames failed: %v", err)
+	}
+	if len(findings) != 3 {
+		t.Errorf("Expected 3 findings, got %d: %+v", len(findings), findings)
+	}
+
+	if code := exitCodeFor(runDoctor(doctorCmd, nil)); code != exitProblemsFound {
+		t.Errorf("exit code = %d, want %d", code, exitProblemsFound)
+	}
+}
+
+func TestDoctorFix(t *testing.T) {
+	tempDir, config, provider := setupSwitchTest(t)
+	t.Cleanup(func() { doctorFix = false })
+
+	// The active version lost its storage, but the live file still matches "work"
+	provider.CurrentVersion = "lost"
+	provider.Versions = map[string]VersionMeta{"ghost": {}}
+	provider.Pinned = []string{"ghost"}
+	config.Providers[provider.Name] = provider
+	if err := config.saveProviders(); err != nil {
+		t.Fatalf("saveProviders failed: %v", err)
+	}
+
+	// Removing "personal" leaves its content unused
+	versionPath, _ := getVersionPath(provider.Name, "personal")
+	if err := os.Remove(versionPath); err != nil {
+		t.Fatal(err)
+	}
+
+	configDir, _ := getConfigDir()
+	providersFile, _ := getProvidersFilePath()
+	if err := os.Chmod(providersFile, 0644); err != nil {
+		t.Fatal(err)
+	}
+	orphan := filepath.Join(configDir, "providers", "removed", "versions")
+	if err := os.MkdirAll(orphan, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	findings := func() map[string]int {
+		result := map[string]int{}
+		for _, check := range doctorChecks {
+			config, _ := loadProviders()
+			found, err := check.run(config)
+			if err != nil {
+				t.Fatalf("%s check failed: %v", check.name, err)
+			}
+			for _, finding := range found {
+				result[check.name]++
+				if finding.fix == nil {
+					result["unfixable"]++
+				}
+			}
+		}
+		return result
+	}
+
+	want := map[string]int{"permissions": 1, "versions": 4, "store": 1, "unfixable": 1}
+	if got := findings(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Findings = %v, want %v", got, want)
+	}
+
+	doctorFix = true
+	if code := exitCodeFor(runDoctor(doctorCmd, nil)); code != exitProblemsFound {
+		t.Errorf("exit code = %d, want %d for the orphaned storage", code, exitProblemsFound)
+	}
+
+	want = map[string]int{"versions": 1, "unfixable": 1}
+	if got := findings(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Findings after --fix = %v, want %v", got, want)
+	}
+	config, err := loadProviders()
+	if err != nil {
+		t.Fatalf("loadProviders failed: %v", err)
+	}
+	fixed := config.Providers[provider.Name]
+	if fixed.CurrentVersion != "work" || len(fixed.Versions) != 0 || len(fixed.Pinned) != 0 {
+		t.Errorf("Provider after --fix = %+v", fixed)
+	}
+
+	t.Run("broken providers.json", func(t *testing.T) {
+		if err := os.RemoveAll(filepath.Dir(orphan)); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(providersFile, []byte("{broken"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := runDoctor(doctorCmd, nil); err != nil {
+			t.Fatalf("Expected --fix to restore providers.json, got: %v", err)
+		}
+		if _, err := readProvidersFile(providersFile); err != nil {
+			t.Errorf("providers.json still broken: %v", err)
+		}
+		if _, err := os.Stat(providersFile + ".broken"); err != nil {
+			t.Errorf("Expected the broken file to be kept: %v", err)
+		}
+	})
+
+	t.Run("overlay working copies", func(t *testing.T) {
+		overlayDir, err := getOverlayDir(provider.Name, "work")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.MkdirAll(overlayDir, 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(overlayDir, "config.json"), []byte("{}"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		found, err := checkPermissions(config)
+		if err != nil || len(found) != 0 {
+			t.Errorf("checkPermissions = %+v, %v; want the working copy left alone", found, err)
+		}
+	})
+
+	t.Run("type mismatch", func(t *testing.T) {
+		config.Providers["dir"] = Provider{Name: "dir", OriginalPath: tempDir, Type: "file"}
+		found, err := checkPaths(config)
+		if err != nil || len(found) != 1 || found[0].fix != nil {
+			t.Errorf("checkPaths = %+v, %v; want one unfixable finding", found, err)
+		}
+	})
+}
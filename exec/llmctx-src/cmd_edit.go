@@ -33,11 +33,22 @@ func runEdit(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("provider '%s' not found", providerName)
 	}
 
-	// Display the absolute path
-	fmt.Println(provider.OriginalPath)
-	
-	// Display reminder
-	fmt.Printf("\nReminder: After making changes, use 'llmctx add-version %s <new_version_name>' to save your changes.\n", providerName)
-
-	return nil
-}
\ No newline at end of file
+	result := editResult{
+		Provider:     providerName,
+		OriginalPath: provider.OriginalPath,
+	}
+
+	return render(result, func() {
+		// Display the absolute path
+		fmt.Println(result.OriginalPath)
+
+		// Display reminder
+		fmt.Printf("\nReminder: After making changes, use 'llmctx add-version %s <new_version_name>' to save your changes.\n", providerName)
+	})
+}
+
+// editResult is the structured output of edit
+type editResult struct {
+	Provider     string `json:"provider"`
+	OriginalPath string `json:"original_path"`
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var encryptCmd = &cobra.Command{
+	Use:   "encrypt",
+	Short: "Encrypt stored versions at rest",
+	Long: `Encrypt the contents of all stored versions, and every version saved from now on.
+
+Contents are encrypted to an X25519 key. By default a new key is created in
+~/.llmctx/identity, protected by a passphrase that is asked for whenever stored
+contents are read back (set-version, undo, diff). With --key-file the key is
+read from the given file instead, or created there without a passphrase if it
+does not exist yet; keep such a file somewhere safe, such as removable media.
+
+Encrypted contents are named by an HMAC with a name key kept in the key file
+next to the public key, and the digests in manifests and providers.json are
+taken the same way, so they cannot be matched against guessed contents.
+Saving versions and comparing them with the live configuration only needs the
+public key and the name key, so add-version, status and the backup check of
+set-version work without the passphrase. The passphrase is read from
+LLMCTX_PASSPHRASE when set.
+
+Running encrypt again encrypts anything left unencrypted by an interrupted run.
+File names and sizes stay readable in the version manifests, and plaintext
+copies may remain in backups or in free disk space.`,
+	Args: cobra.NoArgs,
+	RunE: runEncrypt,
+}
+
+var encryptKeyFile string
+
+func init() {
+	encryptCmd.Flags().StringVar(&encryptKeyFile, "key-file", "", "Use or create an unprotected key file at this path instead of a passphrase")
+	rootCmd.AddCommand(encryptCmd)
+}
+
+// encryptResult is the structured output of encrypt
+type encryptResult struct {
+	Recipient        string `json:"recipient"`
+	Identity         string `json:"identity"`
+	IdentityCreated  bool   `json:"identity_created"`
+	ObjectsEncrypted int    `json:"objects_encrypted"`
+}
+
+func runEncrypt(cmd *cobra.Command, args []string) error {
+	identityPath, err := getDefaultIdentityPath()
+	if err != nil {
+		return err
+	}
+	if encryptKeyFile != "" {
+		if identityPath, err = expandPath(encryptKeyFile); err != nil {
+			return fmt.Errorf("failed to expand path: %w", err)
+		}
+	}
+
+	// Ask for the passphrase of a new key before taking the lock
+	var passphrase []byte
+	config, err := loadEncryptionConfig()
+	if err != nil {
+		return err
+	}
+	if config == nil && encryptKeyFile == "" {
+		if _, err := os.Stat(identityPath); os.IsNotExist(err) {
+			if passphrase, err = readPassphrase("Enter a passphrase for the new key: ", true); err != nil {
+				return err
+			}
+		}
+	}
+
+	unlock, err := lockProviders()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	result := encryptResult{Identity: identityPath}
+	config, err = loadEncryptionConfig()
+	if err != nil {
+		return err
+	}
+	if config != nil {
+		if encryptKeyFile != "" && config.Identity != identityPath {
+			return fmt.Errorf("stored versions are already encrypted to the key in '%s'", config.Identity)
+		}
+		result.Identity = config.Identity
+		if err := ensureNameKey(config.Identity); err != nil {
+			return err
+		}
+	} else {
+		if config, result.IdentityCreated, err = setUpIdentity(identityPath, passphrase); err != nil {
+			return err
+		}
+		if err := saveEncryptionConfig(config); err != nil {
+			return err
+		}
+	}
+	result.Recipient = config.Recipient
+
+	recipient, err := parsePublicKey(config.Recipient)
+	if err != nil {
+		return err
+	}
+	if result.ObjectsEncrypted, err = encryptObjects(recipient); err != nil {
+		return fmt.Errorf("failed to encrypt stored versions: %w", err)
+	}
+	if err := refreshVersionDigests(); err != nil {
+		return err
+	}
+
+	return render(result, func() {
+		if result.IdentityCreated {
+			fmt.Printf("Created a new key in '%s'\n", result.Identity)
+		}
+		fmt.Printf("Encrypted %d stored object(s); new versions are encrypted to the key in '%s'\n", result.ObjectsEncrypted, result.Identity)
+		fmt.Println("Keep this key safe: without it stored versions cannot be restored.")
+	})
+}
+
+// setUpIdentity returns encryption settings for the key at identityPath,
+// creating the key if the file does not exist. A new key is sealed with the
+// passphrase if one is given.
+func setUpIdentity(identityPath string, passphrase []byte) (*encryptionConfig, bool, error) {
+	// An existing key, for example one shared with another machine, is reused
+	if identity, err := readIdentity(identityPath); err == nil {
+		if err := ensureNameKey(identityPath); err != nil {
+			return nil, false, err
+		}
+		return &encryptionConfig{Recipient: identity.PublicKey, Identity: identityPath}, false, nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, false, err
+	}
+
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to generate key: %w", err)
+	}
+	if err := writeIdentity(identityPath, key, passphrase); err != nil {
+		return nil, false, err
+	}
+	return &encryptionConfig{Recipient: encodePublicKey(key.PublicKey()), Identity: identityPath}, true, nil
+}
+
+// refreshVersionDigests recomputes the digests recorded in providers.json for
+// stored versions whose contents were renamed by encryptObjects
+func refreshVersionDigests() error {
+	config, err := loadProviders()
+	if err != nil {
+		return fmt.Errorf("failed to load providers: %w", err)
+	}
+	for _, name := range sortedProviderNames(config) {
+		provider := config.Providers[name]
+		for versionName, meta := range provider.Versions {
+			tree, err := versionTree(name, versionName)
+			if err != nil {
+				// Reported by doctor
+				continue
+			}
+			meta.Digest = manifestDigest(tree)
+			provider.Versions[versionName] = meta
+		}
+	}
+	return config.saveProviders()
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var envCmd = &cobra.Command{
+	Use:   "env <provider_name> <version_name>",
+	Short: "Print shell commands that use a version in the current shell only",
+	Long: `Print shell commands that point the provider's environment variable (see
+"llmctx config --provider <name> env-var") at a working copy of a stored version,
+so that only the current shell uses it and the original path is left alone:
+
+  eval "$(llmctx env claude work)"
+
+The working copy is kept in ~/.llmctx/providers/<name>/overlays/<version> and
+shared by every shell using the version. Changes the tool makes to it are kept
+until it is recreated from the stored version with --refresh.
+
+The commands use POSIX shell syntax, or fish syntax when $SHELL is fish.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runEnv,
+}
+
+var (
+	envRefresh bool
+	envShell   string
+)
+
+func init() {
+	envCmd.Flags().BoolVar(&envRefresh, "refresh", false, "Recreate the working copy from the stored version, discarding changes made to it")
+	envCmd.Flags().StringVar(&envShell, "shell", "", "Shell syntax to print: \"sh\" or \"fish\" (default from $SHELL)")
+	rootCmd.AddCommand(envCmd)
+}
+
+func runEnv(cmd *cobra.Command, args []string) error {
+	syntax, err := shellSyntax(envShell)
+	if err != nil {
+		return err
+	}
+
+	result, err := prepareOverlay(args[0], args[1], envRefresh)
+	if err != nil {
+		return err
+	}
+
+	return render(result, func() {
+		fmt.Println(shellExport(syntax, result.EnvVar, result.Path))
+	})
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var execCmd = &cobra.Command{
+	Use:   "exec <provider>=<version>... -- <command> [args...]",
+	Short: "Run one command with the given versions active",
+	Long: `Run a single command with the given versions active, then put every provider
+back the way it was, e.g.
+
+  llmctx exec gh=work aws=corp -- terraform apply
+
+Providers with an environment variable (see "llmctx config --provider <name>
+env-var") get it pointed at a working copy of the version, as with "llmctx env";
+their original path is never touched. Other providers are switched in place for
+the duration of the command and switched back afterwards, even when the command
+fails or llmctx receives SIGTERM or SIGHUP, which are passed on to the command.
+If llmctx itself is killed, the next llmctx invocation puts them back.
+
+Providers whose current state is not saved in any version are saved as an
+auto-snapshot first, so that state can be restored. Changes the command makes to
+a provider's configuration are saved as an auto-snapshot before switching back.
+
+While the command runs, the providers switched in place cannot be switched,
+renamed or removed, and another exec of them is refused.
+
+Exits with the exit code of the command.`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: runExec,
+}
+
+func init() {
+	rootCmd.AddCommand(execCmd)
+}
+
+func runExec(cmd *cobra.Command, args []string) error {
+	dash := cmd.ArgsLenAtDash()
+	if dash < 1 || dash == len(args) {
+		return fmt.Errorf("usage: llmctx exec <provider>=<version>... -- <command> [args...]")
+	}
+	members, err := parseProfileMembers(args[:dash])
+	if err != nil {
+		return err
+	}
+	command := args[dash:]
+
+	env, leases, err := startExec(members, strings.Join(command, " "))
+	if err != nil {
+		return err
+	}
+
+	runErr := runCommand(command, env)
+
+	// Put the providers back whatever happened to the command
+	if err := finishExec(leases); err != nil {
+		return errors.Join(runErr, err)
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(runErr, &exitErr) {
+		code := exitErr.ExitCode()
+		if code < 0 {
+			code = exitGeneral
+		}
+		return silentExit(code, runErr)
+	}
+	if runErr != nil {
+		return fmt.Errorf("failed to run '%s': %w", command[0], runErr)
+	}
+	return nil
+}
+
+// startExec prepares the versions for the command: it returns the environment
+// variables pointing at working copies and the leases of providers switched in
+// place. If anything fails, every provider is left as it was.
+func startExec(members map[string]string, commandLine string) ([]string, []*execLease, error) {
+	unlock, err := lockProviders()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer unlock()
+
+	// Load providers config
+	config, err := loadProviders()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load providers: %w", err)
+	}
+
+	// Check every provider before changing any of them
+	names := slices.Sorted(maps.Keys(members))
+	for _, providerName := range names {
+		provider, exists := config.Providers[providerName]
+		if !exists {
+			return nil, nil, fmt.Errorf("provider '%s' not found", providerName)
+		}
+		if _, err := loadVersion(providerName, members[providerName]); err != nil {
+			return nil, nil, err
+		}
+		if provider.EnvVar != "" {
+			continue
+		}
+		if _, err := os.Stat(provider.OriginalPath); os.IsNotExist(err) {
+			return nil, nil, fmt.Errorf("original path '%s' of '%s' no longer exists", provider.OriginalPath, providerName)
+		}
+		if err := checkProviderIdle(providerName); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Then save what tools changed and find the providers to switch
+	var env []string
+	var leases []*execLease
+	for _, providerName := range names {
+		versionName := members[providerName]
+		provider := config.Providers[providerName]
+
+		if provider.EnvVar != "" {
+			result, err := overlayFor(config, providerName, versionName, false)
+			if err != nil {
+				return nil, nil, err
+			}
+			env = append(env, result.EnvVar+"="+result.Path)
+			continue
+		}
+
+		if _, err := syncLinkedVersion(config, &provider); err != nil {
+			return nil, nil, err
+		}
+		if _, err := captureOnLeave(config, &provider); err != nil {
+			return nil, nil, err
+		}
+		matches, err := findMatchingVersions(provider)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to check if current state of '%s' is backed up: %w", providerName, err)
+		}
+		if slices.Contains(matches, versionName) {
+			continue
+		}
+
+		lease := &execLease{
+			Provider:        providerName,
+			Version:         versionName,
+			PreviousVersion: provider.CurrentVersion,
+			Command:         commandLine,
+			PID:             os.Getpid(),
+			StartedAt:       time.Now().UTC(),
+		}
+		if slices.Contains(matches, provider.CurrentVersion) {
+			lease.RestoreVersion = provider.CurrentVersion
+		} else if len(matches) > 0 {
+			lease.RestoreVersion = matches[0]
+		}
+		leases = append(leases, lease)
+	}
+
+	// The state to come back to must be stored before it is switched away from
+	for _, lease := range leases {
+		if lease.RestoreVersion != "" {
+			continue
+		}
+		provider := config.Providers[lease.Provider]
+		snapshot, err := takeAutoSnapshot(config, &provider, fmt.Sprintf("saved before running '%s'", commandLine))
+		if err != nil {
+			return nil, nil, err
+		}
+		lease.RestoreVersion = snapshot
+		fmt.Fprintf(os.Stderr, "Saved unbacked state of '%s' as '%s'\n", lease.Provider, snapshot)
+	}
+
+	// Undo everything done so far, putting switched providers back
+	var switched []*execLease
+	abort := func(err error) ([]string, []*execLease, error) {
+		var errs []error
+		for _, lease := range leases {
+			if slices.Contains(switched, lease) {
+				if _, err := endLease(config, lease); err != nil {
+					errs = append(errs, err)
+					lease.unlock()
+					continue
+				}
+			}
+			errs = append(errs, lease.release())
+		}
+		return nil, nil, errors.Join(append([]error{err}, errs...)...)
+	}
+
+	var acquired []*execLease
+	for _, lease := range leases {
+		if err := acquireLease(lease); err != nil {
+			leases = acquired
+			return abort(err)
+		}
+		acquired = append(acquired, lease)
+	}
+
+	for _, lease := range leases {
+		journal, err := swapInVersion(config.Providers[lease.Provider], lease.Version, "")
+		if err != nil {
+			return abort(fmt.Errorf("failed to switch '%s': %w", lease.Provider, err))
+		}
+		if err := finishSwitch(journal); err != nil {
+			return abort(err)
+		}
+		switched = append(switched, lease)
+	}
+	return env, leases, nil
+}
+
+// runCommand runs the command with extra environment variables and waits for
+// it, passing on signals meant to stop it
+func runCommand(command []string, env []string) error {
+	child := exec.Command(command[0], command[1:]...)
+	child.Stdin, child.Stdout, child.Stderr = os.Stdin, os.Stdout, os.Stderr
+	child.Env = append(os.Environ(), env...)
+
+	// llmctx must outlive the command to switch back. Ctrl-C and Ctrl-\ reach
+	// the command from the terminal directly, so only the others are passed on.
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGQUIT, syscall.SIGTERM, syscall.SIGHUP)
+	defer func() {
+		signal.Stop(signals)
+		close(signals)
+	}()
+
+	if err := child.Start(); err != nil {
+		return err
+	}
+	go func() {
+		for sig := range signals {
+			if sig == syscall.SIGTERM || sig == syscall.SIGHUP {
+				child.Process.Signal(sig)
+			}
+		}
+	}()
+	return child.Wait()
+}
+
+// finishExec puts back the providers switched for the command
+func finishExec(leases []*execLease) error {
+	if len(leases) == 0 {
+		return nil
+	}
+
+	unlock, err := lockProviders()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// Load providers config
+	config, err := loadProviders()
+	if err != nil {
+		return fmt.Errorf("failed to load providers: %w", err)
+	}
+
+	var errs []error
+	for _, lease := range leases {
+		snapshot, err := endLease(config, lease)
+		if snapshot != "" {
+			fmt.Fprintf(os.Stderr, "Saved changes made by the command to '%s' as '%s'\n", lease.Provider, snapshot)
+		}
+		if err != nil {
+			// The next llmctx invocation tries again
+			lease.unlock()
+			errs = append(errs, err)
+			continue
+		}
+		errs = append(errs, lease.release())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runExecArgs runs exec with command line arguments, including the "--"
+func runExecArgs(t *testing.T, args ...string) error {
+	t.Helper()
+	if err := execCmd.Flags().Parse(args); err != nil {
+		t.Fatalf("Failed to parse arguments: %v", err)
+	}
+	return runExec(execCmd, execCmd.Flags().Args())
+}
+
+func TestExecRestoresVersion(t *testing.T) {
+	tempDir, _, provider := setupSwitchTest(t)
+	seen := filepath.Join(tempDir, "seen")
+
+	// The command sees the version, changes it and fails
+	script := "cat '" + provider.OriginalPath + "' > '" + seen + "'; echo changed > '" + provider.OriginalPath + "'; exit 3"
+	err := runExecArgs(t, provider.Name+"=personal", "--", "sh", "-c", script)
+	if code := exitCodeFor(err); code != 3 {
+		t.Fatalf("Expected exit code 3, got %d (%v)", code, err)
+	}
+
+	assertFileContent(t, seen, "personal")
+	assertLive(t, map[string]string{provider.Name: "work"})
+
+	// The change made by the command was kept
+	config, _ := loadProviders()
+	snapshots := autoSnapshots(config.Providers[provider.Name])
+	if len(snapshots) != 1 {
+		t.Fatalf("Expected one auto-snapshot, got %v", snapshots)
+	}
+	assertVersionContent(t, provider.Name, snapshots[0], "changed\n")
+
+	journalDir, _ := getJournalDir()
+	if entries, _ := os.ReadDir(journalDir); len(entries) != 0 {
+		t.Errorf("Expected the lease to be removed, found %d journal entries", len(entries))
+	}
+}
+
+func TestExecUsesOverlay(t *testing.T) {
+	tempDir, config, provider := setupSwitchTest(t)
+	provider.EnvVar = "TEST_CONFIG"
+	config.Providers[provider.Name] = provider
+	if err := config.saveProviders(); err != nil {
+		t.Fatalf("Failed to save providers: %v", err)
+	}
+	seen := filepath.Join(tempDir, "seen")
+
+	if err := runExecArgs(t, provider.Name+"=personal", "--", "sh", "-c", `cat "$TEST_CONFIG" > '`+seen+`'`); err != nil {
+		t.Fatalf("exec failed: %v", err)
+	}
+	assertFileContent(t, seen, "personal")
+	assertFileContent(t, provider.OriginalPath, "work")
+}
+
+func TestExecRefusesBusyProvider(t *testing.T) {
+	_, config, provider := setupSwitchTest(t)
+
+	lease := &execLease{Provider: provider.Name, Version: "personal", RestoreVersion: "work", PreviousVersion: "work", Command: "sleep 60", PID: 1}
+	if err := acquireLease(lease); err != nil {
+		t.Fatalf("acquireLease failed: %v", err)
+	}
+	defer lease.release()
+
+	if err := switchVersion(config, provider, "personal"); err == nil || !strings.Contains(err.Error(), "llmctx exec") {
+		t.Errorf("Expected set-version to be refused, got %v", err)
+	}
+	if err := runExecArgs(t, provider.Name+"=personal", "--", "true"); err == nil {
+		t.Error("Expected a concurrent exec to be refused")
+	}
+
+	// A running exec is left alone by recovery
+	if err := recoverInterruptedSwitches(); err != nil {
+		t.Fatalf("recoverInterruptedSwitches failed: %v", err)
+	}
+	if err := checkProviderIdle(provider.Name); err == nil {
+		t.Error("Expected the lease to still be held")
+	}
+}
+
+func TestExecBlocksVersionWrites(t *testing.T) {
+	_, config, provider := setupSwitchTest(t)
+	provider = enableCaptureOnLeave(t, config, provider)
+
+	lease := &execLease{Provider: provider.Name, Version: "personal", RestoreVersion: "work", PreviousVersion: "work", Command: "sleep 60", PID: 1}
+	if err := acquireLease(lease); err != nil {
+		t.Fatalf("acquireLease failed: %v", err)
+	}
+	defer lease.release()
+
+	// The live content belongs to the exec, not to any version
+	if err := os.WriteFile(provider.OriginalPath, []byte("written by the command"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := runAddVersion(addVersionCmd, []string{provider.Name, "work"}); err == nil || !strings.Contains(err.Error(), "llmctx exec") {
+		t.Errorf("Expected add-version to be refused, got %v", err)
+	}
+	if err := runSetVersion(setVersionCmd, []string{provider.Name, "personal"}); err == nil {
+		t.Error("Expected set-version to be refused")
+	}
+	assertVersionContent(t, provider.Name, "work", "work")
+	if revisions, _ := versionRevisions(provider.Name, "work"); len(revisions) != 0 {
+		t.Errorf("Expected no revisions of work, got %v", revisions)
+	}
+}
+
+func TestExecChecksAllProvidersFirst(t *testing.T) {
+	_, config, provider := setupSwitchTest(t)
+	provider = enableCaptureOnLeave(t, config, provider)
+	if err := os.WriteFile(provider.OriginalPath, []byte("work-refreshed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A later provider fails its check, so nothing is saved for the first
+	if err := runExecArgs(t, provider.Name+"=personal", "unknown-provider=work", "--", "true"); err == nil {
+		t.Fatal("Expected an unknown provider to be refused")
+	}
+	assertVersionContent(t, provider.Name, "work", "work")
+	assertFileContent(t, provider.OriginalPath, "work-refreshed")
+}
+
+func TestRecoverLease(t *testing.T) {
+	_, config, provider := setupSwitchTest(t)
+
+	// Simulate an exec killed while the command was running
+	lease := &execLease{Provider: provider.Name, Version: "personal", RestoreVersion: "work", PreviousVersion: "work", Command: "sleep 60", PID: 1}
+	if err := acquireLease(lease); err != nil {
+		t.Fatalf("acquireLease failed: %v", err)
+	}
+	journal, err := swapInVersion(config.Providers[provider.Name], "personal", "")
+	if err != nil {
+		t.Fatalf("swapInVersion failed: %v", err)
+	}
+	if err := finishSwitch(journal); err != nil {
+		t.Fatal(err)
+	}
+	lease.unlock()
+
+	if err := recoverInterruptedSwitches(); err != nil {
+		t.Fatalf("recoverInterruptedSwitches failed: %v", err)
+	}
+	assertLive(t, map[string]string{provider.Name: "work"})
+	if err := checkProviderIdle(provider.Name); err != nil {
+		t.Errorf("Expected the provider to be idle, got %v", err)
+	}
+	leasePath, _ := getLeasePath(provider.Name)
+	if _, err := os.Stat(leasePath); !os.IsNotExist(err) {
+		t.Errorf("Expected the lease to be removed, got %v", err)
+	}
+}
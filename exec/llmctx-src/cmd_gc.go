@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var gcCmd = &cobra.Command{
+	Use:   "gc [provider_name]",
+	Short: "Delete stored versions according to retention settings",
+	Long: `Delete stored versions of all providers (or only the given one) according to
+their retention settings (see "llmctx config --provider"):
+
+  keep-auto    the oldest auto-snapshots beyond the limit are deleted
+  max-age      auto-snapshots last saved longer ago than the limit are deleted
+  max-size     the oldest auto-snapshots are deleted while all stored versions
+               together are larger than the limit
+  prune-named  max-age and max-size delete versions saved by name as well,
+               auto-snapshots first
+
+The active version, pinned versions and versions used by profiles are never
+deleted. Versions saved before metadata was recorded have no known age and are
+only deleted for size, with prune-named.
+
+Finally, stored file contents no longer used by any version, including those of
+versions deleted by remove-version or remove-provider --purge, are deleted.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runGC,
+}
+
+var gcDryRun bool
+
+func init() {
+	gcCmd.Flags().BoolVar(&gcDryRun, "dry-run", false, "Only report what would be deleted")
+	rootCmd.AddCommand(gcCmd)
+}
+
+// gcResult is the structured output of gc
+type gcResult struct {
+	DryRun         bool          `json:"dry_run"`
+	Removed        []gcCandidate `json:"removed"`
+	ObjectsRemoved int           `json:"objects_removed"`
+	Reclaimed      int64         `json:"reclaimed"` // bytes freed in the object store
+}
+
+func runGC(cmd *cobra.Command, args []string) error {
+	if !gcDryRun {
+		unlock, err := lockProviders()
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+
+	// Load providers config
+	config, err := loadProviders()
+	if err != nil {
+		return fmt.Errorf("failed to load providers: %w", err)
+	}
+
+	var providerNames []string
+	if len(args) == 1 {
+		if _, exists := config.Providers[args[0]]; !exists {
+			return fmt.Errorf("provider '%s' not found", args[0])
+		}
+		providerNames = []string{args[0]}
+	} else {
+		for name := range config.Providers {
+			providerNames = append(providerNames, name)
+		}
+		sort.Strings(providerNames)
+	}
+
+	result := gcResult{DryRun: gcDryRun, Removed: []gcCandidate{}}
+	removed := make(map[string][]string)
+	now := time.Now()
+	for _, name := range providerNames {
+		planned, err := planRetention(config.retentionView(name), config.settings(), now)
+		if err != nil {
+			return fmt.Errorf("failed to apply retention settings of '%s': %w", name, err)
+		}
+		if len(planned) == 0 {
+			continue
+		}
+
+		if !gcDryRun {
+			names := make([]string, len(planned))
+			for i, candidate := range planned {
+				names[i] = candidate.Version
+			}
+			deleted, err := removeVersions(config, name, names)
+			planned = planned[:len(deleted)]
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, candidate := range planned {
+			result.Removed = append(result.Removed, candidate)
+			removed[name] = append(removed[name], candidate.Version)
+		}
+	}
+
+	// A dry run treats the planned versions as deleted to report what would be freed
+	result.ObjectsRemoved, result.Reclaimed, err = sweepObjects(removed, gcDryRun)
+	if err != nil {
+		return fmt.Errorf("failed to delete unused objects: %w", err)
+	}
+
+	return render(result, func() {
+		verb, total := "Deleted", "Reclaimed"
+		if gcDryRun {
+			verb, total = "Would delete", "Would reclaim"
+		}
+		if len(result.Removed) == 0 && result.ObjectsRemoved == 0 {
+			fmt.Println("Nothing to delete.")
+			return
+		}
+		for _, candidate := range result.Removed {
+			fmt.Printf("%s version '%s' of '%s' (%s, %s)\n", verb, candidate.Version, candidate.Provider, candidate.Reason, formatSize(candidate.Size))
+		}
+		fmt.Printf("%s %s in %d unused object(s)\n", total, formatSize(result.Reclaimed), result.ObjectsRemoved)
+	})
+}
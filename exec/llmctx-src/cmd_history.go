@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history [provider_name]",
+	Short: "Show the log of switches, saves and deletions",
+	Long: `Show the append-only log of every version switch, save, rename and deletion,
+oldest first, for all providers or only the given one.
+
+The log is kept in ~/.llmctx/history.jsonl. A provider's history includes the
+events recorded under names it was renamed from.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runHistory,
+}
+
+var historyLimit int
+
+func init() {
+	historyCmd.Flags().IntVarP(&historyLimit, "limit", "n", 0, "Only show the most recent events (0 shows all)")
+	rootCmd.AddCommand(historyCmd)
+}
+
+// historyResult is the structured output of history
+type historyResult struct {
+	Events []historyEvent `json:"events"`
+}
+
+func runHistory(cmd *cobra.Command, args []string) error {
+	events, err := loadHistory()
+	if err != nil {
+		return err
+	}
+
+	// Removed providers keep their history, so the name is not checked against providers.json
+	if len(args) == 1 {
+		events = eventsForProvider(events, args[0])
+	}
+	if historyLimit > 0 && len(events) > historyLimit {
+		events = events[len(events)-historyLimit:]
+	}
+
+	return render(historyResult{Events: events}, func() {
+		if len(events) == 0 {
+			fmt.Println("No history recorded.")
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "TIME\tACTION\tPROVIDER\tDETAILS")
+		for _, event := range events {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", event.Time.Local().Format("2006-01-02 15:04:05"), event.Action, event.Provider, describeEvent(event))
+		}
+		w.Flush()
+	})
+}
+
+// describeEvent summarizes what an event changed
+func describeEvent(event historyEvent) string {
+	switch event.Action {
+	case historySetVersion, historyUndo:
+		if event.PreviousVersion == "" {
+			return event.Version
+		}
+		return fmt.Sprintf("%s -> %s", event.PreviousVersion, event.Version)
+	case historyRenameVersion:
+		return fmt.Sprintf("%s -> %s", event.Version, event.NewName)
+	case historyRenameProvider:
+		return fmt.Sprintf("-> %s", event.NewName)
+	case historyRestore:
+		return fmt.Sprintf("%s@%d", event.Version, event.Revision)
+	}
+	return event.Version
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"sort"
+	"strings"
 
 	"github.com/spf13/cobra"
 )
@@ -19,17 +20,37 @@ func init() {
 	rootCmd.AddCommand(listCmd)
 }
 
+// listResult is the structured output of list
+type listResult struct {
+	Providers []listedProvider `json:"providers"`
+
+	// ActiveProfiles are the profiles whose providers are all at the profile's version
+	ActiveProfiles []string `json:"active_profiles,omitempty"`
+	hasProfiles    bool
+}
+
+// listedProvider describes one provider and its stored versions
+type listedProvider struct {
+	Name           string   `json:"name"`
+	OriginalPath   string   `json:"original_path"`
+	Type           string   `json:"type"`
+	CurrentVersion string   `json:"current_version"`
+	EnvVar         string   `json:"env_var,omitempty"`
+	Activation     string   `json:"activation"`
+	Versions       []string `json:"versions"`
+	VersionsError  string   `json:"versions_error,omitempty"`
+	Pinned         []string `json:"pinned,omitempty"`
+
+	// VersionMetadata holds the recorded metadata of versions that have any
+	VersionMetadata map[string]VersionMeta `json:"version_metadata,omitempty"`
+}
+
 func runList(cmd *cobra.Command, args []string) error {
 	config, err := loadProviders()
 	if err != nil {
 		return fmt.Errorf("failed to load providers: %w", err)
 	}
 
-	if len(config.Providers) == 0 {
-		fmt.Println("No providers configured.")
-		return nil
-	}
-
 	// Sort provider names for consistent output
 	var providerNames []string
 	for name := range config.Providers {
@@ -37,26 +58,79 @@ func runList(cmd *cobra.Command, args []string) error {
 	}
 	sort.Strings(providerNames)
 
+	result := listResult{
+		Providers:      []listedProvider{},
+		ActiveProfiles: config.activeProfiles(),
+		hasProfiles:    len(config.Profiles) > 0,
+	}
 	for _, name := range providerNames {
 		provider := config.Providers[name]
-		fmt.Printf("Provider: %s\n", provider.Name)
-		fmt.Printf("  Original Path: %s\n", provider.OriginalPath)
-		fmt.Printf("  Type: %s\n", provider.Type)
-		fmt.Printf("  Current Active Version: %s\n", provider.CurrentVersion)
+		listed := listedProvider{
+			Name:           provider.Name,
+			OriginalPath:   provider.OriginalPath,
+			Type:           provider.Type,
+			CurrentVersion: provider.CurrentVersion,
+			EnvVar:         provider.EnvVar,
+			Activation:     provider.activation(),
+			Versions:       []string{},
+			Pinned:         provider.Pinned,
+
+			VersionMetadata: provider.Versions,
+		}
 
 		// List available versions
 		versions, err := getAvailableVersions(provider.Name)
 		if err != nil {
-			fmt.Printf("  Available Versions: (error reading versions: %v)\n", err)
-		} else if len(versions) == 0 {
-			fmt.Printf("  Available Versions: (none)\n")
-		} else {
-			fmt.Printf("  Available Versions: %v\n", versions)
+			listed.VersionsError = err.Error()
+		} else if len(versions) > 0 {
+			listed.Versions = versions
 		}
-		fmt.Println()
+
+		result.Providers = append(result.Providers, listed)
 	}
 
-	return nil
+	return render(result, func() {
+		if len(result.Providers) == 0 {
+			fmt.Println("No providers configured.")
+			return
+		}
+
+		if len(result.ActiveProfiles) > 0 {
+			fmt.Printf("Active Profile: %s\n\n", strings.Join(result.ActiveProfiles, ", "))
+		} else if result.hasProfiles {
+			fmt.Printf("Active Profile: (none)\n\n")
+		}
+
+		for _, provider := range result.Providers {
+			fmt.Printf("Provider: %s\n", provider.Name)
+			fmt.Printf("  Original Path: %s\n", provider.OriginalPath)
+			fmt.Printf("  Type: %s\n", provider.Type)
+			fmt.Printf("  Current Active Version: %s\n", provider.CurrentVersion)
+			if provider.EnvVar != "" {
+				fmt.Printf("  Environment Variable: %s\n", provider.EnvVar)
+			}
+			if provider.Activation != activationCopy {
+				fmt.Printf("  Activation: %s\n", provider.Activation)
+			}
+
+			if provider.VersionsError != "" {
+				fmt.Printf("  Available Versions: (error reading versions: %s)\n", provider.VersionsError)
+			} else if len(provider.Versions) == 0 {
+				fmt.Printf("  Available Versions: (none)\n")
+			} else {
+				fmt.Printf("  Available Versions: %v\n", provider.Versions)
+				if len(provider.Pinned) > 0 {
+					fmt.Printf("  Pinned Versions: %v\n", provider.Pinned)
+				}
+				for _, version := range provider.Versions {
+					if meta, ok := provider.VersionMetadata[version]; ok {
+						fmt.Printf("    %s: %s\n", version, summarizeVersionMeta(meta))
+					}
+				}
+			}
+			fmt.Println()
+		}
+	})
 }
 
 // getAvailableVersions returns a sorted list of available versions for a provider
@@ -76,13 +150,14 @@ func getAvailableVersions(providerName string) ([]string, error) {
 		return nil, fmt.Errorf("failed to read version directory: %w", err)
 	}
 
+	// Each version is a manifest file; dot files are markers and temporary files
 	var versions []string
 	for _, entry := range entries {
-		if entry.IsDir() || entry.Type().IsRegular() {
+		if entry.Type().IsRegular() && !strings.HasPrefix(entry.Name(), ".") {
 			versions = append(versions, entry.Name())
 		}
 	}
 
 	sort.Strings(versions)
 	return versions, nil
-}
\ No newline at end of file
+}
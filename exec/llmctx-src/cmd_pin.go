@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+
+	"github.com/spf13/cobra"
+)
+
+var pinCmd = &cobra.Command{
+	Use:   "pin <provider_name> <version_name>",
+	Short: "Protect a stored version from garbage collection",
+	Long:  `Protect a stored version from being deleted by gc or by the auto-snapshot limit.`,
+	Args:  cobra.ExactArgs(2),
+	RunE:  runPin,
+}
+
+func init() {
+	rootCmd.AddCommand(pinCmd)
+}
+
+func runPin(cmd *cobra.Command, args []string) error {
+	return setPinned(args[0], args[1], true)
+}
+
+// setPinned pins or unpins a stored version of a provider
+func setPinned(providerName, versionName string, pinned bool) error {
+	unlock, err := lockProviders()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// Load providers config
+	config, err := loadProviders()
+	if err != nil {
+		return fmt.Errorf("failed to load providers: %w", err)
+	}
+
+	// Check if provider exists
+	provider, exists := config.Providers[providerName]
+	if !exists {
+		return fmt.Errorf("provider '%s' not found", providerName)
+	}
+
+	if pinned {
+		versionPath, err := getVersionPath(providerName, versionName)
+		if err != nil {
+			return fmt.Errorf("failed to get version path: %w", err)
+		}
+		if _, err := os.Lstat(versionPath); os.IsNotExist(err) {
+			return fmt.Errorf("version '%s' not found for provider '%s'", versionName, providerName)
+		}
+		if !provider.isPinned(versionName) {
+			provider.Pinned = append(provider.Pinned, versionName)
+			sort.Strings(provider.Pinned)
+		}
+	} else {
+		if !provider.isPinned(versionName) {
+			return fmt.Errorf("version '%s' of '%s' is not pinned", versionName, providerName)
+		}
+		provider.Pinned = slices.DeleteFunc(provider.Pinned, func(name string) bool { return name == versionName })
+	}
+
+	config.Providers[providerName] = provider
+	if err := config.saveProviders(); err != nil {
+		return fmt.Errorf("failed to save providers config: %w", err)
+	}
+
+	action, verb := "pin", "pinned"
+	if !pinned {
+		action, verb = "unpin", "unpinned"
+	}
+	return renderAction(actionResult{
+		Action:   action,
+		Provider: providerName,
+		Version:  versionName,
+		Message:  fmt.Sprintf("Successfully %s version '%s' of '%s'", verb, versionName, providerName),
+	})
+}
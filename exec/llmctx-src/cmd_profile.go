@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var profileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "Manage profiles that switch several providers at once",
+	Long: `Manage profiles: named sets of provider versions, such as a "work" profile that
+switches claude, gh and aws to their work accounts.
+
+"llmctx profile use <name>" switches every provider of the profile as a single
+transaction: if any of them fails, the ones already switched are put back.`,
+}
+
+var profileCreateCmd = &cobra.Command{
+	Use:   "create <profile_name> <provider>=<version>...",
+	Short: "Create a profile",
+	Long: `Create a profile switching each given provider to the given stored version, e.g.
+
+  llmctx profile create work claude=work gh=work aws=corp`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: runProfileCreate,
+}
+
+var profileDeleteCmd = &cobra.Command{
+	Use:   "delete <profile_name>",
+	Short: "Delete a profile",
+	Long:  `Delete a profile. Its providers and stored versions are not changed.`,
+	Args:  cobra.ExactArgs(1),
+	RunE:  runProfileDelete,
+}
+
+var profileListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List profiles and whether they are active",
+	Long: `List profiles with their providers and versions.
+
+A profile is active when every one of its providers is at the profile's version,
+and partial when only some are.`,
+	Args: cobra.NoArgs,
+	RunE: runProfileList,
+}
+
+func init() {
+	profileCmd.AddCommand(profileCreateCmd, profileDeleteCmd, profileListCmd)
+	rootCmd.AddCommand(profileCmd)
+}
+
+// listedProfile describes one profile in the structured output of profile list
+type listedProfile struct {
+	Name     string            `json:"name"`
+	Status   string            `json:"status"` // "active", "partial" or "inactive"
+	Versions map[string]string `json:"versions"`
+
+	// Current holds the current version of members not at the profile's version
+	Current map[string]string `json:"current,omitempty"`
+}
+
+// profileListResult is the structured output of profile list
+type profileListResult struct {
+	Profiles []listedProfile `json:"profiles"`
+}
+
+func runProfileCreate(cmd *cobra.Command, args []string) error {
+	profileName := args[0]
+	if err := validateProfileName(profileName); err != nil {
+		return err
+	}
+	members, err := parseProfileMembers(args[1:])
+	if err != nil {
+		return err
+	}
+
+	unlock, err := lockProviders()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// Load providers config
+	config, err := loadProviders()
+	if err != nil {
+		return fmt.Errorf("failed to load providers: %w", err)
+	}
+
+	if _, exists := config.Profiles[profileName]; exists {
+		return fmt.Errorf("profile '%s' already exists; delete it first to redefine it", profileName)
+	}
+
+	for _, providerName := range slices.Sorted(maps.Keys(members)) {
+		if _, exists := config.Providers[providerName]; !exists {
+			return fmt.Errorf("provider '%s' not found", providerName)
+		}
+		if _, err := loadVersion(providerName, members[providerName]); err != nil {
+			return err
+		}
+	}
+
+	if config.Profiles == nil {
+		config.Profiles = make(map[string]Profile)
+	}
+	config.Profiles[profileName] = Profile{Versions: members}
+	if err := config.saveProviders(); err != nil {
+		return fmt.Errorf("failed to save providers config: %w", err)
+	}
+
+	return renderAction(actionResult{
+		Action:  "profile-create",
+		Profile: profileName,
+		Message: fmt.Sprintf("Successfully created profile '%s' with %d provider(s)", profileName, len(members)),
+	})
+}
+
+func runProfileDelete(cmd *cobra.Command, args []string) error {
+	profileName := args[0]
+
+	unlock, err := lockProviders()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// Load providers config
+	config, err := loadProviders()
+	if err != nil {
+		return fmt.Errorf("failed to load providers: %w", err)
+	}
+
+	if _, exists := config.Profiles[profileName]; !exists {
+		return fmt.Errorf("profile '%s' not found", profileName)
+	}
+	delete(config.Profiles, profileName)
+	if err := config.saveProviders(); err != nil {
+		return fmt.Errorf("failed to save providers config: %w", err)
+	}
+
+	return renderAction(actionResult{
+		Action:  "profile-delete",
+		Profile: profileName,
+		Message: fmt.Sprintf("Successfully deleted profile '%s'", profileName),
+	})
+}
+
+func runProfileList(cmd *cobra.Command, args []string) error {
+	config, err := loadProviders()
+	if err != nil {
+		return fmt.Errorf("failed to load providers: %w", err)
+	}
+
+	result := profileListResult{Profiles: []listedProfile{}}
+	for _, name := range slices.Sorted(maps.Keys(config.Profiles)) {
+		profile := config.Profiles[name]
+		listed := listedProfile{Name: name, Status: profile.status(config), Versions: profile.Versions}
+		for providerName, versionName := range profile.Versions {
+			if current := config.Providers[providerName].CurrentVersion; current != versionName {
+				if listed.Current == nil {
+					listed.Current = make(map[string]string)
+				}
+				listed.Current[providerName] = current
+			}
+		}
+		result.Profiles = append(result.Profiles, listed)
+	}
+
+	return render(result, func() {
+		if len(result.Profiles) == 0 {
+			fmt.Println("No profiles configured.")
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "PROFILE\tSTATUS\tPROVIDERS")
+		for _, profile := range result.Profiles {
+			var members []string
+			for _, providerName := range slices.Sorted(maps.Keys(profile.Versions)) {
+				member := fmt.Sprintf("%s=%s", providerName, profile.Versions[providerName])
+				if current, ok := profile.Current[providerName]; ok {
+					if current == "" {
+						current = "none"
+					}
+					member += fmt.Sprintf(" (at %s)", current)
+				}
+				members = append(members, member)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", profile.Name, profile.Status, strings.Join(members, ", "))
+		}
+		w.Flush()
+	})
+}
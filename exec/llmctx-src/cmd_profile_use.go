@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var profileUseCmd = &cobra.Command{
+	Use:   "use <profile_name>",
+	Short: "Switch every provider of a profile to its version",
+	Long: `Switch every provider of a profile to the profile's version as one transaction.
+
+Every provider is checked before anything is switched. The versions are then
+staged and swapped in one provider at a time; if any of them fails, the providers
+already switched are put back, so a failure never leaves a mix of profiles.
+If llmctx is interrupted, the next invocation completes the switch of all the
+providers or rolls all of them back.
+
+Providers already at the profile's version with unchanged content are left alone.
+As with set-version, providers whose current state is not saved in any version
+stop the switch unless --force is given, and with --autosave (or the autosave
+setting) that state is saved as an auto-snapshot first.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runProfileUse,
+}
+
+var (
+	profileUseForce    bool
+	profileUseAutosave bool
+)
+
+func init() {
+	profileUseCmd.Flags().BoolVar(&profileUseForce, "force", false, "Switch even if the current state of a provider is not backed up")
+	profileUseCmd.Flags().BoolVar(&profileUseAutosave, "autosave", false, "Save unbacked current state as auto-snapshots before switching (default from the autosave setting)")
+	profileCmd.AddCommand(profileUseCmd)
+}
+
+// profileSwitch is the outcome for one provider of profile use
+type profileSwitch struct {
+	Provider        string `json:"provider"`
+	Version         string `json:"version"`
+	PreviousVersion string `json:"previous_version,omitempty"`
+	Snapshot        string `json:"snapshot,omitempty"` // auto-snapshot taken before switching
+	Switched        bool   `json:"switched"`           // false if the provider already was at the version
+}
+
+// profileUseResult is the structured output of profile use
+type profileUseResult struct {
+	Profile   string          `json:"profile"`
+	Providers []profileSwitch `json:"providers"`
+}
+
+func runProfileUse(cmd *cobra.Command, args []string) error {
+	profileName := args[0]
+
+	// Hold the lock until every provider has been switched
+	unlock, err := lockProviders()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// Load providers config
+	config, err := loadProviders()
+	if err != nil {
+		return fmt.Errorf("failed to load providers: %w", err)
+	}
+
+	profile, exists := config.Profiles[profileName]
+	if !exists {
+		return fmt.Errorf("profile '%s' not found", profileName)
+	}
+
+	// An explicit --autosave or --autosave=false overrides the configured default
+	autosave := config.settings().Autosave
+	if cmd.Flags().Changed("autosave") {
+		autosave = profileUseAutosave
+	}
+
+	// Check every provider before switching any of them
+	result := profileUseResult{Profile: profileName, Providers: []profileSwitch{}}
+	var unbacked []string
+	for _, providerName := range profile.members() {
+		versionName := profile.Versions[providerName]
+		provider, exists := config.Providers[providerName]
+		if !exists {
+			return fmt.Errorf("provider '%s' of profile '%s' not found", providerName, profileName)
+		}
+		if err := checkProviderIdle(providerName); err != nil {
+			return err
+		}
+		if _, err := loadVersion(providerName, versionName); err != nil {
+			return err
+		}
+		if _, err := os.Stat(provider.OriginalPath); os.IsNotExist(err) {
+			return fmt.Errorf("original path '%s' of '%s' no longer exists", provider.OriginalPath, providerName)
+		}
+
+		if _, err := syncLinkedVersion(config, &provider); err != nil {
+			return err
+		}
+		if _, err := captureOnLeave(config, &provider); err != nil {
+			return err
+		}
+		matches, err := findMatchingVersions(provider)
+		if err != nil {
+			return fmt.Errorf("failed to check if current state of '%s' is backed up: %w", providerName, err)
+		}
+
+		outcome := profileSwitch{Provider: providerName, Version: versionName, PreviousVersion: provider.CurrentVersion}
+		if provider.CurrentVersion != versionName || !slices.Contains(matches, versionName) {
+			outcome.Switched = true
+			if len(matches) == 0 {
+				if !autosave && !profileUseForce {
+					return fmt.Errorf("current state of '%s' is not backed up in any version. Use 'llmctx add-version %s <version_name>' to back it up first, or use --force to proceed anyway", provider.OriginalPath, providerName)
+				}
+				if autosave {
+					unbacked = append(unbacked, providerName)
+				}
+			}
+		}
+		result.Providers = append(result.Providers, outcome)
+	}
+
+	// Save unbacked state only once every provider is known to be switchable
+	for i, outcome := range result.Providers {
+		if !slices.Contains(unbacked, outcome.Provider) {
+			continue
+		}
+		provider := config.Providers[outcome.Provider]
+		snapshot, err := takeAutoSnapshot(config, &provider, fmt.Sprintf("saved before switching to profile '%s'", profileName))
+		if err != nil {
+			return err
+		}
+		result.Providers[i].Snapshot = snapshot
+	}
+
+	targets := make(map[string]string)
+	for _, outcome := range result.Providers {
+		if outcome.Switched {
+			targets[outcome.Provider] = outcome.Version
+		}
+	}
+	if len(targets) > 0 {
+		if err := switchVersions(config, targets); err != nil {
+			return fmt.Errorf("failed to switch to profile '%s', no provider was changed: %w", profileName, err)
+		}
+	}
+
+	var lines []string
+	for _, outcome := range result.Providers {
+		if !outcome.Switched {
+			lines = append(lines, fmt.Sprintf("'%s' is already at version '%s'", outcome.Provider, outcome.Version))
+			continue
+		}
+
+		// Going back with "-" or undo should restore the state that was just saved
+		previous := outcome.PreviousVersion
+		if outcome.Snapshot != "" {
+			previous = outcome.Snapshot
+			lines = append(lines, fmt.Sprintf("Saved unbacked state of '%s' as '%s'", outcome.Provider, outcome.Snapshot))
+		}
+		recordHistory(historyEvent{
+			Action:          historySetVersion,
+			Provider:        outcome.Provider,
+			Version:         outcome.Version,
+			PreviousVersion: previous,
+		})
+		lines = append(lines, fmt.Sprintf("Switched '%s' to version '%s'", outcome.Provider, outcome.Version))
+
+		if outcome.Snapshot != "" {
+			// The switch already succeeded, so failing to clean up only warns
+			removed, err := pruneAutoSnapshots(config, outcome.Provider)
+			for _, name := range removed {
+				lines = append(lines, fmt.Sprintf("Deleted old auto-snapshot '%s' of '%s'", name, outcome.Provider))
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to delete old auto-snapshots of '%s': %v\n", outcome.Provider, err)
+			}
+		}
+	}
+
+	return render(result, func() {
+		fmt.Println(strings.Join(lines, "\n"))
+		fmt.Printf("Profile '%s' is now active\n", profileName)
+	})
+}
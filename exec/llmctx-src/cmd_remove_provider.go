@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var removeProviderCmd = &cobra.Command{
+	Use:   "remove-provider <provider_name>",
+	Short: "Stop managing a configuration file or directory",
+	Long: `Stop managing a configuration file or directory.
+
+By default the stored versions and the file or directory at the original path
+are left in place. Use --purge to delete the stored versions and --remove-live to
+delete the original path as well. Removing the original path requires its current
+state to be kept in a stored version, unless --force is given.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runRemoveProvider,
+}
+
+var (
+	removeProviderPurge      bool
+	removeProviderRemoveLive bool
+	removeProviderForce      bool
+)
+
+func init() {
+	removeProviderCmd.Flags().BoolVar(&removeProviderPurge, "purge", false, "Delete all stored versions of the provider")
+	removeProviderCmd.Flags().BoolVar(&removeProviderRemoveLive, "remove-live", false, "Delete the file or directory at the original path")
+	removeProviderCmd.Flags().BoolVar(&removeProviderForce, "force", false, "Delete the original path even if its current state is not kept in a stored version")
+	rootCmd.AddCommand(removeProviderCmd)
+}
+
+func runRemoveProvider(cmd *cobra.Command, args []string) error {
+	providerName := args[0]
+
+	unlock, err := lockProviders()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// Load providers config
+	config, err := loadProviders()
+	if err != nil {
+		return fmt.Errorf("failed to load providers: %w", err)
+	}
+
+	// Check if provider exists
+	provider, exists := config.Providers[providerName]
+	if !exists {
+		return fmt.Errorf("provider '%s' not found", providerName)
+	}
+	if err := checkProviderIdle(providerName); err != nil {
+		return err
+	}
+	if _, err := syncLinkedVersion(config, &provider); err != nil {
+		return err
+	}
+
+	providerDir, err := getProviderDir(providerName)
+	if err != nil {
+		return err
+	}
+
+	_, statErr := os.Lstat(provider.OriginalPath)
+	liveExists := statErr == nil
+
+	// Deleting the live state is only safe if a kept version still holds it
+	if removeProviderRemoveLive && liveExists && !removeProviderForce {
+		if removeProviderPurge {
+			return fmt.Errorf("--remove-live with --purge deletes every copy of '%s'; use --force to confirm", provider.OriginalPath)
+		}
+		backedUp, err := isCurrentStateBackedUp(provider)
+		if err != nil {
+			return fmt.Errorf("failed to check if current state is backed up: %w", err)
+		}
+		if !backedUp {
+			return fmt.Errorf("current state of '%s' is not backed up in any version; use --force to delete it anyway", provider.OriginalPath)
+		}
+	}
+
+	// A link into ~/.llmctx/active left behind would dangle once the provider is gone
+	if provider.isLinked() && !removeProviderRemoveLive {
+		journal, err := unlinkWorkingCopy(provider)
+		if err != nil {
+			return fmt.Errorf("failed to replace the link at '%s' with a copy: %w", provider.OriginalPath, err)
+		}
+		if err := finishSwitch(journal); err != nil {
+			return err
+		}
+	}
+
+	// Unregister first so a failure below never leaves providers.json pointing at deleted storage
+	delete(config.Providers, providerName)
+	if err := config.saveProviders(); err != nil {
+		return fmt.Errorf("failed to save providers config: %w", err)
+	}
+	recordHistory(historyEvent{Action: historyRemoveProvider, Provider: providerName})
+
+	if removeProviderPurge {
+		if err := removeAll(providerDir); err != nil {
+			return fmt.Errorf("failed to remove stored versions: %w", err)
+		}
+	}
+
+	if removeProviderRemoveLive && liveExists {
+		if err := removeWorkingCopy(provider.OriginalPath); err != nil {
+			return fmt.Errorf("failed to remove working copy: %w", err)
+		}
+		if err := removeAll(provider.OriginalPath); err != nil {
+			return fmt.Errorf("failed to remove '%s': %w", provider.OriginalPath, err)
+		}
+	}
+
+	return renderAction(actionResult{
+		Action:   "remove-provider",
+		Provider: providerName,
+		Message:  fmt.Sprintf("Successfully removed provider '%s'", providerName),
+	})
+}
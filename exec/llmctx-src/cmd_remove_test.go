@@ -0,0 +1,98 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestRemoveVersion(t *testing.T) {
+	_, _, provider := setupSwitchTest(t)
+	defer func() { removeVersionForce = false }()
+
+	t.Run("active version requires force", func(t *testing.T) {
+		removeVersionForce = false
+		if err := runRemoveVersion(removeVersionCmd, []string{provider.Name, "work"}); err == nil {
+			t.Error("Expected error when removing the active version")
+		}
+	})
+
+	t.Run("only backup of live state requires force", func(t *testing.T) {
+		// The live state now only matches "personal"
+		if err := os.WriteFile(provider.OriginalPath, []byte("personal"), 0644); err != nil {
+			t.Fatalf("Failed to modify live file: %v", err)
+		}
+		removeVersionForce = false
+		if err := runRemoveVersion(removeVersionCmd, []string{provider.Name, "personal"}); err == nil {
+			t.Error("Expected error when removing the only backup of the live state")
+		}
+	})
+
+	t.Run("forced removal of the active version clears current_version", func(t *testing.T) {
+		removeVersionForce = true
+		if err := runRemoveVersion(removeVersionCmd, []string{provider.Name, "work"}); err != nil {
+			t.Fatalf("runRemoveVersion failed: %v", err)
+		}
+
+		versions, err := getAvailableVersions(provider.Name)
+		if err != nil {
+			t.Fatalf("getAvailableVersions failed: %v", err)
+		}
+		if len(versions) != 1 || versions[0] != "personal" {
+			t.Errorf("Versions after removal = %v, want [personal]", versions)
+		}
+
+		config, err := loadProviders()
+		if err != nil {
+			t.Fatalf("loadProviders failed: %v", err)
+		}
+		if current := config.Providers[provider.Name].CurrentVersion; current != "" {
+			t.Errorf("CurrentVersion = %q, want empty", current)
+		}
+	})
+}
+
+func TestRemoveProvider(t *testing.T) {
+	_, _, provider := setupSwitchTest(t)
+	resetFlags := func() {
+		removeProviderPurge = false
+		removeProviderRemoveLive = false
+		removeProviderForce = false
+	}
+	defer resetFlags()
+
+	t.Run("removing live state requires a backup", func(t *testing.T) {
+		resetFlags()
+		if err := os.WriteFile(provider.OriginalPath, []byte("unsaved"), 0644); err != nil {
+			t.Fatalf("Failed to modify live file: %v", err)
+		}
+		removeProviderRemoveLive = true
+		if err := runRemoveProvider(removeProviderCmd, []string{provider.Name}); err == nil {
+			t.Error("Expected error when deleting unbacked live state")
+		}
+	})
+
+	t.Run("purge removes storage but keeps the live file", func(t *testing.T) {
+		resetFlags()
+		removeProviderPurge = true
+		if err := runRemoveProvider(removeProviderCmd, []string{provider.Name}); err != nil {
+			t.Fatalf("runRemoveProvider failed: %v", err)
+		}
+
+		config, err := loadProviders()
+		if err != nil {
+			t.Fatalf("loadProviders failed: %v", err)
+		}
+		if _, exists := config.Providers[provider.Name]; exists {
+			t.Error("Expected provider to be unregistered")
+		}
+
+		providerDir, err := getProviderDir(provider.Name)
+		if err != nil {
+			t.Fatalf("getProviderDir failed: %v", err)
+		}
+		if _, err := os.Stat(providerDir); !os.IsNotExist(err) {
+			t.Error("Expected stored versions to be purged")
+		}
+		assertFileContent(t, provider.OriginalPath, "unsaved")
+	})
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var removeVersionCmd = &cobra.Command{
+	Use:   "remove-version <provider_name> <version_name>",
+	Short: "Delete a stored version of a provider",
+	Long: `Delete a stored version of a provider.
+
+The active version, a version used by a profile, or a version that holds the
+only copy of the current state at the original path, is only removed with --force. Removing the active version
+leaves the provider without a current version.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runRemoveVersion,
+}
+
+var removeVersionForce bool
+
+func init() {
+	removeVersionCmd.Flags().BoolVar(&removeVersionForce, "force", false, "Remove the version even if it is active, used by a profile or holds the only copy of the current state")
+	rootCmd.AddCommand(removeVersionCmd)
+}
+
+func runRemoveVersion(cmd *cobra.Command, args []string) error {
+	providerName := args[0]
+	versionName := args[1]
+
+	unlock, err := lockProviders()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// Load providers config
+	config, err := loadProviders()
+	if err != nil {
+		return fmt.Errorf("failed to load providers: %w", err)
+	}
+
+	// Check if provider exists
+	provider, exists := config.Providers[providerName]
+	if !exists {
+		return fmt.Errorf("provider '%s' not found", providerName)
+	}
+	if err := checkProviderIdle(providerName); err != nil {
+		return err
+	}
+
+	// Check if version exists
+	versionPath, err := getVersionPath(providerName, versionName)
+	if err != nil {
+		return fmt.Errorf("failed to get version path: %w", err)
+	}
+	if _, err := os.Lstat(versionPath); os.IsNotExist(err) {
+		return fmt.Errorf("version '%s' not found for provider '%s'", versionName, providerName)
+	}
+
+	if !removeVersionForce {
+		if provider.CurrentVersion == versionName {
+			return fmt.Errorf("version '%s' is the active version of '%s'; switch to another version first or use --force", versionName, providerName)
+		}
+
+		if profiles := config.profilesUsing(providerName, versionName); len(profiles) > 0 {
+			return fmt.Errorf("version '%s' of '%s' is used by profile(s) %s; delete or change them first or use --force", versionName, providerName, strings.Join(profiles, ", "))
+		}
+
+		onlyCopy, err := isOnlyBackup(provider, versionName)
+		if err != nil {
+			return fmt.Errorf("failed to check if current state is backed up: %w", err)
+		}
+		if onlyCopy {
+			return fmt.Errorf("version '%s' is the only backup of the current state of '%s'; use --force to remove it anyway", versionName, provider.OriginalPath)
+		}
+	}
+
+	if err := removeAll(versionPath); err != nil {
+		return fmt.Errorf("failed to remove version: %w", err)
+	}
+	if err := removeOverlay(providerName, versionName); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to remove the working copy of '%s': %v\n", versionName, err)
+	}
+	if err := removeRevisions(providerName, versionName); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to remove the revisions of '%s': %v\n", versionName, err)
+	}
+
+	// Never leave current_version pointing at a version that no longer exists
+	if provider.CurrentVersion == versionName {
+		provider.CurrentVersion = ""
+	}
+	delete(provider.Versions, versionName)
+	provider.Pinned = slices.DeleteFunc(provider.Pinned, func(name string) bool { return name == versionName })
+
+	config.Providers[providerName] = provider
+	if err := config.saveProviders(); err != nil {
+		return fmt.Errorf("failed to save providers config: %w", err)
+	}
+	recordHistory(historyEvent{Action: historyRemoveVersion, Provider: providerName, Version: versionName})
+
+	return renderAction(actionResult{
+		Action:   "remove-version",
+		Provider: providerName,
+		Version:  versionName,
+		Message:  fmt.Sprintf("Successfully removed version '%s' of '%s'", versionName, providerName),
+	})
+}
+
+// isOnlyBackup reports whether versionName is the only stored version matching
+// the current state at the provider's original path
+func isOnlyBackup(provider Provider, versionName string) (bool, error) {
+	if _, err := os.Stat(provider.OriginalPath); os.IsNotExist(err) {
+		return false, nil
+	}
+
+	matches, err := findMatchingVersions(provider)
+	if err != nil {
+		return false, err
+	}
+	return len(matches) == 1 && matches[0] == versionName, nil
+}
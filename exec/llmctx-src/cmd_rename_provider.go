@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var renameProviderCmd = &cobra.Command{
+	Use:   "rename-provider <old_provider_name> <new_provider_name>",
+	Short: "Rename a managed provider",
+	Long:  `Rename a managed provider, moving its stored versions along with it.`,
+	Args:  cobra.ExactArgs(2),
+	RunE:  runRenameProvider,
+}
+
+func init() {
+	rootCmd.AddCommand(renameProviderCmd)
+}
+
+func runRenameProvider(cmd *cobra.Command, args []string) error {
+	oldName := args[0]
+	newName := args[1]
+
+	if err := validateProviderName(newName); err != nil {
+		return err
+	}
+
+	unlock, err := lockProviders()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// Load providers config
+	config, err := loadProviders()
+	if err != nil {
+		return fmt.Errorf("failed to load providers: %w", err)
+	}
+
+	// Check if provider exists
+	provider, exists := config.Providers[oldName]
+	if !exists {
+		return fmt.Errorf("provider '%s' not found", oldName)
+	}
+	if err := checkProviderIdle(oldName); err != nil {
+		return err
+	}
+
+	if _, exists := config.Providers[newName]; exists {
+		return withExitCode(exitProviderExists, fmt.Errorf("provider '%s' already exists", newName))
+	}
+
+	oldDir, err := getProviderDir(oldName)
+	if err != nil {
+		return err
+	}
+	newDir, err := getProviderDir(newName)
+	if err != nil {
+		return err
+	}
+
+	// Storage left behind by a removed provider would otherwise be adopted silently
+	if _, err := os.Lstat(newDir); err == nil {
+		return fmt.Errorf("storage for '%s' already exists at '%s'", newName, newDir)
+	}
+
+	_, statErr := os.Lstat(oldDir)
+	hasStorage := statErr == nil
+	if hasStorage {
+		if err := os.Rename(oldDir, newDir); err != nil {
+			return fmt.Errorf("failed to move provider storage: %w", err)
+		}
+	}
+
+	provider.Name = newName
+	delete(config.Providers, oldName)
+	config.Providers[newName] = provider
+	config.renameProfileProvider(oldName, newName)
+
+	if err := config.saveProviders(); err != nil {
+		// Put the storage back so it still matches providers.json
+		if hasStorage {
+			os.Rename(newDir, oldDir)
+		}
+		return fmt.Errorf("failed to save providers config: %w", err)
+	}
+	recordHistory(historyEvent{Action: historyRenameProvider, Provider: oldName, NewName: newName})
+
+	return renderAction(actionResult{
+		Action:   "rename-provider",
+		Provider: oldName,
+		NewName:  newName,
+		Message:  fmt.Sprintf("Successfully renamed provider '%s' to '%s'", oldName, newName),
+	})
+}
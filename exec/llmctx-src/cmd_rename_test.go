@@ -0,0 +1,86 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestRenameVersion(t *testing.T) {
+	_, _, provider := setupSwitchTest(t)
+
+	t.Run("rejects collisions", func(t *testing.T) {
+		if err := runRenameVersion(renameVersionCmd, []string{provider.Name, "work", "personal"}); err == nil {
+			t.Error("Expected error when renaming onto an existing version")
+		}
+	})
+
+	t.Run("renames storage and the active version", func(t *testing.T) {
+		if err := runRenameVersion(renameVersionCmd, []string{provider.Name, "work", "corp"}); err != nil {
+			t.Fatalf("runRenameVersion failed: %v", err)
+		}
+
+		versions, err := getAvailableVersions(provider.Name)
+		if err != nil {
+			t.Fatalf("getAvailableVersions failed: %v", err)
+		}
+		if len(versions) != 2 || versions[0] != "corp" || versions[1] != "personal" {
+			t.Errorf("Versions after rename = %v, want [corp personal]", versions)
+		}
+
+		config, err := loadProviders()
+		if err != nil {
+			t.Fatalf("loadProviders failed: %v", err)
+		}
+		if current := config.Providers[provider.Name].CurrentVersion; current != "corp" {
+			t.Errorf("CurrentVersion = %q, want %q", current, "corp")
+		}
+	})
+}
+
+func TestRenameProvider(t *testing.T) {
+	_, config, provider := setupSwitchTest(t)
+
+	other := Provider{Name: "other", OriginalPath: provider.OriginalPath, Type: "file"}
+	config.Providers[other.Name] = other
+	if err := config.saveProviders(); err != nil {
+		t.Fatalf("saveProviders failed: %v", err)
+	}
+
+	t.Run("rejects collisions", func(t *testing.T) {
+		err := runRenameProvider(renameProviderCmd, []string{provider.Name, "other"})
+		if exitCodeFor(err) != exitProviderExists {
+			t.Errorf("Expected 'already exists' error, got: %v", err)
+		}
+	})
+
+	t.Run("moves storage and updates providers.json", func(t *testing.T) {
+		if err := runRenameProvider(renameProviderCmd, []string{provider.Name, "renamed"}); err != nil {
+			t.Fatalf("runRenameProvider failed: %v", err)
+		}
+
+		loaded, err := loadProviders()
+		if err != nil {
+			t.Fatalf("loadProviders failed: %v", err)
+		}
+		renamed, exists := loaded.Providers["renamed"]
+		if !exists || renamed.Name != "renamed" || renamed.CurrentVersion != "work" {
+			t.Errorf("Unexpected renamed provider: %+v", renamed)
+		}
+		if _, exists := loaded.Providers[provider.Name]; exists {
+			t.Error("Expected old provider name to be gone")
+		}
+
+		versions, err := getAvailableVersions("renamed")
+		if err != nil {
+			t.Fatalf("getAvailableVersions failed: %v", err)
+		}
+		if len(versions) != 2 {
+			t.Errorf("Expected versions to move with the provider, got %v", versions)
+		}
+
+		oldDir, _ := getProviderDir(provider.Name)
+		if _, err := os.Stat(oldDir); !os.IsNotExist(err) {
+			t.Error("Expected old storage directory to be gone")
+		}
+	})
+}
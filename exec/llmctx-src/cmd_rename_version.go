@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var renameVersionCmd = &cobra.Command{
+	Use:   "rename-version <provider_name> <old_version_name> <new_version_name>",
+	Short: "Rename a stored version of a provider",
+	Long:  `Rename a stored version of a provider, updating the active version if it is the one being renamed.`,
+	Args:  cobra.ExactArgs(3),
+	RunE:  runRenameVersion,
+}
+
+func init() {
+	rootCmd.AddCommand(renameVersionCmd)
+}
+
+func runRenameVersion(cmd *cobra.Command, args []string) error {
+	providerName := args[0]
+	oldName := args[1]
+	newName := args[2]
+
+	if err := validateVersionName(newName); err != nil {
+		return err
+	}
+
+	unlock, err := lockProviders()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// Load providers config
+	config, err := loadProviders()
+	if err != nil {
+		return fmt.Errorf("failed to load providers: %w", err)
+	}
+
+	// Check if provider exists
+	provider, exists := config.Providers[providerName]
+	if !exists {
+		return fmt.Errorf("provider '%s' not found", providerName)
+	}
+	if err := checkProviderIdle(providerName); err != nil {
+		return err
+	}
+
+	oldPath, err := getVersionPath(providerName, oldName)
+	if err != nil {
+		return fmt.Errorf("failed to get version path: %w", err)
+	}
+	if _, err := os.Lstat(oldPath); os.IsNotExist(err) {
+		return fmt.Errorf("version '%s' not found for provider '%s'", oldName, providerName)
+	}
+
+	newPath, err := getVersionPath(providerName, newName)
+	if err != nil {
+		return fmt.Errorf("failed to get version path: %w", err)
+	}
+	if _, err := os.Lstat(newPath); err == nil {
+		return fmt.Errorf("version '%s' already exists for provider '%s'", newName, providerName)
+	}
+
+	if err := os.Rename(oldPath, newPath); err != nil {
+		return fmt.Errorf("failed to rename version storage: %w", err)
+	}
+
+	if provider.CurrentVersion == oldName {
+		provider.CurrentVersion = newName
+	}
+	if meta, ok := provider.Versions[oldName]; ok {
+		delete(provider.Versions, oldName)
+		provider.Versions[newName] = meta
+	}
+	for i, name := range provider.Pinned {
+		if name == oldName {
+			provider.Pinned[i] = newName
+		}
+	}
+
+	config.renameProfileVersion(providerName, oldName, newName)
+
+	config.Providers[providerName] = provider
+	if err := config.saveProviders(); err != nil {
+		// Put the storage back so it still matches providers.json
+		os.Rename(newPath, oldPath)
+		return fmt.Errorf("failed to save providers config: %w", err)
+	}
+	if err := renameOverlay(providerName, oldName, newName); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to rename the working copy of '%s': %v\n", oldName, err)
+	}
+	if err := renameRevisions(providerName, oldName, newName); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to rename the revisions of '%s': %v\n", oldName, err)
+	}
+	recordHistory(historyEvent{Action: historyRenameVersion, Provider: providerName, Version: oldName, NewName: newName})
+
+	return renderAction(actionResult{
+		Action:   "rename-version",
+		Provider: providerName,
+		Version:  oldName,
+		NewName:  newName,
+		Message:  fmt.Sprintf("Successfully renamed version '%s' of '%s' to '%s'", oldName, providerName, newName),
+	})
+}
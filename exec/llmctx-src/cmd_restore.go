@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore <provider_name> <version_name>@<revision>",
+	Short: "Bring an earlier revision of a version back",
+	Long: `Make an earlier revision of a stored version (see "llmctx revisions") its
+content again. The content the version held is kept as a new revision, so a
+restore can itself be undone by restoring that revision.
+
+When the version is active and the original path still holds its content, the
+restored content is put in place as well. Otherwise the original path is left
+alone.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runRestore,
+}
+
+func init() {
+	rootCmd.AddCommand(restoreCmd)
+}
+
+// parseRevisionSpec splits <version_name>@<revision>
+func parseRevisionSpec(spec string) (string, int, error) {
+	at := strings.LastIndex(spec, "@")
+	if at < 0 {
+		return "", 0, fmt.Errorf("invalid revision '%s': expected <version_name>@<revision>", spec)
+	}
+	revision, err := strconv.Atoi(spec[at+1:])
+	if err != nil || revision < 1 {
+		return "", 0, fmt.Errorf("invalid revision '%s': must be a positive number", spec[at+1:])
+	}
+	return spec[:at], revision, nil
+}
+
+func runRestore(cmd *cobra.Command, args []string) error {
+	providerName := args[0]
+	versionName, revision, err := parseRevisionSpec(args[1])
+	if err != nil {
+		return err
+	}
+
+	unlock, err := lockProviders()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// Load providers config
+	config, err := loadProviders()
+	if err != nil {
+		return fmt.Errorf("failed to load providers: %w", err)
+	}
+
+	// Check if provider exists
+	provider, exists := config.Providers[providerName]
+	if !exists {
+		return fmt.Errorf("provider '%s' not found", providerName)
+	}
+	if err := checkProviderIdle(providerName); err != nil {
+		return err
+	}
+	if _, err := loadVersion(providerName, versionName); err != nil {
+		return err
+	}
+
+	current, err := currentRevision(providerName, versionName)
+	if err != nil {
+		return err
+	}
+	if revision == current {
+		return renderAction(actionResult{
+			Action:   "restore",
+			Provider: providerName,
+			Version:  versionName,
+			Message:  fmt.Sprintf("Version '%s' of '%s' already holds revision %d", versionName, providerName, revision),
+		})
+	}
+	stored, err := loadRevision(providerName, versionName, revision)
+	if err != nil {
+		return err
+	}
+
+	// The live content follows an active version only if nothing would be lost
+	active := provider.CurrentVersion == versionName
+	followLive := false
+	if active {
+		if _, err := syncLinkedVersion(config, &provider); err != nil {
+			return err
+		}
+		if _, err := os.Stat(provider.OriginalPath); err == nil {
+			matches, err := findMatchingVersions(provider)
+			if err != nil {
+				return fmt.Errorf("failed to check current state: %w", err)
+			}
+			followLive = slices.Contains(matches, versionName)
+		}
+	}
+
+	kept, err := keepRevision(provider, versionName, config.settings().revisionLimit())
+	if err != nil {
+		return err
+	}
+	versionPath, err := getVersionPath(providerName, versionName)
+	if err != nil {
+		return fmt.Errorf("failed to get version path: %w", err)
+	}
+	if err := writeVersionManifest(versionPath, &stored.storedVersion); err != nil {
+		return err
+	}
+	if err := provider.recordVersion(versionName, ""); err != nil {
+		return err
+	}
+	// The note belongs to the content, even when the revision had none
+	meta := provider.Versions[versionName]
+	meta.Note = stored.Note
+	provider.Versions[versionName] = meta
+	config.Providers[providerName] = provider
+	if err := config.saveProviders(); err != nil {
+		return fmt.Errorf("failed to save providers config: %w", err)
+	}
+	recordHistory(historyEvent{Action: historyRestore, Provider: providerName, Version: versionName, Revision: revision})
+
+	message := fmt.Sprintf("Successfully restored revision %d of version '%s' of '%s', keeping the content it replaced as revision %d", revision, versionName, providerName, kept)
+	if followLive {
+		journal, err := swapInVersion(provider, versionName, "")
+		if err != nil {
+			return fmt.Errorf("restored the version, but failed to put it in place at '%s': %w", provider.OriginalPath, err)
+		}
+		if err := finishSwitch(journal); err != nil {
+			return err
+		}
+		message += fmt.Sprintf("\nPut the restored content in place at '%s'", provider.OriginalPath)
+	} else if active {
+		message += fmt.Sprintf("\nThe current state of '%s' was not saved in the version and was left alone", provider.OriginalPath)
+	}
+
+	return renderAction(actionResult{
+		Action:   "restore",
+		Provider: providerName,
+		Version:  versionName,
+		Revision: revision,
+		Message:  message,
+	})
+}
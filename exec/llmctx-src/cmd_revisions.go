@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var revisionsCmd = &cobra.Command{
+	Use:   "revisions <provider_name> <version_name>",
+	Short: "List the earlier contents kept for a version",
+	Long: `List the revisions of a stored version, oldest first.
+
+Each time a version is overwritten with different content, by add-version, by
+capture-on-leave or by saving changes made through a symlink, the content it
+held is kept as a revision, numbered from 1. The content the version holds now
+is the newest revision. The number of earlier revisions kept per version is set
+with "llmctx config revision-limit". Bring one back with
+"llmctx restore <provider_name> <version_name>@<revision>".`,
+	Args: cobra.ExactArgs(2),
+	RunE: runRevisions,
+}
+
+func init() {
+	rootCmd.AddCommand(revisionsCmd)
+}
+
+// revisionsResult is the structured output of revisions
+type revisionsResult struct {
+	Provider  string           `json:"provider"`
+	Version   string           `json:"version"`
+	Revisions []listedRevision `json:"revisions"`
+}
+
+// listedRevision describes one revision of a version
+type listedRevision struct {
+	Revision   int       `json:"revision"`
+	Current    bool      `json:"current"`
+	SavedAt    time.Time `json:"saved_at,omitzero"`
+	ReplacedAt time.Time `json:"replaced_at,omitzero"`
+	Note       string    `json:"note,omitempty"`
+	Digest     string    `json:"digest"`
+	Size       int64     `json:"size"`
+}
+
+func runRevisions(cmd *cobra.Command, args []string) error {
+	providerName := args[0]
+	versionName := args[1]
+
+	// Load providers config
+	config, err := loadProviders()
+	if err != nil {
+		return fmt.Errorf("failed to load providers: %w", err)
+	}
+
+	// Check if provider exists
+	provider, exists := config.Providers[providerName]
+	if !exists {
+		return fmt.Errorf("provider '%s' not found", providerName)
+	}
+
+	current, err := versionTree(providerName, versionName)
+	if err != nil {
+		return err
+	}
+	revisions, err := versionRevisions(providerName, versionName)
+	if err != nil {
+		return err
+	}
+
+	result := revisionsResult{Provider: providerName, Version: versionName, Revisions: []listedRevision{}}
+	for _, revision := range revisions {
+		stored, err := loadRevision(providerName, versionName, revision)
+		if err != nil {
+			return err
+		}
+		tree := stored.tree()
+		result.Revisions = append(result.Revisions, listedRevision{
+			Revision:   revision,
+			SavedAt:    stored.SavedAt,
+			ReplacedAt: stored.ReplacedAt,
+			Note:       stored.Note,
+			Digest:     manifestDigest(tree),
+			Size:       manifestSize(tree),
+		})
+	}
+
+	latest := listedRevision{Revision: 1, Current: true, Digest: manifestDigest(current), Size: manifestSize(current)}
+	if len(revisions) > 0 {
+		latest.Revision = revisions[len(revisions)-1] + 1
+	}
+	if meta, ok := provider.Versions[versionName]; ok {
+		latest.SavedAt = meta.UpdatedAt
+		latest.Note = meta.Note
+	}
+	result.Revisions = append(result.Revisions, latest)
+
+	return render(result, func() {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "REVISION\tSAVED\tSIZE\tNOTE")
+		for _, revision := range result.Revisions {
+			number := fmt.Sprintf("%s@%d", result.Version, revision.Revision)
+			if revision.Current {
+				number += " (current)"
+			}
+			saved := "-"
+			if !revision.SavedAt.IsZero() {
+				saved = revision.SavedAt.Local().Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", number, saved, formatSize(revision.Size), revision.Note)
+		}
+		w.Flush()
+	})
+}
@@ -3,8 +3,6 @@ package main
 import (
 	"fmt"
 	"os"
-	"os/exec"
-	"path/filepath"
 
 	"github.com/spf13/cobra"
 )
@@ -12,15 +10,38 @@ import (
 var setVersionCmd = &cobra.Command{
 	Use:   "set-version <provider_name> <version_name>",
 	Short: "Replace the active configuration with a chosen version",
-	Long:  `Replace the active configuration file or directory at its original location with a chosen version from storage.`,
-	Args:  cobra.ExactArgs(2),
-	RunE:  runSetVersion,
+	Long: `Replace the active configuration file or directory at its original location with a chosen version from storage.
+
+The version is staged next to the original location and swapped in with a rename.
+If llmctx is interrupted, the next invocation completes or rolls back the switch.
+
+Use "-" as the version name to switch back to the previously active version.
+
+If the current state is not saved in any version, set-version refuses to switch
+unless --force is given. With --autosave (or "llmctx config autosave true") the
+current state is saved as an auto-snapshot named auto-<UTC time> instead, and
+the oldest auto-snapshots beyond the configured limit are deleted.
+
+For providers with symlink activation (see "llmctx activation"), changes made
+through the link are first saved into the active version, and the link is then
+pointed at a working copy of the chosen version.
+
+With capture-on-leave ("llmctx config --provider <name> capture-on-leave true"),
+a current state that matches no stored version is taken to be changes the tool
+made to the active version, such as a refreshed token. It is saved into the
+active version, whose previous content is kept as a revision, before switching.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runSetVersion,
 }
 
-var forceFlag bool
+var (
+	forceFlag    bool
+	autosaveFlag bool
+)
 
 func init() {
 	setVersionCmd.Flags().BoolVar(&forceFlag, "force", false, "Force the operation even if current state is not backed up")
+	setVersionCmd.Flags().BoolVar(&autosaveFlag, "autosave", false, "Save unbacked current state as an auto-snapshot before switching (default from the autosave setting)")
 	rootCmd.AddCommand(setVersionCmd)
 }
 
@@ -28,6 +49,13 @@ func runSetVersion(cmd *cobra.Command, args []string) error {
 	providerName := args[0]
 	versionName := args[1]
 
+	// Hold the lock until providers.json has been updated
+	unlock, err := lockProviders()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	// Load providers config
 	config, err := loadProviders()
 	if err != nil {
@@ -39,6 +67,17 @@ func runSetVersion(cmd *cobra.Command, args []string) error {
 	if !exists {
 		return fmt.Errorf("provider '%s' not found", providerName)
 	}
+	if err := checkProviderIdle(providerName); err != nil {
+		return err
+	}
+
+	// "-" goes back to the version that was active before the last switch
+	if versionName == "-" {
+		versionName, err = previousVersion(provider)
+		if err != nil {
+			return err
+		}
+	}
 
 	// Check if target version exists
 	targetVersionPath, err := getVersionPath(providerName, versionName)
@@ -55,99 +94,146 @@ func runSetVersion(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("original path '%s' no longer exists", provider.OriginalPath)
 	}
 
-	// Check if current state is backed up (unless force flag is used)
-	if !forceFlag {
+	// Writes made through the link of a symlink provider belong to its active version
+	synced, err := syncLinkedVersion(config, &provider)
+	if err != nil {
+		return err
+	}
+
+	// So are changes a tool made to a copy, with capture-on-leave
+	revision, err := captureOnLeave(config, &provider)
+	if err != nil {
+		return err
+	}
+
+	// An explicit --autosave or --autosave=false overrides the configured default
+	autosave := config.settings().Autosave
+	if cmd.Flags().Changed("autosave") {
+		autosave = autosaveFlag
+	}
+
+	// Check if current state is backed up, saving it as an auto-snapshot if enabled
+	var snapshot string
+	if autosave {
 		isBackedUp, err := isCurrentStateBackedUp(provider)
 		if err != nil {
 			return fmt.Errorf("failed to check if current state is backed up: %w", err)
 		}
 
 		if !isBackedUp {
-			return fmt.Errorf("current state of '%s' is not backed up in any version. Use 'llmctx add-version %s <version_name>' to back it up first, or use --force to proceed anyway", provider.OriginalPath, providerName)
+			snapshot, err = takeAutoSnapshot(config, &provider, fmt.Sprintf("saved before switching to '%s'", versionName))
+			if err != nil {
+				return err
+			}
 		}
-	}
-
-	// Remove existing content at original path
-	if provider.Type == "directory" {
-		if err := os.RemoveAll(provider.OriginalPath); err != nil {
-			return fmt.Errorf("failed to remove existing directory: %w", err)
+	} else if !forceFlag {
+		isBackedUp, err := isCurrentStateBackedUp(provider)
+		if err != nil {
+			return fmt.Errorf("failed to check if current state is backed up: %w", err)
 		}
-	} else {
-		if err := os.Remove(provider.OriginalPath); err != nil {
-			return fmt.Errorf("failed to remove existing file: %w", err)
+
+		if !isBackedUp {
+			return fmt.Errorf("current state of '%s' is not backed up in any version. Use 'llmctx add-version %s <version_name>' to back it up first, or use --force to proceed anyway", provider.OriginalPath, providerName)
 		}
 	}
 
-	// Ensure parent directories exist
-	parentDir := filepath.Dir(provider.OriginalPath)
-	if err := os.MkdirAll(parentDir, 0755); err != nil {
-		return fmt.Errorf("failed to create parent directories: %w", err)
+	// Stage the version and swap it in, keeping the current content until done
+	if err := switchVersion(config, provider, versionName); err != nil {
+		return err
+	}
+	// Going back with "-" or undo should restore the state that was just saved
+	previous := provider.CurrentVersion
+	if snapshot != "" {
+		previous = snapshot
 	}
+	recordHistory(historyEvent{
+		Action:          historySetVersion,
+		Provider:        providerName,
+		Version:         versionName,
+		PreviousVersion: previous,
+	})
+
+	message := fmt.Sprintf("Successfully set '%s' to version '%s'", providerName, versionName)
+	if synced {
+		message = fmt.Sprintf("Saved changes to version '%s' of '%s'\n%s", provider.CurrentVersion, providerName, message)
+	}
+	if revision > 0 {
+		message = fmt.Sprintf("Saved changes to version '%s' of '%s', keeping its previous content as revision %d\n%s", provider.CurrentVersion, providerName, revision, message)
+	}
+	if snapshot != "" {
+		message = fmt.Sprintf("Saved unbacked state of '%s' as '%s'\n%s", providerName, snapshot, message)
 
-	// Copy version to original location
-	if err := copyPath(targetVersionPath, provider.OriginalPath, provider.Type); err != nil {
-		return fmt.Errorf("failed to copy version to original location: %w", err)
+		// The switch already succeeded, so failing to clean up only warns
+		removed, err := pruneAutoSnapshots(config, providerName)
+		for _, name := range removed {
+			message += fmt.Sprintf("\nDeleted old auto-snapshot '%s'", name)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to delete old auto-snapshots: %v\n", err)
+		}
 	}
 
-	// Update current version in config
-	provider.CurrentVersion = versionName
-	config.Providers[providerName] = provider
+	return renderAction(actionResult{
+		Action:          "set-version",
+		Provider:        providerName,
+		Version:         versionName,
+		PreviousVersion: provider.CurrentVersion,
+		Snapshot:        snapshot,
+		Message:         message,
+	})
+}
 
-	// Save config
-	if err := config.saveProviders(); err != nil {
-		return fmt.Errorf("failed to save providers config: %w", err)
+// previousVersion returns the version that was active before the last recorded
+// switch of a provider
+func previousVersion(provider Provider) (string, error) {
+	events, err := loadHistory()
+	if err != nil {
+		return "", err
 	}
 
-	fmt.Printf("Successfully set '%s' to version '%s'\n", providerName, versionName)
-	return nil
+	last, ok := lastSwitch(events, provider.Name)
+	if !ok || last.PreviousVersion == "" {
+		return "", fmt.Errorf("no previous version recorded for '%s'", provider.Name)
+	}
+	return last.PreviousVersion, nil
 }
 
-// isCurrentStateBackedUp checks if the current state matches any existing version
+// isCurrentStateBackedUp checks if the current state matches any existing version.
+// Any error while reading the live state or a stored version is returned rather
+// than treated as a mismatch, so the safety check cannot be fooled.
 func isCurrentStateBackedUp(provider Provider) (bool, error) {
-	versions, err := getAvailableVersions(provider.Name)
+	matches, err := findMatchingVersions(provider)
 	if err != nil {
 		return false, err
 	}
+	return len(matches) > 0, nil
+}
+
+// findMatchingVersions returns the stored versions whose content is identical
+// to the current state at the provider's original path
+func findMatchingVersions(provider Provider) ([]string, error) {
+	versions, err := getAvailableVersions(provider.Name)
+	if err != nil {
+		return nil, err
+	}
 
-	for _, version := range versions {
-		versionPath, err := getVersionPath(provider.Name, version)
-		if err != nil {
-			continue
-		}
+	policy := provider.symlinkPolicy()
+	current, err := hashTree(provider.OriginalPath, provider.Type, policy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read current state: %w", err)
+	}
 
-		// Compare current state with this version
-		matches, err := comparePathContents(provider.OriginalPath, versionPath, provider.Type)
+	var matches []string
+	for _, version := range versions {
+		stored, err := versionTree(provider.Name, version)
 		if err != nil {
-			continue
+			return nil, fmt.Errorf("failed to read version '%s': %w", version, err)
 		}
 
-		if matches {
-			return true, nil
+		if compareManifests(current, stored).Equal {
+			matches = append(matches, version)
 		}
 	}
 
-	return false, nil
+	return matches, nil
 }
-
-// comparePathContents compares the contents of two paths (files or directories)
-func comparePathContents(path1, path2, pathType string) (bool, error) {
-	if pathType == "directory" {
-		// Use diff -r to compare directories
-		cmd := exec.Command("diff", "-r", path1, path2)
-		err := cmd.Run()
-		if err == nil {
-			return true, nil // No differences found
-		}
-		// diff returns non-zero exit code when differences are found
-		return false, nil
-	} else {
-		// Use diff to compare files
-		cmd := exec.Command("diff", path1, path2)
-		err := cmd.Run()
-		if err == nil {
-			return true, nil // No differences found
-		}
-		// diff returns non-zero exit code when differences are found
-		return false, nil
-	}
-}
\ No newline at end of file
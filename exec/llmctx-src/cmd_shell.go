@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+
+	"github.com/spf13/cobra"
+)
+
+var shellCmd = &cobra.Command{
+	Use:   "shell <provider_name> <version_name>",
+	Short: "Start a shell that uses a version without switching it globally",
+	Long: `Start a new shell, $SHELL or /bin/sh, with the provider's environment variable
+pointing at a working copy of a stored version. Only that shell and the programs
+started from it use the version; the original path is left alone, so other
+terminals keep their version. Exit the shell to return.
+
+The working copy is the same one "llmctx env" uses. Changes the tool makes to it
+are kept until it is recreated from the stored version with --refresh.
+
+Exits with the exit code of the shell.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runShell,
+}
+
+var shellRefresh bool
+
+func init() {
+	shellCmd.Flags().BoolVar(&shellRefresh, "refresh", false, "Recreate the working copy from the stored version, discarding changes made to it")
+	rootCmd.AddCommand(shellCmd)
+}
+
+func runShell(cmd *cobra.Command, args []string) error {
+	result, err := prepareOverlay(args[0], args[1], shellRefresh)
+	if err != nil {
+		return err
+	}
+
+	shellPath := os.Getenv("SHELL")
+	if shellPath == "" {
+		shellPath = "/bin/sh"
+	}
+	fmt.Fprintf(os.Stderr, "Starting %s with version '%s' of '%s' (%s=%s); exit the shell to return\n", shellPath, result.Version, result.Provider, result.EnvVar, result.Path)
+
+	shell := exec.Command(shellPath)
+	shell.Stdin, shell.Stdout, shell.Stderr = os.Stdin, os.Stdout, os.Stderr
+	shell.Env = append(os.Environ(), result.EnvVar+"="+result.Path)
+
+	// The terminal delivers Ctrl-C to the shell as well, which handles it;
+	// llmctx only has to keep waiting for it
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+
+	err = shell.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		code := exitErr.ExitCode()
+		if code < 0 {
+			code = exitGeneral
+		}
+		return silentExit(code, err)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to start shell: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var showCmd = &cobra.Command{
+	Use:   "show <provider_name> <version_name>",
+	Short: "Show the metadata of a stored version",
+	Long:  `Show when, where and why a stored version was captured, along with its size and content digest.`,
+	Args:  cobra.ExactArgs(2),
+	RunE:  runShow,
+}
+
+func init() {
+	rootCmd.AddCommand(showCmd)
+}
+
+// showResult is the structured output of show
+type showResult struct {
+	Provider string       `json:"provider"`
+	Version  string       `json:"version"`
+	Active   bool         `json:"active"`
+	Path     string       `json:"path"`
+	Metadata *VersionMeta `json:"metadata,omitempty"`
+	Digest   string       `json:"digest"`
+	Size     int64        `json:"size"`
+}
+
+func runShow(cmd *cobra.Command, args []string) error {
+	providerName := args[0]
+	versionName := args[1]
+
+	// Load providers config
+	config, err := loadProviders()
+	if err != nil {
+		return fmt.Errorf("failed to load providers: %w", err)
+	}
+
+	// Check if provider exists
+	provider, exists := config.Providers[providerName]
+	if !exists {
+		return fmt.Errorf("provider '%s' not found", providerName)
+	}
+
+	versionPath, err := getVersionPath(providerName, versionName)
+	if err != nil {
+		return fmt.Errorf("failed to get version path: %w", err)
+	}
+	if _, err := os.Lstat(versionPath); os.IsNotExist(err) {
+		return fmt.Errorf("version '%s' not found for provider '%s'", versionName, providerName)
+	}
+
+	// Digest and size are always computed from the stored manifest, so they are
+	// available for versions saved before metadata was recorded
+	manifest, err := versionTree(providerName, versionName)
+	if err != nil {
+		return fmt.Errorf("failed to read version '%s': %w", versionName, err)
+	}
+
+	result := showResult{
+		Provider: providerName,
+		Version:  versionName,
+		Active:   provider.CurrentVersion == versionName,
+		Path:     versionPath,
+		Digest:   manifestDigest(manifest),
+		Size:     manifestSize(manifest),
+	}
+	if meta, ok := provider.Versions[versionName]; ok {
+		result.Metadata = &meta
+	}
+
+	return render(result, func() {
+		fmt.Printf("Provider: %s\n", result.Provider)
+		fmt.Printf("Version: %s", result.Version)
+		if result.Active {
+			fmt.Print(" (active)")
+		}
+		fmt.Println()
+		fmt.Printf("  Path: %s\n", result.Path)
+		fmt.Printf("  Size: %s\n", formatSize(result.Size))
+		fmt.Printf("  Digest: %s\n", result.Digest)
+
+		meta := result.Metadata
+		if meta == nil {
+			fmt.Println("  (no metadata recorded; the version predates metadata support)")
+			return
+		}
+		fmt.Printf("  Created: %s\n", meta.CreatedAt.Local().Format("2006-01-02 15:04:05 MST"))
+		fmt.Printf("  Updated: %s\n", meta.UpdatedAt.Local().Format("2006-01-02 15:04:05 MST"))
+		if meta.Hostname != "" {
+			fmt.Printf("  Host: %s\n", meta.Hostname)
+		}
+		if meta.LlmctxVersion != "" {
+			fmt.Printf("  Saved by: llmctx %s\n", meta.LlmctxVersion)
+		}
+		if meta.Digest != result.Digest {
+			fmt.Println("  Warning: stored content no longer matches the digest recorded when it was saved")
+		}
+		if meta.Note != "" {
+			fmt.Printf("  Note: %s\n", meta.Note)
+		}
+	})
+}
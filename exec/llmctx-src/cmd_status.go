@@ -0,0 +1,199 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var statusCmd = &cobra.Command{
+	Use:   "status [provider_name]",
+	Short: "Show whether live configurations still match their active versions",
+	Long: `Compare the live configuration of each provider (or only the given one) with
+its active version and with all stored versions, and report one of:
+
+  clean                    the live configuration matches the active version
+  modified                 the live configuration matches no stored version
+  matches-another-version  the live configuration matches a different stored version
+  missing                  the original path no longer exists
+
+For providers with symlink activation (see "llmctx activation") also:
+
+  unsynced                 changes made through the link are not saved into the
+                           active version yet; they are saved on the next switch
+  unlinked                 the original path no longer links to a working copy,
+                           for example because a tool replaced the link
+
+Exits with code 5 if any provider is not clean or unsynced.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runStatus,
+}
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+}
+
+// Drift states reported by status
+const (
+	stateClean        = "clean"
+	stateModified     = "modified"
+	stateMatchesOther = "matches-another-version"
+	stateMissing      = "missing"
+	stateUnsynced     = "unsynced"
+	stateUnlinked     = "unlinked"
+)
+
+// ProviderStatus describes how a provider's live configuration relates to its versions
+type ProviderStatus struct {
+	Provider         string           `json:"provider"`
+	CurrentVersion   string           `json:"current_version"`
+	State            string           `json:"state"`
+	MatchingVersions []string         `json:"matching_versions,omitempty"`
+	Differences      []FileDifference `json:"differences,omitempty"` // live compared with the active version
+	Activation       string           `json:"activation,omitempty"`  // set for symlink activation
+	LinkTarget       string           `json:"link_target,omitempty"` // working copy the original path links to
+}
+
+func runStatus(cmd *cobra.Command, args []string) error {
+	// Load providers config
+	config, err := loadProviders()
+	if err != nil {
+		return fmt.Errorf("failed to load providers: %w", err)
+	}
+
+	var providerNames []string
+	if len(args) == 1 {
+		if _, exists := config.Providers[args[0]]; !exists {
+			return fmt.Errorf("provider '%s' not found", args[0])
+		}
+		providerNames = []string{args[0]}
+	} else {
+		for name := range config.Providers {
+			providerNames = append(providerNames, name)
+		}
+		sort.Strings(providerNames)
+	}
+
+	result := statusResult{Providers: []ProviderStatus{}}
+	drifted := 0
+	for _, name := range providerNames {
+		status, err := getProviderStatus(config.Providers[name])
+		if err != nil {
+			return fmt.Errorf("failed to check status of '%s': %w", name, err)
+		}
+		if status.State != stateClean && status.State != stateUnsynced {
+			drifted++
+		}
+		result.Providers = append(result.Providers, status)
+	}
+
+	err = render(result, func() {
+		if len(result.Providers) == 0 {
+			fmt.Println("No providers configured.")
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, status := range result.Providers {
+			currentVersion := status.CurrentVersion
+			if currentVersion == "" {
+				currentVersion = "(none)"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", status.Provider, currentVersion, describeStatus(status))
+		}
+		w.Flush()
+	})
+	if err != nil {
+		return err
+	}
+
+	if drifted > 0 {
+		return silentExit(exitDrift, fmt.Errorf("%d provider(s) drifted from their active version", drifted))
+	}
+	return nil
+}
+
+// statusResult is the structured output of status
+type statusResult struct {
+	Providers []ProviderStatus `json:"providers"`
+}
+
+// getProviderStatus compares a provider's live configuration with its stored versions
+func getProviderStatus(provider Provider) (ProviderStatus, error) {
+	status := ProviderStatus{
+		Provider:       provider.Name,
+		CurrentVersion: provider.CurrentVersion,
+	}
+
+	if _, err := os.Stat(provider.OriginalPath); errors.Is(err, os.ErrNotExist) {
+		status.State = stateMissing
+		return status, nil
+	}
+
+	if provider.activation() == activationSymlink {
+		status.Activation = activationSymlink
+		status.LinkTarget = linkedWorkingCopy(provider.OriginalPath)
+	}
+
+	matches, err := findMatchingVersions(provider)
+	if err != nil {
+		return status, err
+	}
+	status.MatchingVersions = matches
+
+	if status.Activation != "" && status.LinkTarget == "" {
+		status.State = stateUnlinked
+		return status, nil
+	}
+
+	for _, match := range matches {
+		if match == provider.CurrentVersion {
+			status.State = stateClean
+			return status, nil
+		}
+	}
+	if len(matches) > 0 {
+		status.State = stateMatchesOther
+		return status, nil
+	}
+
+	status.State = stateModified
+	if status.LinkTarget != "" {
+		status.State = stateUnsynced
+	}
+	if provider.CurrentVersion != "" {
+		stored, err := versionTree(provider.Name, provider.CurrentVersion)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return status, err
+		}
+		if err == nil {
+			live, err := hashTree(provider.OriginalPath, provider.Type, provider.symlinkPolicy())
+			if err != nil {
+				return status, err
+			}
+			status.Differences = compareManifests(stored, live).Differences
+		}
+	}
+	return status, nil
+}
+
+// describeStatus renders a status for humans
+func describeStatus(status ProviderStatus) string {
+	switch status.State {
+	case stateMatchesOther:
+		return fmt.Sprintf("%s (%s)", status.State, strings.Join(status.MatchingVersions, ", "))
+	case stateModified, stateUnsynced:
+		if len(status.Differences) > 0 {
+			return fmt.Sprintf("%s (%d file(s) differ)", status.State, len(status.Differences))
+		}
+	case stateUnlinked:
+		return fmt.Sprintf("%s (not a link to a working copy; run 'llmctx activation %s symlink' to link it again)", status.State, status.Provider)
+	}
+	return status.State
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestGetProviderStatus(t *testing.T) {
+	_, _, provider := setupSwitchTest(t)
+
+	tests := []struct {
+		name     string
+		prepare  func()
+		expected string
+	}{
+		{"clean", func() {}, stateClean},
+		{"modified", func() { os.WriteFile(provider.OriginalPath, []byte("edited"), 0644) }, stateModified},
+		{"matches another version", func() { os.WriteFile(provider.OriginalPath, []byte("personal"), 0644) }, stateMatchesOther},
+		{"missing", func() { os.Remove(provider.OriginalPath) }, stateMissing},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.prepare()
+			status, err := getProviderStatus(provider)
+			if err != nil {
+				t.Fatalf("getProviderStatus failed: %v", err)
+			}
+			if status.State != tt.expected {
+				t.Errorf("State = %q, want %q", status.State, tt.expected)
+			}
+		})
+	}
+}
+
+func TestStatusExitCode(t *testing.T) {
+	_, _, provider := setupSwitchTest(t)
+
+	if err := runStatus(statusCmd, nil); err != nil {
+		t.Fatalf("Expected clean status to succeed, got: %v", err)
+	}
+
+	if err := os.WriteFile(provider.OriginalPath, []byte("edited"), 0644); err != nil {
+		t.Fatalf("Failed to modify live file: %v", err)
+	}
+	err := runStatus(statusCmd, []string{provider.Name})
+	if code := exitCodeFor(err); code != exitDrift {
+		t.Errorf("exit code = %d, want %d (err: %v)", code, exitDrift, err)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/spf13/cobra"
+)
+
+var undoCmd = &cobra.Command{
+	Use:   "undo <provider_name>",
+	Short: "Reverse the last version switch of a provider",
+	Long: `Reverse the last recorded version switch of a provider, putting back the version
+that was active before it.
+
+The switch is only reversed while the content at the original path still matches
+the version that was switched in, so no unsaved changes can be lost.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runUndo,
+}
+
+func init() {
+	rootCmd.AddCommand(undoCmd)
+}
+
+func runUndo(cmd *cobra.Command, args []string) error {
+	providerName := args[0]
+
+	unlock, err := lockProviders()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// Load providers config
+	config, err := loadProviders()
+	if err != nil {
+		return fmt.Errorf("failed to load providers: %w", err)
+	}
+
+	// Check if provider exists
+	provider, exists := config.Providers[providerName]
+	if !exists {
+		return fmt.Errorf("provider '%s' not found", providerName)
+	}
+	if err := checkProviderIdle(providerName); err != nil {
+		return err
+	}
+
+	events, err := loadHistory()
+	if err != nil {
+		return err
+	}
+	last, ok := lastSwitch(events, providerName)
+	if !ok {
+		return fmt.Errorf("no switch recorded for '%s'", providerName)
+	}
+	if last.PreviousVersion == "" {
+		return fmt.Errorf("the last switch of '%s' had no previous version to go back to", providerName)
+	}
+	if provider.CurrentVersion != last.Version {
+		return fmt.Errorf("'%s' is no longer at version '%s' that was last switched in", providerName, last.Version)
+	}
+
+	if _, err := syncLinkedVersion(config, &provider); err != nil {
+		return err
+	}
+	if _, err := captureOnLeave(config, &provider); err != nil {
+		return err
+	}
+
+	// Only undo while the live content is exactly what was switched in
+	matches, err := findMatchingVersions(provider)
+	if err != nil {
+		return fmt.Errorf("failed to check current state: %w", err)
+	}
+	if !slices.Contains(matches, last.Version) {
+		return fmt.Errorf("current state of '%s' has changed since version '%s' was switched in. Use 'llmctx add-version %s <version_name>' to save it first", provider.OriginalPath, last.Version, providerName)
+	}
+
+	if err := switchVersion(config, provider, last.PreviousVersion); err != nil {
+		return err
+	}
+	recordHistory(historyEvent{
+		Action:          historyUndo,
+		Provider:        providerName,
+		Version:         last.PreviousVersion,
+		PreviousVersion: last.Version,
+	})
+
+	return renderAction(actionResult{
+		Action:          "undo",
+		Provider:        providerName,
+		Version:         last.PreviousVersion,
+		PreviousVersion: last.Version,
+		Message:         fmt.Sprintf("Switched '%s' back from version '%s' to '%s'", providerName, last.Version, last.PreviousVersion),
+	})
+}
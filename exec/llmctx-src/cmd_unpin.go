@@ -0,0 +1,21 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var unpinCmd = &cobra.Command{
+	Use:   "unpin <provider_name> <version_name>",
+	Short: "Allow garbage collection of a pinned version again",
+	Long:  `Remove the protection added by pin, so gc and the auto-snapshot limit may delete the version again.`,
+	Args:  cobra.ExactArgs(2),
+	RunE:  runUnpin,
+}
+
+func init() {
+	rootCmd.AddCommand(unpinCmd)
+}
+
+func runUnpin(cmd *cobra.Command, args []string) error {
+	return setPinned(args[0], args[1], false)
+}
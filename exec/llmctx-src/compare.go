@@ -0,0 +1,249 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Kinds of entries recorded in a tree manifest
+const (
+	entryFile      = "file"
+	entryDirectory = "directory"
+	entrySymlink   = "symlink"
+)
+
+// treeEntry describes one file, directory or symlink of a hashed tree
+type treeEntry struct {
+	Path   string      `json:"path"` // slash-separated and relative to the root; "" is the root itself
+	Kind   string      `json:"kind"`
+	Mode   fs.FileMode `json:"mode"`
+	Size   int64       `json:"size,omitempty"`
+	Digest string      `json:"digest,omitempty"` // content digest of the file contents or link target
+
+	// Recorded so that a stored version can be restored exactly; not compared
+	ModTime time.Time `json:"mtime"`
+	Target  string    `json:"target,omitempty"` // symlink target
+}
+
+// treeManifest maps relative paths to the entries found there
+type treeManifest map[string]treeEntry
+
+// DiffKind describes how an entry differs between two trees
+type DiffKind string
+
+const (
+	DiffAdded    DiffKind = "added"
+	DiffRemoved  DiffKind = "removed"
+	DiffModified DiffKind = "modified"
+)
+
+// FileDifference is a single differing path between two trees
+type FileDifference struct {
+	Path   string   `json:"path"`
+	Kind   DiffKind `json:"kind"`
+	Detail string   `json:"detail,omitempty"` // for modified entries: "content", "mode" or "type"
+}
+
+// ComparisonResult is the outcome of comparing two trees
+type ComparisonResult struct {
+	Equal       bool             `json:"equal"`
+	Differences []FileDifference `json:"differences,omitempty"`
+}
+
+// newContentHash returns the hash content digests are taken with: sha256, or
+// in an encrypted store an HMAC keyed by the identity, so that the digests
+// kept in the store cannot be matched against guessed contents
+func newContentHash() (func() hash.Hash, error) {
+	key, err := storeNameKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the key naming stored contents: %w", err)
+	}
+	if key == nil {
+		return sha256.New, nil
+	}
+	return func() hash.Hash { return hmac.New(sha256.New, key) }, nil
+}
+
+// contentDigest returns the hex encoded digest of data
+func contentDigest(newHash func() hash.Hash, data []byte) string {
+	h := newHash()
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// hashTree walks a file or directory and records the path, kind, mode and content
+// digest of every entry. Symlinks inside a directory are recorded as links unless
+// the policy follows them; the root itself is always followed.
+func hashTree(root, pathType string, policy SymlinkPolicy) (treeManifest, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, pathError("stat", root, err)
+	}
+	newHash, err := newContentHash()
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := make(treeManifest)
+	if pathType != "directory" {
+		if !info.Mode().IsRegular() {
+			return nil, pathError("hash", root, errors.New("expected a regular file"))
+		}
+		entry, err := hashEntry(newHash, root, "", info)
+		if err != nil {
+			return nil, err
+		}
+		manifest[""] = entry
+		return manifest, nil
+	}
+
+	if !info.IsDir() {
+		return nil, pathError("hash", root, errors.New("expected a directory"))
+	}
+	if err := hashDir(newHash, root, "", info, policy, manifest, nil); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// hashDir records dir and everything below it under the relative path rel
+func hashDir(newHash func() hash.Hash, dir, rel string, info fs.FileInfo, policy SymlinkPolicy, manifest treeManifest, ancestors []fs.FileInfo) error {
+	for _, ancestor := range ancestors {
+		if os.SameFile(ancestor, info) {
+			return pathError("hash", dir, errors.New("symlink loop detected"))
+		}
+	}
+	ancestors = append(ancestors, info)
+
+	manifest[rel] = treeEntry{Path: rel, Kind: entryDirectory, Mode: info.Mode().Perm(), ModTime: info.ModTime()}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return pathError("read", dir, err)
+	}
+
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		entryRel := entry.Name()
+		if rel != "" {
+			entryRel = rel + "/" + entry.Name()
+		}
+
+		entryInfo, err := os.Lstat(path)
+		if err != nil {
+			return pathError("stat", path, err)
+		}
+		if entryInfo.Mode()&fs.ModeSymlink != 0 && policy == SymlinkFollow {
+			if entryInfo, err = os.Stat(path); err != nil {
+				return pathError("follow", path, err)
+			}
+		}
+
+		if entryInfo.IsDir() {
+			err = hashDir(newHash, path, entryRel, entryInfo, policy, manifest, ancestors)
+		} else {
+			var hashed treeEntry
+			hashed, err = hashEntry(newHash, path, entryRel, entryInfo)
+			manifest[entryRel] = hashed
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hashEntry records a single regular file or symlink
+func hashEntry(newHash func() hash.Hash, path, rel string, info fs.FileInfo) (treeEntry, error) {
+	entry := treeEntry{Path: rel, Mode: info.Mode().Perm(), ModTime: info.ModTime()}
+
+	switch {
+	case info.Mode()&fs.ModeSymlink != 0:
+		target, err := os.Readlink(path)
+		if err != nil {
+			return entry, pathError("readlink", path, err)
+		}
+		entry.Kind = entrySymlink
+		entry.Digest = contentDigest(newHash, []byte(target))
+		entry.Target = target
+	case info.Mode().IsRegular():
+		digest, err := hashFile(newHash, path)
+		if err != nil {
+			return entry, err
+		}
+		entry.Kind = entryFile
+		entry.Size = info.Size()
+		entry.Digest = digest
+	default:
+		return entry, pathError("hash", path, fmt.Errorf("unsupported file type %s", info.Mode().Type()))
+	}
+	return entry, nil
+}
+
+// hashFile returns the hex encoded digest of a file's contents
+func hashFile(newHash func() hash.Hash, path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", pathError("open", path, err)
+	}
+	defer f.Close()
+
+	h := newHash()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", pathError("read", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// compareManifests compares two hashed trees. Entries only present in right are
+// reported as added, entries only present in left as removed.
+func compareManifests(left, right treeManifest) ComparisonResult {
+	var differences []FileDifference
+
+	for path, l := range left {
+		r, ok := right[path]
+		switch {
+		case !ok:
+			differences = append(differences, FileDifference{Path: path, Kind: DiffRemoved})
+		case l.Kind != r.Kind:
+			differences = append(differences, FileDifference{Path: path, Kind: DiffModified, Detail: "type"})
+		case l.Digest != r.Digest:
+			differences = append(differences, FileDifference{Path: path, Kind: DiffModified, Detail: "content"})
+		case l.Mode != r.Mode:
+			differences = append(differences, FileDifference{Path: path, Kind: DiffModified, Detail: "mode"})
+		}
+	}
+	for path := range right {
+		if _, ok := left[path]; !ok {
+			differences = append(differences, FileDifference{Path: path, Kind: DiffAdded})
+		}
+	}
+
+	sort.Slice(differences, func(i, j int) bool {
+		return differences[i].Path < differences[j].Path
+	})
+	return ComparisonResult{Equal: len(differences) == 0, Differences: differences}
+}
+
+// comparePaths hashes two files or directories and compares them
+func comparePaths(path1, path2, pathType string, policy SymlinkPolicy) (ComparisonResult, error) {
+	left, err := hashTree(path1, pathType, policy)
+	if err != nil {
+		return ComparisonResult{}, err
+	}
+	right, err := hashTree(path2, pathType, policy)
+	if err != nil {
+		return ComparisonResult{}, err
+	}
+	return compareManifests(left, right), nil
+}
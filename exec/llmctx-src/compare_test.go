@@ -0,0 +1,91 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestComparePaths(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "llmctx-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	writeTree := func(root string, files map[string]string) {
+		for name, content := range files {
+			path := filepath.Join(root, name)
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatalf("Failed to create directory: %v", err)
+			}
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatalf("Failed to write file: %v", err)
+			}
+		}
+	}
+
+	left := filepath.Join(tempDir, "left")
+	right := filepath.Join(tempDir, "right")
+	writeTree(left, map[string]string{"a.txt": "one", "sub/b.txt": "two", "gone.txt": "x"})
+	writeTree(right, map[string]string{"a.txt": "one", "sub/b.txt": "changed", "new.txt": "y"})
+
+	t.Run("identical directories are equal", func(t *testing.T) {
+		result, err := comparePaths(left, left, "directory", SymlinkCopy)
+		if err != nil {
+			t.Fatalf("comparePaths failed: %v", err)
+		}
+		if !result.Equal || len(result.Differences) != 0 {
+			t.Errorf("Expected equal result, got %+v", result)
+		}
+	})
+
+	t.Run("differences are listed per file", func(t *testing.T) {
+		result, err := comparePaths(left, right, "directory", SymlinkCopy)
+		if err != nil {
+			t.Fatalf("comparePaths failed: %v", err)
+		}
+		if result.Equal {
+			t.Fatal("Expected directories to differ")
+		}
+
+		expected := []FileDifference{
+			{Path: "gone.txt", Kind: DiffRemoved},
+			{Path: "new.txt", Kind: DiffAdded},
+			{Path: "sub/b.txt", Kind: DiffModified, Detail: "content"},
+		}
+		if len(result.Differences) != len(expected) {
+			t.Fatalf("Differences = %+v, want %+v", result.Differences, expected)
+		}
+		for i, diff := range expected {
+			if result.Differences[i] != diff {
+				t.Errorf("Difference %d = %+v, want %+v", i, result.Differences[i], diff)
+			}
+		}
+	})
+
+	t.Run("mode changes are detected", func(t *testing.T) {
+		file1 := filepath.Join(tempDir, "mode1")
+		file2 := filepath.Join(tempDir, "mode2")
+		writeTree(tempDir, map[string]string{"mode1": "same", "mode2": "same"})
+		if err := os.Chmod(file2, 0600); err != nil {
+			t.Fatalf("Failed to chmod: %v", err)
+		}
+
+		result, err := comparePaths(file1, file2, "file", SymlinkCopy)
+		if err != nil {
+			t.Fatalf("comparePaths failed: %v", err)
+		}
+		if result.Equal || result.Differences[0].Detail != "mode" {
+			t.Errorf("Expected mode difference, got %+v", result)
+		}
+	})
+
+	t.Run("unreadable paths are errors, not mismatches", func(t *testing.T) {
+		_, err := comparePaths(left, filepath.Join(tempDir, "missing"), "directory", SymlinkCopy)
+		if err == nil {
+			t.Error("Expected error when comparing against a missing path")
+		}
+	})
+}
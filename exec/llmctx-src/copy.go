@@ -0,0 +1,224 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// SymlinkPolicy controls how symlinks found inside a managed directory are copied
+type SymlinkPolicy string
+
+const (
+	// SymlinkCopy recreates the link itself, pointing at the same target
+	SymlinkCopy SymlinkPolicy = "copy"
+	// SymlinkFollow copies the file or directory the link points to
+	SymlinkFollow SymlinkPolicy = "follow"
+	// SymlinkReject fails the copy when a link is encountered
+	SymlinkReject SymlinkPolicy = "reject"
+)
+
+// parseSymlinkPolicy validates a symlink policy name, defaulting to SymlinkCopy
+func parseSymlinkPolicy(value string) (SymlinkPolicy, error) {
+	switch SymlinkPolicy(value) {
+	case "", SymlinkCopy:
+		return SymlinkCopy, nil
+	case SymlinkFollow, SymlinkReject:
+		return SymlinkPolicy(value), nil
+	}
+	return "", fmt.Errorf("invalid symlink policy '%s': must be \"copy\", \"follow\" or \"reject\"", value)
+}
+
+// CopyError reports the exact path at which a copy failed
+type CopyError struct {
+	Op   string
+	Path string
+	Err  error
+}
+
+func (e *CopyError) Error() string {
+	return fmt.Sprintf("%s %s: %v", e.Op, e.Path, e.Err)
+}
+
+func (e *CopyError) Unwrap() error {
+	return e.Err
+}
+
+// pathError builds a CopyError, dropping the path already carried by errors
+// returned from the os package so it is not reported twice
+func pathError(op, path string, err error) *CopyError {
+	var pe *fs.PathError
+	var le *os.LinkError
+	switch {
+	case errors.As(err, &pe):
+		err = pe.Err
+	case errors.As(err, &le):
+		err = le.Err
+	}
+	return &CopyError{Op: op, Path: path, Err: err}
+}
+
+// errSymlinkRejected is returned when a symlink is found under SymlinkReject
+var errSymlinkRejected = errors.New("symlinks are not allowed by the provider's symlink policy")
+
+// copyPath copies a file or directory from src to dst, preserving file modes and
+// modification times. dst must not exist yet; if the copy fails part way, whatever
+// was written to dst is removed again.
+func copyPath(src, dst, pathType string, policy SymlinkPolicy) error {
+	if _, err := os.Lstat(dst); err == nil {
+		return pathError("create", dst, fs.ErrExist)
+	} else if !os.IsNotExist(err) {
+		return pathError("stat", dst, err)
+	}
+
+	// The managed path itself may be a symlink (e.g. a dotfiles link), so it is
+	// always followed; the policy only applies to entries inside a directory.
+	info, err := os.Stat(src)
+	if err != nil {
+		return pathError("stat", src, err)
+	}
+
+	if pathType == "directory" {
+		if !info.IsDir() {
+			return pathError("copy", src, errors.New("expected a directory"))
+		}
+		err = copyDir(src, dst, info, policy, nil)
+	} else {
+		if !info.Mode().IsRegular() {
+			return pathError("copy", src, errors.New("expected a regular file"))
+		}
+		err = copyFile(src, dst, info)
+	}
+
+	if err != nil {
+		removeAll(dst)
+		return err
+	}
+	return nil
+}
+
+// removeAll removes path like os.RemoveAll, first making directories writable so
+// that copies of read-only directories can be cleaned up as well
+func removeAll(path string) error {
+	filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err == nil && d.IsDir() {
+			os.Chmod(p, 0700)
+		}
+		return nil
+	})
+	return os.RemoveAll(path)
+}
+
+// copyDir recursively copies the directory src to dst. ancestors holds the
+// directories currently being copied so that followed links cannot loop forever.
+func copyDir(src, dst string, info fs.FileInfo, policy SymlinkPolicy, ancestors []fs.FileInfo) error {
+	for _, ancestor := range ancestors {
+		if os.SameFile(ancestor, info) {
+			return pathError("copy", src, errors.New("symlink loop detected"))
+		}
+	}
+	ancestors = append(ancestors, info)
+
+	if err := os.Mkdir(dst, 0700); err != nil {
+		return pathError("create", dst, err)
+	}
+
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return pathError("read", src, err)
+	}
+
+	for _, entry := range entries {
+		srcPath := filepath.Join(src, entry.Name())
+		dstPath := filepath.Join(dst, entry.Name())
+
+		entryInfo, err := os.Lstat(srcPath)
+		if err != nil {
+			return pathError("stat", srcPath, err)
+		}
+
+		if entryInfo.Mode()&fs.ModeSymlink != 0 {
+			switch policy {
+			case SymlinkReject:
+				return pathError("copy", srcPath, errSymlinkRejected)
+			case SymlinkFollow:
+				entryInfo, err = os.Stat(srcPath)
+				if err != nil {
+					return pathError("follow", srcPath, err)
+				}
+			default:
+				if err := copySymlink(srcPath, dstPath); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		switch {
+		case entryInfo.IsDir():
+			err = copyDir(srcPath, dstPath, entryInfo, policy, ancestors)
+		case entryInfo.Mode().IsRegular():
+			err = copyFile(srcPath, dstPath, entryInfo)
+		default:
+			err = pathError("copy", srcPath, fmt.Errorf("unsupported file type %s", entryInfo.Mode().Type()))
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// Apply the mode and times last so that a read-only directory can still be
+	// filled and copying its children does not bump its modification time.
+	if err := os.Chmod(dst, info.Mode().Perm()); err != nil {
+		return pathError("chmod", dst, err)
+	}
+	if err := os.Chtimes(dst, info.ModTime(), info.ModTime()); err != nil {
+		return pathError("chtimes", dst, err)
+	}
+	return nil
+}
+
+// copyFile copies a single regular file, preserving its mode and modification time
+func copyFile(src, dst string, info fs.FileInfo) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return pathError("open", src, err)
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return pathError("create", dst, err)
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return pathError("copy", src, err)
+	}
+	if err := out.Close(); err != nil {
+		return pathError("write", dst, err)
+	}
+
+	if err := os.Chmod(dst, info.Mode().Perm()); err != nil {
+		return pathError("chmod", dst, err)
+	}
+	if err := os.Chtimes(dst, info.ModTime(), info.ModTime()); err != nil {
+		return pathError("chtimes", dst, err)
+	}
+	return nil
+}
+
+// copySymlink recreates the symlink src at dst with the same target
+func copySymlink(src, dst string) error {
+	target, err := os.Readlink(src)
+	if err != nil {
+		return pathError("readlink", src, err)
+	}
+	if err := os.Symlink(target, dst); err != nil {
+		return pathError("symlink", dst, err)
+	}
+	return nil
+}
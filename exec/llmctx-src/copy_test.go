@@ -0,0 +1,186 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCopyPath(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "llmctx-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	t.Run("copy file", func(t *testing.T) {
+		// Create a test file
+		srcFile := filepath.Join(tempDir, "test.txt")
+		content := "test content"
+		if err := os.WriteFile(srcFile, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+
+		// Copy the file
+		dstFile := filepath.Join(tempDir, "test_copy.txt")
+		if err := copyPath(srcFile, dstFile, "file", SymlinkCopy); err != nil {
+			t.Fatalf("copyPath failed: %v", err)
+		}
+
+		// Verify the copy
+		copiedContent, err := os.ReadFile(dstFile)
+		if err != nil {
+			t.Fatalf("Failed to read copied file: %v", err)
+		}
+
+		if string(copiedContent) != content {
+			t.Errorf("Content mismatch: got %q, want %q", string(copiedContent), content)
+		}
+	})
+
+	t.Run("copy directory", func(t *testing.T) {
+		// Create a test directory with a file
+		srcDir := filepath.Join(tempDir, "testdir")
+		if err := os.MkdirAll(srcDir, 0755); err != nil {
+			t.Fatalf("Failed to create test directory: %v", err)
+		}
+
+		testFile := filepath.Join(srcDir, "file.txt")
+		content := "directory test content"
+		if err := os.WriteFile(testFile, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file in directory: %v", err)
+		}
+
+		// Copy the directory
+		dstDir := filepath.Join(tempDir, "testdir_copy")
+		if err := copyPath(srcDir, dstDir, "directory", SymlinkCopy); err != nil {
+			t.Fatalf("copyPath failed: %v", err)
+		}
+
+		// Verify the copy
+		copiedFile := filepath.Join(dstDir, "file.txt")
+		copiedContent, err := os.ReadFile(copiedFile)
+		if err != nil {
+			t.Fatalf("Failed to read copied file: %v", err)
+		}
+
+		if string(copiedContent) != content {
+			t.Errorf("Content mismatch: got %q, want %q", string(copiedContent), content)
+		}
+	})
+
+	t.Run("preserves modes and modification times", func(t *testing.T) {
+		srcDir := filepath.Join(tempDir, "modes")
+		if err := os.MkdirAll(filepath.Join(srcDir, "sub"), 0750); err != nil {
+			t.Fatalf("Failed to create test directory: %v", err)
+		}
+		secret := filepath.Join(srcDir, "sub", "token")
+		if err := os.WriteFile(secret, []byte("secret"), 0600); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+		mtime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		if err := os.Chtimes(secret, mtime, mtime); err != nil {
+			t.Fatalf("Failed to set mtime: %v", err)
+		}
+
+		dstDir := filepath.Join(tempDir, "modes_copy")
+		if err := copyPath(srcDir, dstDir, "directory", SymlinkCopy); err != nil {
+			t.Fatalf("copyPath failed: %v", err)
+		}
+
+		info, err := os.Stat(filepath.Join(dstDir, "sub", "token"))
+		if err != nil {
+			t.Fatalf("Failed to stat copied file: %v", err)
+		}
+		if info.Mode().Perm() != 0600 {
+			t.Errorf("File mode = %v, want 0600", info.Mode().Perm())
+		}
+		if !info.ModTime().Equal(mtime) {
+			t.Errorf("File mtime = %v, want %v", info.ModTime(), mtime)
+		}
+
+		dirInfo, err := os.Stat(filepath.Join(dstDir, "sub"))
+		if err != nil {
+			t.Fatalf("Failed to stat copied directory: %v", err)
+		}
+		if dirInfo.Mode().Perm() != 0750 {
+			t.Errorf("Directory mode = %v, want 0750", dirInfo.Mode().Perm())
+		}
+	})
+
+	t.Run("refuses to overwrite existing destination", func(t *testing.T) {
+		srcFile := filepath.Join(tempDir, "test.txt")
+		dstFile := filepath.Join(tempDir, "test_copy.txt")
+		if err := copyPath(srcFile, dstFile, "file", SymlinkCopy); err == nil {
+			t.Error("Expected error when destination already exists")
+		}
+	})
+}
+
+func TestCopyPathSymlinkPolicies(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "llmctx-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	srcDir := filepath.Join(tempDir, "src")
+	if err := os.MkdirAll(srcDir, 0755); err != nil {
+		t.Fatalf("Failed to create test directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(srcDir, "real.txt"), []byte("real"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if err := os.Symlink("real.txt", filepath.Join(srcDir, "link.txt")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+
+	t.Run("copy keeps the link", func(t *testing.T) {
+		dstDir := filepath.Join(tempDir, "copy")
+		if err := copyPath(srcDir, dstDir, "directory", SymlinkCopy); err != nil {
+			t.Fatalf("copyPath failed: %v", err)
+		}
+		target, err := os.Readlink(filepath.Join(dstDir, "link.txt"))
+		if err != nil {
+			t.Fatalf("Expected link to be copied as a link: %v", err)
+		}
+		if target != "real.txt" {
+			t.Errorf("Link target = %q, want %q", target, "real.txt")
+		}
+	})
+
+	t.Run("follow copies the target", func(t *testing.T) {
+		dstDir := filepath.Join(tempDir, "follow")
+		if err := copyPath(srcDir, dstDir, "directory", SymlinkFollow); err != nil {
+			t.Fatalf("copyPath failed: %v", err)
+		}
+		info, err := os.Lstat(filepath.Join(dstDir, "link.txt"))
+		if err != nil {
+			t.Fatalf("Failed to stat followed link: %v", err)
+		}
+		if !info.Mode().IsRegular() {
+			t.Errorf("Expected followed link to be a regular file, got %v", info.Mode())
+		}
+	})
+
+	t.Run("reject fails and cleans up", func(t *testing.T) {
+		dstDir := filepath.Join(tempDir, "reject")
+		err := copyPath(srcDir, dstDir, "directory", SymlinkReject)
+		if !errors.Is(err, errSymlinkRejected) {
+			t.Fatalf("Expected symlink rejection, got: %v", err)
+		}
+
+		var copyErr *CopyError
+		if !errors.As(err, &copyErr) || copyErr.Path != filepath.Join(srcDir, "link.txt") {
+			t.Errorf("Expected error to name the link path, got: %v", err)
+		}
+
+		if _, err := os.Lstat(dstDir); !os.IsNotExist(err) {
+			t.Errorf("Expected partial destination to be removed, stat err: %v", err)
+		}
+	})
+}
@@ -0,0 +1,483 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/term"
+)
+
+// Objects can be encrypted to an X25519 key in the style of age. Every object
+// gets a fresh ephemeral key pair; the secret it shares with the store's
+// recipient key is expanded with HKDF into an AES-256-GCM key. Saving needs
+// only the public key, so the private key, which may be protected by a
+// passphrase, is unlocked only when contents are read back. Objects are named
+// by an HMAC with a separate key kept readable in the identity file, so the
+// digests in the store cannot be used to confirm guesses of the contents.
+
+// encryptedObjectHeader starts every encrypted object, followed by the
+// ephemeral public key and the sealed contents
+const encryptedObjectHeader = "llmctx-encrypted/v1\n"
+
+const (
+	// passphraseEnv supplies the passphrase when stdin is not a terminal
+	passphraseEnv = "LLMCTX_PASSPHRASE"
+
+	// passphraseIterations is the PBKDF2-SHA256 work factor for new identities
+	passphraseIterations = 600000
+)
+
+// encryptionConfig records that new objects are encrypted and to which key
+type encryptionConfig struct {
+	Recipient string `json:"recipient"` // base64 X25519 public key
+	Identity  string `json:"identity"`  // path of the identity file holding the private key
+}
+
+// identityFile is a stored X25519 key pair. The private key is either kept
+// as is, for key files, or sealed with a key derived from a passphrase.
+type identityFile struct {
+	PublicKey        string `json:"public_key"`
+	NameKey          string `json:"name_key,omitempty"` // base64 HMAC key naming objects; not sealed, so saving needs no passphrase
+	PrivateKey       string `json:"private_key,omitempty"`
+	SealedPrivateKey string `json:"sealed_private_key,omitempty"`
+	Salt             string `json:"salt,omitempty"`
+	Iterations       int    `json:"iterations,omitempty"`
+}
+
+// unlockedKeys caches private keys by identity path, so a passphrase is asked
+// for at most once per invocation
+var unlockedKeys = map[string]*ecdh.PrivateKey{}
+
+// nameKeys caches the keys naming objects by identity path
+var nameKeys = map[string][]byte{}
+
+// getEncryptionConfigPath returns the path to the store's encryption settings
+func getEncryptionConfigPath() (string, error) {
+	configDir, err := getConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "store", "encryption.json"), nil
+}
+
+// getDefaultIdentityPath returns where passphrase-protected identities are kept
+func getDefaultIdentityPath() (string, error) {
+	configDir, err := getConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "identity"), nil
+}
+
+// loadEncryptionConfig returns the store's encryption settings, or nil when
+// the store is not encrypted
+func loadEncryptionConfig() (*encryptionConfig, error) {
+	path, err := getEncryptionConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read encryption settings: %w", err)
+	}
+	var config encryptionConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse encryption settings '%s': %w", path, err)
+	}
+	return &config, nil
+}
+
+// saveEncryptionConfig records the store's encryption settings
+func saveEncryptionConfig(config *encryptionConfig) error {
+	path, err := getEncryptionConfigPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), privateDirMode); err != nil {
+		return fmt.Errorf("failed to create object store: %w", err)
+	}
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal encryption settings: %w", err)
+	}
+	if err := writeFileAtomic(path, data, privateFileMode); err != nil {
+		return fmt.Errorf("failed to write encryption settings: %w", err)
+	}
+	return nil
+}
+
+// storeRecipient returns the key new objects are encrypted to, or nil when the
+// store is not encrypted
+func storeRecipient() (*ecdh.PublicKey, error) {
+	config, err := loadEncryptionConfig()
+	if err != nil || config == nil {
+		return nil, err
+	}
+	return parsePublicKey(config.Recipient)
+}
+
+// parsePublicKey decodes a base64 X25519 public key
+func parsePublicKey(encoded string) (*ecdh.PublicKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+	key, err := ecdh.X25519().NewPublicKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+	return key, nil
+}
+
+// encodePublicKey returns the base64 form of a public key
+func encodePublicKey(key *ecdh.PublicKey) string {
+	return base64.StdEncoding.EncodeToString(key.Bytes())
+}
+
+// readIdentity reads an identity file
+func readIdentity(path string) (*identityFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read identity: %w", err)
+	}
+	var identity identityFile
+	if err := json.Unmarshal(data, &identity); err != nil {
+		return nil, fmt.Errorf("failed to parse identity '%s': %w", path, err)
+	}
+	if _, err := parsePublicKey(identity.PublicKey); err != nil {
+		return nil, fmt.Errorf("identity '%s': %w", path, err)
+	}
+	return &identity, nil
+}
+
+// writeIdentity stores a new private key at path, which must not exist. With
+// a passphrase the key is sealed; without one the file itself is the secret.
+func writeIdentity(path string, key *ecdh.PrivateKey, passphrase []byte) error {
+	nameKey, err := newNameKey()
+	if err != nil {
+		return err
+	}
+	identity := identityFile{PublicKey: encodePublicKey(key.PublicKey()), NameKey: nameKey}
+	if passphrase == nil {
+		identity.PrivateKey = base64.StdEncoding.EncodeToString(key.Bytes())
+	} else {
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return err
+		}
+		aead, err := passphraseCipher(passphrase, salt, passphraseIterations)
+		if err != nil {
+			return err
+		}
+		nonce := make([]byte, aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return err
+		}
+		identity.SealedPrivateKey = base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, key.Bytes(), nil))
+		identity.Salt = base64.StdEncoding.EncodeToString(salt)
+		identity.Iterations = passphraseIterations
+	}
+
+	data, err := json.MarshalIndent(identity, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal identity: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), privateDirMode); err != nil {
+		return fmt.Errorf("failed to create identity directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, privateFileMode)
+	if err != nil {
+		return fmt.Errorf("failed to create identity: %w", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(path)
+		return fmt.Errorf("failed to write identity: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(path)
+		return fmt.Errorf("failed to write identity: %w", err)
+	}
+	return nil
+}
+
+// newNameKey returns a fresh base64 key for naming objects
+func newNameKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// ensureNameKey gives an identity written without a key for naming objects,
+// such as a key file shared from an older installation, a new one
+func ensureNameKey(path string) error {
+	identity, err := readIdentity(path)
+	if err != nil {
+		return err
+	}
+	if identity.NameKey != "" {
+		return nil
+	}
+	if identity.NameKey, err = newNameKey(); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(identity, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal identity: %w", err)
+	}
+	if err := writeFileAtomic(path, data, privateFileMode); err != nil {
+		return fmt.Errorf("failed to write identity: %w", err)
+	}
+	return nil
+}
+
+// storeNameKey returns the key objects are named with, or nil when the store
+// is not encrypted. It is read from the identity without its passphrase.
+func storeNameKey() ([]byte, error) {
+	config, err := loadEncryptionConfig()
+	if err != nil || config == nil {
+		return nil, err
+	}
+	if key, ok := nameKeys[config.Identity]; ok {
+		return key, nil
+	}
+
+	identity, err := readIdentity(config.Identity)
+	if err != nil {
+		return nil, err
+	}
+	if identity.PublicKey != config.Recipient {
+		return nil, fmt.Errorf("identity '%s' is not the key the store is encrypted to", config.Identity)
+	}
+	if identity.NameKey == "" {
+		return nil, fmt.Errorf("identity '%s' has no key for naming objects; run 'llmctx encrypt' to add one", config.Identity)
+	}
+	key, err := base64.StdEncoding.DecodeString(identity.NameKey)
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("invalid name key in '%s'", config.Identity)
+	}
+	nameKeys[config.Identity] = key
+	return key, nil
+}
+
+// privateKey returns the identity's private key, asking for the passphrase if
+// it is sealed
+func (identity *identityFile) privateKey(path string) (*ecdh.PrivateKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(identity.PrivateKey)
+	if identity.SealedPrivateKey != "" {
+		raw, err = identity.unseal(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key from '%s': %w", path, err)
+	}
+
+	key, err := ecdh.X25519().NewPrivateKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key in '%s': %w", path, err)
+	}
+	if encodePublicKey(key.PublicKey()) != identity.PublicKey {
+		return nil, fmt.Errorf("private key in '%s' does not match its public key", path)
+	}
+	return key, nil
+}
+
+// unseal decrypts a passphrase-protected private key
+func (identity *identityFile) unseal(path string) ([]byte, error) {
+	sealed, err := base64.StdEncoding.DecodeString(identity.SealedPrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	salt, err := base64.StdEncoding.DecodeString(identity.Salt)
+	if err != nil {
+		return nil, err
+	}
+
+	passphrase, err := readPassphrase(fmt.Sprintf("Enter passphrase for '%s': ", path), false)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := passphraseCipher(passphrase, salt, identity.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("sealed key is truncated")
+	}
+	raw, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, errors.New("incorrect passphrase")
+	}
+	return raw, nil
+}
+
+// passphraseCipher derives the cipher sealing a private key from a passphrase
+func passphraseCipher(passphrase, salt []byte, iterations int) (cipher.AEAD, error) {
+	if iterations <= 0 {
+		return nil, fmt.Errorf("invalid iteration count %d", iterations)
+	}
+	key, err := pbkdf2.Key(sha256.New, string(passphrase), salt, iterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	return newGCM(key)
+}
+
+// unlockStoreKey returns the private key objects are encrypted to
+func unlockStoreKey() (*ecdh.PrivateKey, error) {
+	config, err := loadEncryptionConfig()
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return nil, errors.New("object is encrypted but the store has no encryption key configured")
+	}
+	if key, ok := unlockedKeys[config.Identity]; ok {
+		return key, nil
+	}
+
+	identity, err := readIdentity(config.Identity)
+	if err != nil {
+		return nil, err
+	}
+	if identity.PublicKey != config.Recipient {
+		return nil, fmt.Errorf("identity '%s' is not the key the store is encrypted to", config.Identity)
+	}
+	key, err := identity.privateKey(config.Identity)
+	if err != nil {
+		return nil, err
+	}
+	unlockedKeys[config.Identity] = key
+	return key, nil
+}
+
+// isEncryptedObject reports whether stored object data is encrypted
+func isEncryptedObject(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(encryptedObjectHeader))
+}
+
+// encryptObject seals the contents of an object to the recipient
+func encryptObject(plaintext []byte, recipient *ecdh.PublicKey) ([]byte, error) {
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := ephemeral.ECDH(recipient)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := objectCipher(shared, ephemeral.PublicKey(), recipient)
+	if err != nil {
+		return nil, err
+	}
+
+	out := append([]byte(encryptedObjectHeader), ephemeral.PublicKey().Bytes()...)
+	// The key is used for this object only, so a fixed nonce is safe
+	return aead.Seal(out, make([]byte, aead.NonceSize()), plaintext, nil), nil
+}
+
+// decryptObject opens an object sealed by encryptObject
+func decryptObject(data []byte, key *ecdh.PrivateKey) ([]byte, error) {
+	body := data[len(encryptedObjectHeader):]
+	if len(body) < 32 {
+		return nil, errors.New("encrypted object is truncated")
+	}
+	ephemeral, err := ecdh.X25519().NewPublicKey(body[:32])
+	if err != nil {
+		return nil, err
+	}
+	shared, err := key.ECDH(ephemeral)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := objectCipher(shared, ephemeral, key.PublicKey())
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, make([]byte, aead.NonceSize()), body[32:], nil)
+	if err != nil {
+		return nil, errors.New("failed to decrypt object: wrong key or corrupt data")
+	}
+	return plaintext, nil
+}
+
+// objectCipher derives the cipher of one object from its shared secret
+func objectCipher(shared []byte, ephemeral, recipient *ecdh.PublicKey) (cipher.AEAD, error) {
+	salt := append(ephemeral.Bytes(), recipient.Bytes()...)
+	key, err := hkdf.Key(sha256.New, shared, salt, "llmctx object", 32)
+	if err != nil {
+		return nil, err
+	}
+	return newGCM(key)
+}
+
+// newGCM returns AES-256-GCM with the given key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// readPassphrase takes a passphrase from LLMCTX_PASSPHRASE or, on a terminal,
+// prompts for it without echoing, twice when confirm is set
+func readPassphrase(prompt string, confirm bool) ([]byte, error) {
+	if value, ok := os.LookupEnv(passphraseEnv); ok {
+		if value == "" {
+			return nil, fmt.Errorf("%s is empty", passphraseEnv)
+		}
+		return []byte(value), nil
+	}
+	if !isTerminal(os.Stdin) {
+		return nil, fmt.Errorf("a passphrase is required: set %s when stdin is not a terminal", passphraseEnv)
+	}
+
+	passphrase, err := promptHidden(prompt)
+	if err != nil {
+		return nil, err
+	}
+	if passphrase == "" {
+		return nil, errors.New("passphrase cannot be empty")
+	}
+	if confirm {
+		again, err := promptHidden("Confirm passphrase: ")
+		if err != nil {
+			return nil, err
+		}
+		if again != passphrase {
+			return nil, errors.New("passphrases do not match")
+		}
+	}
+	return []byte(passphrase), nil
+}
+
+// promptHidden reads a line from the terminal with echo turned off, failing
+// rather than reading it with echo on
+func promptHidden(prompt string) (string, error) {
+	// Prompts go to stderr to keep stdout clean for JSON or YAML output
+	fmt.Fprint(os.Stderr, prompt)
+	defer fmt.Fprintln(os.Stderr)
+
+	line, err := term.ReadPassword(int(os.Stdin.Fd()))
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	return string(line), nil
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEncryptObjectRoundTrip(t *testing.T) {
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext := []byte("api_key: sk-secret")
+
+	sealed, err := encryptObject(plaintext, key.PublicKey())
+	if err != nil {
+		t.Fatalf("encryptObject failed: %v", err)
+	}
+	if !isEncryptedObject(sealed) || strings.Contains(string(sealed), "sk-secret") {
+		t.Fatal("Expected the object to be encrypted")
+	}
+
+	opened, err := decryptObject(sealed, key)
+	if err != nil {
+		t.Fatalf("decryptObject failed: %v", err)
+	}
+	if string(opened) != string(plaintext) {
+		t.Errorf("Decrypted %q, want %q", opened, plaintext)
+	}
+
+	other, _ := ecdh.X25519().GenerateKey(rand.Reader)
+	if _, err := decryptObject(sealed, other); err == nil {
+		t.Error("Expected error when decrypting with another key")
+	}
+	sealed[len(sealed)-1] ^= 1
+	if _, err := decryptObject(sealed, key); err == nil {
+		t.Error("Expected error for a tampered object")
+	}
+}
+
+func TestEncryptedStore(t *testing.T) {
+	tempDir, provider := setupStoreTest(t)
+	t.Setenv(passphraseEnv, "correct horse")
+	t.Cleanup(func() {
+		unlockedKeys = map[string]*ecdh.PrivateKey{}
+		nameKeys = map[string][]byte{}
+	})
+
+	// A version saved before encryption was turned on, with a revision
+	if err := saveVersion(provider, "plain"); err != nil {
+		t.Fatalf("saveVersion failed: %v", err)
+	}
+	if _, err := keepRevision(provider, "plain", 5); err != nil {
+		t.Fatalf("keepRevision failed: %v", err)
+	}
+
+	identityPath, _ := getDefaultIdentityPath()
+	config, created, err := setUpIdentity(identityPath, []byte("correct horse"))
+	if err != nil || !created {
+		t.Fatalf("setUpIdentity = %v, %v", created, err)
+	}
+	if err := saveEncryptionConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	recipient, _ := parsePublicKey(config.Recipient)
+	if count, err := encryptObjects(recipient); err != nil || count != 3 {
+		t.Fatalf("encryptObjects = %d, %v; want 3 objects", count, err)
+	}
+	if count, err := encryptObjects(recipient); err != nil || count != 0 {
+		t.Errorf("Second encryptObjects = %d, %v; want nothing left to encrypt", count, err)
+	}
+
+	// Nothing in the store is named by the plain digest of the contents
+	for _, content := range []string{"{}", "secret", "notes"} {
+		plainDigest := contentDigest(sha256.New, []byte(content))
+		objectPath, _ := getObjectPath(plainDigest)
+		if _, err := os.Stat(objectPath); err == nil {
+			t.Errorf("Object for %q is still named by its sha256", content)
+		}
+		version, _ := loadVersion(provider.Name, "plain")
+		revision, _ := loadRevision(provider.Name, "plain", 1)
+		for _, entry := range append(version.Entries, revision.Entries...) {
+			if entry.Digest == plainDigest {
+				t.Errorf("Manifest entry %q still records the sha256 of its contents", entry.Path)
+			}
+		}
+	}
+	if matches, err := findMatchingVersions(provider); err != nil || len(matches) != 1 {
+		t.Errorf("findMatchingVersions = %v, %v; want the renamed version to match", matches, err)
+	}
+	assertDirRestores(t, provider, "plain")
+
+	// New versions are encrypted as they are saved
+	if err := os.WriteFile(filepath.Join(provider.OriginalPath, "token"), []byte("rotated"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveVersion(provider, "encrypted"); err != nil {
+		t.Fatalf("saveVersion failed: %v", err)
+	}
+	objectsDir, _ := getObjectsDir()
+	filepath.Walk(objectsDir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			data, _ := os.ReadFile(path)
+			if !isEncryptedObject(data) {
+				t.Errorf("Object %s is not encrypted", path)
+			}
+		}
+		return nil
+	})
+	assertDirRestores(t, provider, "encrypted")
+
+	t.Run("wrong passphrase", func(t *testing.T) {
+		unlockedKeys = map[string]*ecdh.PrivateKey{}
+		t.Setenv(passphraseEnv, "wrong")
+		err := materializeVersion(provider.Name, "plain", filepath.Join(tempDir, "wrong"))
+		if err == nil || !strings.Contains(err.Error(), "incorrect passphrase") {
+			t.Errorf("Expected incorrect passphrase error, got %v", err)
+		}
+	})
+
+	t.Run("name key added to an older identity", func(t *testing.T) {
+		keyPath := filepath.Join(tempDir, "key")
+		key, _ := ecdh.X25519().GenerateKey(rand.Reader)
+		if err := writeIdentity(keyPath, key, nil); err != nil {
+			t.Fatal(err)
+		}
+		identity, _ := readIdentity(keyPath)
+		identity.NameKey = ""
+		data, _ := json.Marshal(identity)
+		if err := os.WriteFile(keyPath, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ensureNameKey(keyPath); err != nil {
+			t.Fatalf("ensureNameKey failed: %v", err)
+		}
+		added, _ := readIdentity(keyPath)
+		if added.NameKey == "" || added.PrivateKey != identity.PrivateKey {
+			t.Fatalf("Identity after ensureNameKey = %+v", added)
+		}
+		if err := ensureNameKey(keyPath); err != nil {
+			t.Fatal(err)
+		}
+		if again, _ := readIdentity(keyPath); again.NameKey != added.NameKey {
+			t.Error("Expected an existing name key to be kept")
+		}
+	})
+
+	t.Run("existing key file is reused", func(t *testing.T) {
+		reused, created, err := setUpIdentity(identityPath, nil)
+		if err != nil || created || reused.Recipient != config.Recipient {
+			t.Errorf("setUpIdentity = %+v, %v, %v; want the existing key", reused, created, err)
+		}
+	})
+}
+
+func TestTightenPermissions(t *testing.T) {
+	_, provider := setupStoreTest(t)
+	if err := saveVersion(provider, "v1"); err != nil {
+		t.Fatalf("saveVersion failed: %v", err)
+	}
+
+	configDir, _ := getConfigDir()
+	historyPath := filepath.Join(configDir, "history.jsonl")
+	if err := os.WriteFile(historyPath, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(historyPath, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(configDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tightenPermissions(); err != nil {
+		t.Fatalf("tightenPermissions failed: %v", err)
+	}
+	filepath.Walk(configDir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().Perm()&0077 != 0 {
+			t.Errorf("%s is still accessible by others: %v", path, info.Mode())
+		}
+		return nil
+	})
+}
@@ -1,9 +1,14 @@
 module llmctx
 
-go 1.21
+go 1.24.0
+
+require (
+	github.com/spf13/cobra v1.9.1
+	golang.org/x/term v0.40.0
+)
 
 require (
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
-	github.com/spf13/cobra v1.9.1 // indirect
 	github.com/spf13/pflag v1.0.6 // indirect
-)
\ No newline at end of file
+	golang.org/x/sys v0.41.0 // indirect
+)
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Actions recorded in the history log
+const (
+	historyAddProvider    = "add-provider"
+	historyAddVersion     = "add-version"
+	historySetVersion     = "set-version"
+	historyUndo           = "undo"
+	historyRemoveVersion  = "remove-version"
+	historyRemoveProvider = "remove-provider"
+	historyRenameVersion  = "rename-version"
+	historyRenameProvider = "rename-provider"
+	historyRestore        = "restore"
+)
+
+// historyEvent is one line of the append-only history log
+type historyEvent struct {
+	Time            time.Time `json:"time"`
+	Action          string    `json:"action"`
+	Provider        string    `json:"provider"`
+	Version         string    `json:"version,omitempty"`
+	PreviousVersion string    `json:"previous_version,omitempty"`
+	NewName         string    `json:"new_name,omitempty"`
+	Revision        int       `json:"revision,omitempty"` // revision brought back by restore
+}
+
+// isSwitch reports whether the event changed the active version of a provider
+func (e historyEvent) isSwitch() bool {
+	return e.Action == historySetVersion || e.Action == historyUndo
+}
+
+// getHistoryFilePath returns the path to the history log
+func getHistoryFilePath() (string, error) {
+	configDir, err := getConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "history.jsonl"), nil
+}
+
+// appendHistory adds an event to the history log
+func appendHistory(event historyEvent) error {
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+
+	historyPath, err := getHistoryFilePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(historyPath), privateDirMode); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal history event: %w", err)
+	}
+
+	// A single write of a whole line to an O_APPEND file is never interleaved
+	f, err := os.OpenFile(historyPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, privateFileMode)
+	if err != nil {
+		return fmt.Errorf("failed to open history log: %w", err)
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write history log: %w", err)
+	}
+	return f.Close()
+}
+
+// recordHistory appends an event to the history log. The change it describes
+// has already been made, so a failure only produces a warning.
+func recordHistory(event historyEvent) {
+	if err := appendHistory(event); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to record history: %v\n", err)
+	}
+}
+
+// loadHistory reads all events from the history log, oldest first. Lines that
+// cannot be parsed, such as one cut short by a crash, are skipped.
+func loadHistory() ([]historyEvent, error) {
+	historyPath, err := getHistoryFilePath()
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(historyPath)
+	if os.IsNotExist(err) {
+		return []historyEvent{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history log: %w", err)
+	}
+	defer f.Close()
+
+	events := []historyEvent{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var event historyEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			continue
+		}
+		events = append(events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history log: %w", err)
+	}
+	return events, nil
+}
+
+// lastSwitch returns the most recent switch of a provider, with version names
+// translated through any later renames so they refer to the current names.
+// History from before the provider was last added is ignored.
+func lastSwitch(events []historyEvent, providerName string) (historyEvent, bool) {
+	name := providerName
+	renamed := make(map[string]string) // version name at the time of an event -> current name
+	current := func(version string) string {
+		if newName, ok := renamed[version]; ok {
+			return newName
+		}
+		return version
+	}
+
+	for i := len(events) - 1; i >= 0; i-- {
+		event := events[i]
+		if event.Action == historyRenameProvider && event.NewName == name {
+			name = event.Provider
+			continue
+		}
+		if event.Provider != name {
+			continue
+		}
+
+		switch {
+		case event.Action == historyRenameVersion:
+			renamed[event.Version] = current(event.NewName)
+		case event.Action == historyAddProvider || event.Action == historyRemoveProvider:
+			return historyEvent{}, false
+		case event.isSwitch():
+			event.Provider = providerName
+			event.Version = current(event.Version)
+			event.PreviousVersion = current(event.PreviousVersion)
+			return event, true
+		}
+	}
+	return historyEvent{}, false
+}
+
+// eventsForProvider returns the events concerning a provider, including those
+// recorded under a name it was later renamed from
+func eventsForProvider(events []historyEvent, providerName string) []historyEvent {
+	names := map[string]bool{providerName: true}
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].Action == historyRenameProvider && names[events[i].NewName] {
+			names[events[i].Provider] = true
+		}
+	}
+
+	filtered := []historyEvent{}
+	for _, event := range events {
+		if names[event.Provider] {
+			filtered = append(filtered, event)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestSetVersionPreviousAndUndo(t *testing.T) {
+	_, _, provider := setupSwitchTest(t)
+
+	if err := runSetVersion(setVersionCmd, []string{provider.Name, "-"}); err == nil {
+		t.Error("Expected error when no switch has been recorded")
+	}
+
+	if err := runSetVersion(setVersionCmd, []string{provider.Name, "personal"}); err != nil {
+		t.Fatalf("runSetVersion failed: %v", err)
+	}
+	if err := runSetVersion(setVersionCmd, []string{provider.Name, "-"}); err != nil {
+		t.Fatalf("runSetVersion with '-' failed: %v", err)
+	}
+	assertFileContent(t, provider.OriginalPath, "work")
+
+	if err := runUndo(undoCmd, []string{provider.Name}); err != nil {
+		t.Fatalf("runUndo failed: %v", err)
+	}
+	assertFileContent(t, provider.OriginalPath, "personal")
+
+	events, err := loadHistory()
+	if err != nil {
+		t.Fatalf("loadHistory failed: %v", err)
+	}
+	if len(events) != 3 || events[2].Action != historyUndo || events[2].Version != "personal" {
+		t.Errorf("Unexpected history: %+v", events)
+	}
+
+	t.Run("undo refuses to discard live changes", func(t *testing.T) {
+		if err := os.WriteFile(provider.OriginalPath, []byte("edited"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := runUndo(undoCmd, []string{provider.Name}); err == nil {
+			t.Error("Expected error when the live state has changed")
+		}
+		assertFileContent(t, provider.OriginalPath, "edited")
+	})
+}
+
+func TestLastSwitchFollowsRenames(t *testing.T) {
+	events := []historyEvent{
+		{Action: historySetVersion, Provider: "old", Version: "b", PreviousVersion: "a"},
+		{Action: historyRenameVersion, Provider: "old", Version: "a", NewName: "first"},
+		{Action: historyRenameProvider, Provider: "old", NewName: "new"},
+		{Action: historySetVersion, Provider: "other", Version: "x", PreviousVersion: "y"},
+	}
+
+	last, ok := lastSwitch(events, "new")
+	if !ok {
+		t.Fatal("Expected a switch to be found")
+	}
+	if last.Version != "b" || last.PreviousVersion != "first" {
+		t.Errorf("lastSwitch = %s -> %s, want first -> b", last.PreviousVersion, last.Version)
+	}
+
+	// A provider added again under an old name does not inherit its history
+	events = append(events, historyEvent{Action: historyAddProvider, Provider: "other"})
+	if _, ok := lastSwitch(events, "other"); ok {
+		t.Error("Expected no switch for a re-added provider")
+	}
+
+	if got := eventsForProvider(events, "new"); len(got) != 3 {
+		t.Errorf("eventsForProvider returned %d events, want 3", len(got))
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -52,37 +53,29 @@ func TestIntegrationWorkflow(t *testing.T) {
 		t.Errorf("Expected 'No providers configured', got: %s", string(output))
 	}
 
-	// Test 2: Add provider (simulated - we can't test interactive input easily)
-	// Instead, we'll test the underlying functionality directly
-	config := &ProvidersConfig{
-		Providers: make(map[string]Provider),
-	}
-
-	provider := Provider{
-		Name:           "test-provider",
-		OriginalPath:   testConfigFile,
-		Type:           "file",
-		CurrentVersion: "initial",
-	}
-
-	config.Providers["test-provider"] = provider
-	if err := config.saveProviders(); err != nil {
-		t.Fatalf("Failed to save provider config: %v", err)
-	}
-
-	// Create initial version
-	versionPath, err := getVersionPath("test-provider", "initial")
+	// Test 2: Add provider non-interactively using flags
+	addProviderCmd := exec.Command("./llmctx-test", "add-provider",
+		"--name", "test-provider",
+		"--path", testConfigFile,
+		"--initial-version", "initial")
+	addProviderCmd.Dir = "."
+	output, err = addProviderCmd.Output()
 	if err != nil {
-		t.Fatalf("Failed to get version path: %v", err)
+		t.Fatalf("Add-provider command failed: %v", err)
 	}
-
-	versionDir := filepath.Dir(versionPath)
-	if err := os.MkdirAll(versionDir, 0755); err != nil {
-		t.Fatalf("Failed to create version directory: %v", err)
+	if !strings.Contains(string(output), "Successfully added provider") {
+		t.Errorf("Expected success message in add-provider output, got: %s", string(output))
 	}
 
-	if err := copyPath(testConfigFile, versionPath, "file"); err != nil {
-		t.Fatalf("Failed to copy initial version: %v", err)
+	// Adding the same provider again fails with the "already exists" exit code
+	addProviderCmd = exec.Command("./llmctx-test", "add-provider",
+		"--name", "test-provider",
+		"--path", testConfigFile,
+		"--initial-version", "initial")
+	addProviderCmd.Dir = "."
+	err = addProviderCmd.Run()
+	if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != exitProviderExists {
+		t.Errorf("Expected exit code %d for existing provider, got: %v", exitProviderExists, err)
 	}
 
 	// Test 3: List with one provider
@@ -103,6 +96,21 @@ func TestIntegrationWorkflow(t *testing.T) {
 		t.Errorf("Expected initial version in output, got: %s", outputStr)
 	}
 
+	// Test 3b: List as JSON for scripts
+	listCmd = exec.Command("./llmctx-test", "list", "--output", "json")
+	listCmd.Dir = "."
+	output, err = listCmd.Output()
+	if err != nil {
+		t.Fatalf("List --output json failed: %v", err)
+	}
+	var listed listResult
+	if err := json.Unmarshal(output, &listed); err != nil {
+		t.Fatalf("List --output json is not valid JSON: %v\n%s", err, string(output))
+	}
+	if len(listed.Providers) != 1 || listed.Providers[0].CurrentVersion != "initial" {
+		t.Errorf("Unexpected JSON list output: %s", string(output))
+	}
+
 	// Test 4: Edit command
 	editCmd := exec.Command("./llmctx-test", "edit", "test-provider")
 	editCmd.Dir = "."
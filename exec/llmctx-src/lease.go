@@ -0,0 +1,275 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// leaseSuffix names exec leases in the journal directory
+const leaseSuffix = ".exec"
+
+// execLease records a provider that exec has temporarily switched to another
+// version. Its file stays locked while the command runs; a lease whose lock is
+// free was left behind by an exec that died, and recovery puts the provider back.
+type execLease struct {
+	Provider        string    `json:"provider"`
+	Version         string    `json:"version"`          // version active while the command runs
+	RestoreVersion  string    `json:"restore_version"`  // stored version holding the content to put back
+	PreviousVersion string    `json:"previous_version"` // current_version before the command
+	Command         string    `json:"command"`
+	PID             int       `json:"pid"`
+	StartedAt       time.Time `json:"started_at"`
+
+	file *os.File // open and locked while the lease is held
+}
+
+// getLeasePath returns the lease file of a provider
+func getLeasePath(providerName string) (string, error) {
+	if err := checkPathComponent("provider", providerName); err != nil {
+		return "", err
+	}
+	journalDir, err := getJournalDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(journalDir, providerName+leaseSuffix), nil
+}
+
+// acquireLease locks the lease file of the provider and records the lease in
+// it. It fails without waiting if another exec holds the provider.
+func acquireLease(lease *execLease) error {
+	leasePath, err := getLeasePath(lease.Provider)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(leasePath), privateDirMode); err != nil {
+		return fmt.Errorf("failed to create journal directory: %w", err)
+	}
+
+	file, err := openLease(leasePath)
+	if errors.Is(err, errLockBusy) {
+		return providerBusyError(lease.Provider, leasePath)
+	}
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(lease, "", "  ")
+	if err == nil {
+		err = file.Truncate(0)
+	}
+	if err == nil {
+		_, err = file.WriteAt(data, 0)
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if err != nil {
+		os.Remove(leasePath)
+		unlockFile(file)
+		file.Close()
+		return fmt.Errorf("failed to write lease: %w", err)
+	}
+	lease.file = file
+	return nil
+}
+
+// openLease opens and locks a lease file without waiting. A file removed by
+// its previous holder while we waited for the lock is opened again. Without
+// file locking an existing lease always counts as held.
+func openLease(leasePath string) (*os.File, error) {
+	if !fileLocking {
+		file, err := os.OpenFile(leasePath, os.O_RDWR|os.O_CREATE|os.O_EXCL, privateFileMode)
+		if os.IsExist(err) {
+			return nil, errLockBusy
+		}
+		return file, err
+	}
+	for {
+		file, err := os.OpenFile(leasePath, os.O_RDWR|os.O_CREATE, privateFileMode)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open lease: %w", err)
+		}
+		if err := tryLockFile(file); err != nil {
+			file.Close()
+			if errors.Is(err, errLockBusy) {
+				return nil, err
+			}
+			return nil, fmt.Errorf("failed to lock '%s': %w", leasePath, err)
+		}
+
+		opened, statErr := file.Stat()
+		current, err := os.Stat(leasePath)
+		if statErr == nil && err == nil && os.SameFile(opened, current) {
+			return file, nil
+		}
+		unlockFile(file)
+		file.Close()
+	}
+}
+
+// release removes the lease file and unlocks it
+func (l *execLease) release() error {
+	leasePath, err := getLeasePath(l.Provider)
+	if err != nil {
+		return err
+	}
+	removeErr := os.Remove(leasePath)
+	if os.IsNotExist(removeErr) {
+		removeErr = nil
+	}
+	l.unlock()
+	if removeErr != nil {
+		return fmt.Errorf("failed to remove lease: %w", removeErr)
+	}
+	return nil
+}
+
+// unlock unlocks the lease file but keeps it, so recovery ends the lease later
+func (l *execLease) unlock() {
+	if l.file != nil {
+		unlockFile(l.file)
+		l.file.Close()
+		l.file = nil
+	}
+}
+
+// providerBusyError reports a provider held by a running exec
+func providerBusyError(providerName, leasePath string) error {
+	var lease execLease
+	if data, err := os.ReadFile(leasePath); err == nil && json.Unmarshal(data, &lease) == nil && lease.PID != 0 {
+		return fmt.Errorf("provider '%s' is switched to version '%s' by 'llmctx exec' (pid %d) until '%s' exits", providerName, lease.Version, lease.PID, lease.Command)
+	}
+	return fmt.Errorf("provider '%s' is switched by a running 'llmctx exec'", providerName)
+}
+
+// checkProviderIdle fails if a running exec has temporarily switched the
+// provider, whose live content and versions must then be left alone
+func checkProviderIdle(providerName string) error {
+	leasePath, err := getLeasePath(providerName)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(leasePath); os.IsNotExist(err) {
+		return nil
+	} else if !fileLocking {
+		return providerBusyError(providerName, leasePath)
+	}
+
+	file, err := os.Open(leasePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open lease: %w", err)
+	}
+	defer file.Close()
+	if err := tryLockFile(file); errors.Is(err, errLockBusy) {
+		return providerBusyError(providerName, leasePath)
+	} else if err != nil {
+		return fmt.Errorf("failed to lock '%s': %w", leasePath, err)
+	}
+	return unlockFile(file)
+}
+
+// endLease puts back the content the provider had before exec switched it. If
+// the command changed the live content, for example by refreshing a token, the
+// changes are first saved as an auto-snapshot, whose name is returned. The
+// caller holds the providers lock and releases the lease afterwards.
+func endLease(config *ProvidersConfig, lease *execLease) (string, error) {
+	provider, exists := config.Providers[lease.Provider]
+	if !exists {
+		return "", fmt.Errorf("provider '%s' not found", lease.Provider)
+	}
+
+	var snapshot string
+	matches, err := findMatchingVersions(provider)
+	if err != nil {
+		return "", fmt.Errorf("failed to check the live content of '%s': %w", lease.Provider, err)
+	}
+	if !slices.Contains(matches, lease.RestoreVersion) {
+		if len(matches) == 0 {
+			snapshot, err = takeAutoSnapshot(config, &provider, fmt.Sprintf("changed by '%s' while '%s' was active", lease.Command, lease.Version))
+			if err != nil {
+				return "", err
+			}
+		}
+
+		journal, err := swapInVersion(provider, lease.RestoreVersion, "")
+		if err != nil {
+			return snapshot, fmt.Errorf("failed to restore version '%s' of '%s': %w", lease.RestoreVersion, lease.Provider, err)
+		}
+		if err := finishSwitch(journal); err != nil {
+			return snapshot, err
+		}
+	}
+
+	// Recovery of a switch interrupted by a crash may have moved current_version
+	if provider.CurrentVersion != lease.PreviousVersion {
+		provider.CurrentVersion = lease.PreviousVersion
+		config.Providers[lease.Provider] = provider
+		if err := config.saveProviders(); err != nil {
+			return snapshot, fmt.Errorf("failed to save providers config: %w", err)
+		}
+	}
+	return snapshot, nil
+}
+
+// recoverLease ends a lease left behind by an exec that died, unless the exec
+// is still running. The caller holds the providers lock.
+func recoverLease(leasePath string) error {
+	if !fileLocking {
+		return nil
+	}
+	file, err := openLease(leasePath)
+	if errors.Is(err, errLockBusy) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var lease execLease
+	data, err := os.ReadFile(leasePath)
+	if err == nil {
+		err = json.Unmarshal(data, &lease)
+	}
+	if err != nil {
+		unlockFile(file)
+		file.Close()
+		// A lease is written right after its file is created; an empty one
+		// was never used to switch anything
+		if len(data) == 0 {
+			os.Remove(leasePath)
+			return nil
+		}
+		return fmt.Errorf("failed to read lease '%s': %w", leasePath, err)
+	}
+	lease.file = file
+
+	config, err := loadProviders()
+	if err != nil {
+		lease.unlock()
+		return err
+	}
+	if _, exists := config.Providers[lease.Provider]; !exists {
+		return lease.release()
+	}
+
+	snapshot, err := endLease(config, &lease)
+	if err != nil {
+		// Keep the lease so the next invocation tries again
+		lease.unlock()
+		return err
+	}
+	if snapshot != "" {
+		fmt.Fprintf(os.Stderr, "Saved changes made by '%s' to '%s' as '%s'\n", lease.Command, lease.Provider, snapshot)
+	}
+	fmt.Fprintf(os.Stderr, "Restored '%s' after an interrupted 'llmctx exec' of '%s'\n", lease.Provider, lease.Command)
+	return lease.release()
+}
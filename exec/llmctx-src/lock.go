@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// lockTimeout is how long to wait for another llmctx process to release a lock
+var lockTimeout = 10 * time.Second
+
+// errLockBusy is returned by tryLockFile when another process holds the lock
+var errLockBusy = errors.New("lock is held by another process")
+
+// getLockFilePath returns the path of the lock file guarding providers.json
+func getLockFilePath() (string, error) {
+	configDir, err := getConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "providers.lock"), nil
+}
+
+// lockProviders takes an exclusive advisory lock around a load/modify/save cycle
+// of providers.json, waiting up to lockTimeout for other llmctx processes. The
+// returned function releases the lock.
+func lockProviders() (func(), error) {
+	lockPath, err := getLockFilePath()
+	if err != nil {
+		return nil, err
+	}
+	return acquireLock(lockPath, lockTimeout)
+}
+
+// acquireLock opens path and takes an exclusive lock on it, retrying until timeout
+func acquireLock(path string, timeout time.Duration) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(path), privateDirMode); err != nil {
+		return nil, fmt.Errorf("failed to create lock directory: %w", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, privateFileMode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		err := tryLockFile(f)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, errLockBusy) {
+			f.Close()
+			return nil, fmt.Errorf("failed to lock '%s': %w", path, err)
+		}
+		if time.Now().After(deadline) {
+			f.Close()
+			return nil, fmt.Errorf("timed out waiting for lock '%s': another llmctx process is running", path)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
+}
@@ -0,0 +1,19 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// fileLocking reports whether locks taken by tryLockFile exclude other processes
+const fileLocking = false
+
+// tryLockFile is a no-op on platforms without flock; llmctx still writes
+// providers.json atomically there, but concurrent invocations are not serialized
+func tryLockFile(f *os.File) error {
+	return nil
+}
+
+// unlockFile is a no-op on platforms without flock
+func unlockFile(f *os.File) error {
+	return nil
+}
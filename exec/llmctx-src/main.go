@@ -1,21 +1,97 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
 	"github.com/spf13/cobra"
 )
 
+// Exit codes returned by llmctx so scripts can branch on specific failures
+const (
+	exitGeneral        = 1
+	exitProviderExists = 3
+	exitPathMissing    = 4
+	exitDrift          = 5
+	exitProblemsFound  = 6
+)
+
+// exitCodeError wraps an error with the process exit code it should produce
+type exitCodeError struct {
+	code   int
+	err    error
+	silent bool // the command already reported the problem on stdout
+}
+
+func (e *exitCodeError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitCodeError) Unwrap() error {
+	return e.err
+}
+
+// withExitCode attaches a specific exit code to an error
+func withExitCode(code int, err error) error {
+	return &exitCodeError{code: code, err: err}
+}
+
+// silentExit makes the process exit with code without printing an error,
+// for commands whose output already explains the outcome
+func silentExit(code int, err error) error {
+	return &exitCodeError{code: code, err: err, silent: true}
+}
+
+// exitCodeFor returns the exit code that should be used for an error
+func exitCodeFor(err error) int {
+	var codeErr *exitCodeError
+	if errors.As(err, &codeErr) {
+		return codeErr.code
+	}
+	return exitGeneral
+}
+
+// llmctxVersion is the version of this build, set with -ldflags "-X main.llmctxVersion=..."
+var llmctxVersion = "dev"
+
 var rootCmd = &cobra.Command{
-	Use:   "llmctx",
-	Short: "Manage different versions of CLI tool configuration files and directories",
-	Long:  `llmctx is a tool to manage different versions of CLI tool authentication/configuration files or directories.`,
+	Use:     "llmctx",
+	Version: llmctxVersion,
+	Short:   "Manage different versions of CLI tool configuration files and directories",
+	Long:    `llmctx is a tool to manage different versions of CLI tool authentication/configuration files or directories.`,
+	// Errors are printed once by main, without repeating the usage text
+	SilenceErrors: true,
+	SilenceUsage:  true,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := validateOutputFormat(); err != nil {
+			return err
+		}
+		// Bring providers.json written by an older llmctx up to date
+		if err := upgradeProvidersFile(); err != nil {
+			return err
+		}
+		// Finish or undo any switch a previous invocation was interrupted in
+		return recoverInterruptedSwitches()
+	},
 }
 
 func main() {
 	if err := rootCmd.Execute(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		var codeErr *exitCodeError
+		if !errors.As(err, &codeErr) || !codeErr.silent {
+			printError(err)
+		}
+		os.Exit(exitCodeFor(err))
 	}
-}
\ No newline at end of file
+}
+
+// printError reports a failed command on stderr, or as a structured error
+// document on stdout when --output is json or yaml
+func printError(err error) {
+	if structuredOutput() {
+		writeStructured(os.Stdout, errorOutput{Error: errorDetail{Message: err.Error(), ExitCode: exitCodeFor(err)}})
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+}
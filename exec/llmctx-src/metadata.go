@@ -0,0 +1,125 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// VersionMeta records when, where and why a stored version was captured
+type VersionMeta struct {
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	Note          string    `json:"note,omitempty"`
+	Hostname      string    `json:"hostname,omitempty"`
+	LlmctxVersion string    `json:"llmctx_version,omitempty"`
+	Digest        string    `json:"digest"`         // sha256 over the paths, kinds, modes and content digests of the version
+	Size          int64     `json:"size"`           // total size of the files in bytes
+	Auto          bool      `json:"auto,omitempty"` // saved automatically by set-version --autosave
+}
+
+// manifestDigest returns a single digest identifying the content of a hashed tree
+func manifestDigest(manifest treeManifest) string {
+	paths := make([]string, 0, len(manifest))
+	for path := range manifest {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	h := sha256.New()
+	for _, path := range paths {
+		entry := manifest[path]
+		fmt.Fprintf(h, "%s\x00%s\x00%o\x00%s\n", entry.Path, entry.Kind, entry.Mode, entry.Digest)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// manifestSize returns the total size of the files in a hashed tree
+func manifestSize(manifest treeManifest) int64 {
+	var size int64
+	for _, entry := range manifest {
+		size += entry.Size
+	}
+	return size
+}
+
+// describeVersion computes metadata for the content stored for a version.
+// previous is the metadata of the version being overwritten, if any; its
+// creation time and note are kept unless a new note is given.
+func describeVersion(provider Provider, versionName string, previous *VersionMeta, note string) (VersionMeta, error) {
+	manifest, err := versionTree(provider.Name, versionName)
+	if err != nil {
+		return VersionMeta{}, fmt.Errorf("failed to read version '%s': %w", versionName, err)
+	}
+
+	now := time.Now().UTC()
+	hostname, _ := os.Hostname()
+	meta := VersionMeta{
+		CreatedAt:     now,
+		UpdatedAt:     now,
+		Note:          note,
+		Hostname:      hostname,
+		LlmctxVersion: llmctxVersion,
+		Digest:        manifestDigest(manifest),
+		Size:          manifestSize(manifest),
+	}
+	if previous != nil {
+		meta.CreatedAt = previous.CreatedAt
+		if note == "" {
+			meta.Note = previous.Note
+		}
+	}
+	return meta, nil
+}
+
+// recordVersion stores fresh metadata for a version that was just written
+func (p *Provider) recordVersion(versionName, note string) error {
+	var previous *VersionMeta
+	if meta, ok := p.Versions[versionName]; ok {
+		previous = &meta
+	}
+
+	meta, err := describeVersion(*p, versionName, previous, note)
+	if err != nil {
+		return err
+	}
+
+	if p.Versions == nil {
+		p.Versions = make(map[string]VersionMeta)
+	}
+	p.Versions[versionName] = meta
+	return nil
+}
+
+// formatSize renders a byte count for humans
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// summarizeVersionMeta renders the most useful metadata of a version on one line
+func summarizeVersionMeta(meta VersionMeta) string {
+	summary := fmt.Sprintf("saved %s", meta.UpdatedAt.Local().Format("2006-01-02 15:04"))
+	if meta.Auto {
+		summary = "auto-snapshot " + summary
+	}
+	if meta.Hostname != "" {
+		summary += " on " + meta.Hostname
+	}
+	summary += fmt.Sprintf(", %s", formatSize(meta.Size))
+	if meta.Note != "" {
+		summary += fmt.Sprintf(" - %s", meta.Note)
+	}
+	return summary
+}
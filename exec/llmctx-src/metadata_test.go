@@ -0,0 +1,84 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestAddVersionRecordsMetadata(t *testing.T) {
+	_, _, provider := setupSwitchTest(t)
+
+	addVersionNote = "first capture"
+	defer func() { addVersionNote = "" }()
+	if err := runAddVersion(addVersionCmd, []string{provider.Name, "snapshot"}); err != nil {
+		t.Fatalf("runAddVersion failed: %v", err)
+	}
+
+	config, err := loadProviders()
+	if err != nil {
+		t.Fatalf("loadProviders failed: %v", err)
+	}
+	meta, ok := config.Providers[provider.Name].Versions["snapshot"]
+	if !ok {
+		t.Fatal("Expected metadata for version 'snapshot'")
+	}
+	if meta.Note != "first capture" {
+		t.Errorf("Note = %q, want %q", meta.Note, "first capture")
+	}
+	if meta.Size != int64(len("work")) {
+		t.Errorf("Size = %d, want %d", meta.Size, len("work"))
+	}
+	if meta.CreatedAt.IsZero() || meta.Digest == "" {
+		t.Errorf("Expected creation time and digest, got %+v", meta)
+	}
+
+	t.Run("digest identifies content", func(t *testing.T) {
+		// The stored 'work' version has the same content as the snapshot
+		manifest, err := versionTree(provider.Name, "work")
+		if err != nil {
+			t.Fatalf("versionTree failed: %v", err)
+		}
+		if digest := manifestDigest(manifest); digest != meta.Digest {
+			t.Errorf("Digest of identical content = %s, want %s", digest, meta.Digest)
+		}
+	})
+
+	t.Run("overwriting keeps creation time and note", func(t *testing.T) {
+		if err := os.WriteFile(provider.OriginalPath, []byte("changed"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		addVersionNote = ""
+		if err := runAddVersion(addVersionCmd, []string{provider.Name, "snapshot"}); err != nil {
+			t.Fatalf("runAddVersion failed: %v", err)
+		}
+
+		config, err := loadProviders()
+		if err != nil {
+			t.Fatalf("loadProviders failed: %v", err)
+		}
+		updated := config.Providers[provider.Name].Versions["snapshot"]
+		if !updated.CreatedAt.Equal(meta.CreatedAt) {
+			t.Errorf("CreatedAt = %v, want %v", updated.CreatedAt, meta.CreatedAt)
+		}
+		if updated.Note != "first capture" {
+			t.Errorf("Note = %q, want it kept", updated.Note)
+		}
+		if updated.Digest == meta.Digest {
+			t.Error("Expected the digest to change with the content")
+		}
+	})
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := map[int64]string{
+		0:       "0 B",
+		1023:    "1023 B",
+		1536:    "1.5 KB",
+		5 << 20: "5.0 MB",
+	}
+	for size, want := range tests {
+		if got := formatSize(size); got != want {
+			t.Errorf("formatSize(%d) = %q, want %q", size, got, want)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// maxNameLength is the longest allowed provider or version name
+const maxNameLength = 64
+
+// validNamePattern allows letters, digits, dots, dashes and underscores, starting
+// with a letter or digit so names can never be hidden files or look like flags
+var validNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// envVarPattern matches the names of environment variables a shell can set
+var envVarPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// reservedNames have a special meaning on the command line or in the version store
+var reservedNames = map[string]bool{
+	".":  true,
+	"..": true,
+	"-":  true,
+}
+
+// validateName checks a new provider or version name against the naming rules.
+// kind is used in the error message, e.g. "provider" or "version".
+func validateName(kind, name string) error {
+	switch {
+	case name == "":
+		return fmt.Errorf("%s name cannot be empty", kind)
+	case len(name) > maxNameLength:
+		return fmt.Errorf("invalid %s name '%s': must be at most %d characters", kind, name, maxNameLength)
+	case reservedNames[name]:
+		return fmt.Errorf("invalid %s name '%s': the name is reserved", kind, name)
+	case strings.ContainsAny(name, `/\`):
+		return fmt.Errorf("invalid %s name '%s': must not contain path separators", kind, name)
+	case !validNamePattern.MatchString(name):
+		return fmt.Errorf("invalid %s name '%s': use letters, digits, '.', '-' and '_', starting with a letter or digit", kind, name)
+	}
+	return nil
+}
+
+// validateProviderName checks a new provider name
+func validateProviderName(name string) error {
+	return validateName("provider", name)
+}
+
+// validateVersionName checks a new version name
+func validateVersionName(name string) error {
+	return validateName("version", name)
+}
+
+// validateEnvVarName checks the name of an environment variable
+func validateEnvVarName(name string) error {
+	if !envVarPattern.MatchString(name) {
+		return fmt.Errorf("invalid environment variable name '%s': use letters, digits and '_', not starting with a digit", name)
+	}
+	return nil
+}
+
+// checkPathComponent makes sure an existing name can be used as a single path
+// element below ~/.llmctx without escaping it. Names created before the naming
+// rules existed only have to pass this check, so they can still be renamed or removed.
+func checkPathComponent(kind, name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, "/\\\x00") {
+		return fmt.Errorf("invalid %s name '%s': not usable as a storage path", kind, name)
+	}
+	return nil
+}
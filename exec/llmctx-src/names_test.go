@@ -0,0 +1,52 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateName(t *testing.T) {
+	tests := []struct {
+		name  string
+		valid bool
+	}{
+		{"work", true},
+		{"personal-2", true},
+		{"gh.corp_account", true},
+		{"auto-2026-10-18T10-00-00", true},
+		{"", false},
+		{".", false},
+		{"..", false},
+		{"-", false},
+		{"../../other/versions/x", false},
+		{"a/b", false},
+		{`a\b`, false},
+		{".hidden", false},
+		{"--force", false},
+		{"work@2", false},
+		{"has space", false},
+		{strings.Repeat("a", maxNameLength), true},
+		{strings.Repeat("a", maxNameLength+1), false},
+	}
+
+	for _, tt := range tests {
+		err := validateName("version", tt.name)
+		if tt.valid && err != nil {
+			t.Errorf("validateName(%q) returned unexpected error: %v", tt.name, err)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("validateName(%q) should have been rejected", tt.name)
+		}
+	}
+}
+
+func TestGetVersionPathRejectsTraversal(t *testing.T) {
+	for _, name := range []string{"..", "../../etc", "a/b", ""} {
+		if _, err := getVersionPath("test-provider", name); err == nil {
+			t.Errorf("getVersionPath accepted unsafe version name %q", name)
+		}
+		if _, err := getVersionPath(name, "v1"); err == nil {
+			t.Errorf("getVersionPath accepted unsafe provider name %q", name)
+		}
+	}
+}
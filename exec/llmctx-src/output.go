@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Output formats accepted by --output
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+// outputFormat is the value of the global --output flag
+var outputFormat string
+
+func init() {
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", outputTable, "Output format: \"table\", \"json\" or \"yaml\"")
+}
+
+// validateOutputFormat checks the value of --output
+func validateOutputFormat() error {
+	switch outputFormat {
+	case outputTable, outputJSON, outputYAML:
+		return nil
+	}
+	return fmt.Errorf("invalid output format '%s': must be \"table\", \"json\" or \"yaml\"", outputFormat)
+}
+
+// structuredOutput reports whether results are printed as JSON or YAML
+func structuredOutput() bool {
+	return outputFormat == outputJSON || outputFormat == outputYAML
+}
+
+// render prints result as JSON or YAML, or calls table to print the human layout
+func render(result any, table func()) error {
+	if !structuredOutput() {
+		table()
+		return nil
+	}
+	return writeStructured(os.Stdout, result)
+}
+
+// writeStructured encodes v to w in the selected structured format
+func writeStructured(w io.Writer, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode output: %w", err)
+	}
+
+	if outputFormat == outputYAML {
+		yaml, err := jsonToYAML(data)
+		if err != nil {
+			return fmt.Errorf("failed to encode output: %w", err)
+		}
+		_, err = io.WriteString(w, yaml)
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+// actionResult is the structured output of commands that change state
+type actionResult struct {
+	Action          string `json:"action"`
+	Provider        string `json:"provider,omitempty"`
+	Profile         string `json:"profile,omitempty"`
+	Version         string `json:"version,omitempty"`
+	PreviousVersion string `json:"previous_version,omitempty"`
+	NewName         string `json:"new_name,omitempty"`
+	Snapshot        string `json:"snapshot,omitempty"` // auto-snapshot taken before the change
+	Revision        int    `json:"revision,omitempty"` // revision kept by add-version, or brought back by restore
+	Message         string `json:"message"`
+}
+
+// renderAction prints the outcome of a command that changed state
+func renderAction(result actionResult) error {
+	return render(result, func() {
+		fmt.Println(result.Message)
+	})
+}
+
+// errorOutput is the structured document printed when a command fails
+type errorOutput struct {
+	Error errorDetail `json:"error"`
+}
+
+// errorDetail describes a failed command
+type errorDetail struct {
+	Message  string `json:"message"`
+	ExitCode int    `json:"exit_code"`
+}
+
+// yamlNode is a JSON value decoded with its object member order intact
+type yamlNode struct {
+	object bool
+	array  bool
+	keys   []string
+	values []*yamlNode
+	scalar string // already formatted as YAML
+}
+
+// jsonToYAML converts a JSON document to YAML, keeping the order of object members
+func jsonToYAML(data []byte) (string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	node, err := decodeYAMLNode(decoder)
+	if err != nil {
+		return "", err
+	}
+
+	var out strings.Builder
+	writeYAMLNode(&out, node, 0)
+	return out.String(), nil
+}
+
+// decodeYAMLNode reads the next JSON value from decoder
+func decodeYAMLNode(decoder *json.Decoder) (*yamlNode, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch value := token.(type) {
+	case json.Delim:
+		node := &yamlNode{object: value == '{', array: value == '['}
+		for decoder.More() {
+			if node.object {
+				keyToken, err := decoder.Token()
+				if err != nil {
+					return nil, err
+				}
+				node.keys = append(node.keys, keyToken.(string))
+			}
+			child, err := decodeYAMLNode(decoder)
+			if err != nil {
+				return nil, err
+			}
+			node.values = append(node.values, child)
+		}
+		// Consume the closing delimiter
+		if _, err := decoder.Token(); err != nil {
+			return nil, err
+		}
+		return node, nil
+	case string:
+		return &yamlNode{scalar: yamlString(value)}, nil
+	case json.Number:
+		return &yamlNode{scalar: value.String()}, nil
+	case bool:
+		return &yamlNode{scalar: fmt.Sprint(value)}, nil
+	case nil:
+		return &yamlNode{scalar: "null"}, nil
+	}
+	return nil, fmt.Errorf("unexpected JSON token %v", token)
+}
+
+// writeYAMLNode writes node as the value of a block at the given indentation
+func writeYAMLNode(out *strings.Builder, node *yamlNode, indent int) {
+	pad := strings.Repeat("  ", indent)
+
+	switch {
+	case node.object:
+		for i, key := range node.keys {
+			out.WriteString(pad + yamlString(key) + ":")
+			writeYAMLChild(out, node.values[i], indent)
+		}
+	case node.array:
+		for _, child := range node.values {
+			if child.object && len(child.keys) > 0 {
+				// Put the first member on the same line as the dash
+				var item strings.Builder
+				writeYAMLNode(&item, child, indent+1)
+				out.WriteString(pad + "- " + item.String()[len(pad)+2:])
+				continue
+			}
+			out.WriteString(pad + "-")
+			writeYAMLChild(out, child, indent)
+		}
+	default:
+		out.WriteString(pad + node.scalar + "\n")
+	}
+}
+
+// writeYAMLChild writes a value following a "key:" or "-" already on the line
+func writeYAMLChild(out *strings.Builder, child *yamlNode, indent int) {
+	switch {
+	case child.object && len(child.keys) == 0:
+		out.WriteString(" {}\n")
+	case child.array && len(child.values) == 0:
+		out.WriteString(" []\n")
+	case child.object || child.array:
+		out.WriteString("\n")
+		writeYAMLNode(out, child, indent+1)
+	default:
+		out.WriteString(" " + child.scalar + "\n")
+	}
+}
+
+// yamlPlainString matches strings that can be written without quotes
+var yamlPlainString = regexp.MustCompile(`^[A-Za-z_/~.][A-Za-z0-9_ ./~@()+-]*$`)
+
+// yamlReserved holds plain scalars that YAML would not read back as strings
+var yamlReserved = map[string]bool{
+	"true": true, "false": true, "yes": true, "no": true, "on": true, "off": true,
+	"null": true, "y": true, "n": true, "~": true,
+}
+
+// yamlNumber matches the plain scalars starting with a dot that YAML reads as
+// floats, such as .5, .inf and .NaN; other numbers start with a digit or sign
+var yamlNumber = regexp.MustCompile(`(?i)^\.(?:[0-9][0-9_]*(?:e[-+]?[0-9]+)?|inf|nan)$`)
+
+// yamlString formats a string scalar, quoting it when the plain form would be ambiguous
+func yamlString(s string) string {
+	if yamlPlainString.MatchString(s) && !yamlReserved[strings.ToLower(s)] && !yamlNumber.MatchString(s) && !strings.HasSuffix(s, " ") {
+		return s
+	}
+	// JSON string syntax is valid YAML double-quoted syntax
+	quoted, _ := json.Marshal(s)
+	return string(quoted)
+}
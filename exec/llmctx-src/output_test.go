@@ -0,0 +1,69 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestJSONToYAML(t *testing.T) {
+	input := `{
+  "providers": [
+    {"name": "claude", "current_version": "work", "versions": ["work", "personal"], "tags": []},
+    {"name": "yes", "current_version": "", "versions": [], "meta": {}}
+  ],
+  "count": 2,
+  "ok": true,
+  "note": "key: value # not a comment",
+  "missing": null
+}`
+	expected := `providers:
+  - name: claude
+    current_version: work
+    versions:
+      - work
+      - personal
+    tags: []
+  - name: "yes"
+    current_version: ""
+    versions: []
+    meta: {}
+count: 2
+ok: true
+note: "key: value # not a comment"
+missing: null
+`
+
+	result, err := jsonToYAML([]byte(input))
+	if err != nil {
+		t.Fatalf("jsonToYAML failed: %v", err)
+	}
+	if result != expected {
+		t.Errorf("jsonToYAML =\n%s\nwant\n%s", result, expected)
+	}
+}
+
+func TestYAMLString(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"work", "work"},
+		{"/Users/hb/.config/gh", "/Users/hb/.config/gh"},
+		{"auto-2026-10-18T10-00-00", "auto-2026-10-18T10-00-00"},
+		{"true", `"true"`},
+		{"123", `"123"`},
+		{"-dash", `"-dash"`},
+		{".5", `".5"`},
+		{".inf", `".inf"`},
+		{".NaN", `".NaN"`},
+		{".5e3", `".5e3"`},
+		{".claude", ".claude"},
+		{"", `""`},
+		{"line\nbreak", `"line\nbreak"`},
+	}
+
+	for _, tt := range tests {
+		if result := yamlString(tt.input); result != tt.expected {
+			t.Errorf("yamlString(%q) = %s, want %s", tt.input, result, tt.expected)
+		}
+	}
+}
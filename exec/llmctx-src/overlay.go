@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// overlay is a working copy of a stored version that shell sessions point the
+// provider's environment variable at, leaving the original path alone
+type overlay struct {
+	Provider string `json:"provider"`
+	Version  string `json:"version"`
+	EnvVar   string `json:"env_var"`
+	Path     string `json:"path"`
+	Created  bool   `json:"created"` // false if an existing working copy was reused
+}
+
+// getOverlayDir returns the directory holding the working copy of a version
+func getOverlayDir(providerName, versionName string) (string, error) {
+	if err := checkPathComponent("version", versionName); err != nil {
+		return "", err
+	}
+	providerDir, err := getProviderDir(providerName)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(providerDir, "overlays", versionName), nil
+}
+
+// prepareOverlay returns the working copy of a version for a shell session,
+// materializing it if it does not exist yet or refresh is set. Sessions using
+// the same version share the copy, so whatever the tool writes there, such as
+// refreshed tokens, is kept until the copy is refreshed.
+func prepareOverlay(providerName, versionName string, refresh bool) (*overlay, error) {
+	unlock, err := lockProviders()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	// Load providers config
+	config, err := loadProviders()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load providers: %w", err)
+	}
+	return overlayFor(config, providerName, versionName, refresh)
+}
+
+// overlayFor does the work of prepareOverlay for a caller holding the lock
+func overlayFor(config *ProvidersConfig, providerName, versionName string, refresh bool) (*overlay, error) {
+	provider, exists := config.Providers[providerName]
+	if !exists {
+		return nil, fmt.Errorf("provider '%s' not found", providerName)
+	}
+	if provider.EnvVar == "" {
+		return nil, fmt.Errorf("provider '%s' has no environment variable for its configuration path; set one with 'llmctx config --provider %s env-var <NAME>'", providerName, providerName)
+	}
+	if _, err := loadVersion(providerName, versionName); err != nil {
+		return nil, err
+	}
+
+	overlayDir, err := getOverlayDir(providerName, versionName)
+	if err != nil {
+		return nil, err
+	}
+	baseName := filepath.Base(provider.OriginalPath)
+	result := &overlay{
+		Provider: providerName,
+		Version:  versionName,
+		EnvVar:   provider.EnvVar,
+		Path:     filepath.Join(overlayDir, baseName),
+	}
+	if _, err := os.Lstat(result.Path); err == nil && !refresh {
+		return result, nil
+	}
+
+	// Materialize next to the working copy and move it into place, so a
+	// session never sees a partial copy
+	parentDir := filepath.Dir(overlayDir)
+	if err := os.MkdirAll(parentDir, privateDirMode); err != nil {
+		return nil, fmt.Errorf("failed to create overlay directory: %w", err)
+	}
+	stagingDir, err := os.MkdirTemp(parentDir, ".tmp-"+versionName+"-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create overlay directory: %w", err)
+	}
+	if err := materializeVersion(providerName, versionName, filepath.Join(stagingDir, baseName)); err != nil {
+		removeAll(stagingDir)
+		return nil, fmt.Errorf("failed to materialize version '%s': %w", versionName, err)
+	}
+	if err := restrictPermissions(stagingDir); err != nil {
+		removeAll(stagingDir)
+		return nil, err
+	}
+	if err := removeAll(overlayDir); err != nil {
+		removeAll(stagingDir)
+		return nil, fmt.Errorf("failed to remove old working copy: %w", err)
+	}
+	if err := os.Rename(stagingDir, overlayDir); err != nil {
+		removeAll(stagingDir)
+		return nil, fmt.Errorf("failed to move working copy into place: %w", err)
+	}
+	result.Created = true
+	return result, nil
+}
+
+// removeOverlay deletes the working copy of a version, if there is one
+func removeOverlay(providerName, versionName string) error {
+	overlayDir, err := getOverlayDir(providerName, versionName)
+	if err != nil {
+		return err
+	}
+	return removeAll(overlayDir)
+}
+
+// renameOverlay moves the working copy of a renamed version along with it
+func renameOverlay(providerName, oldName, newName string) error {
+	oldDir, err := getOverlayDir(providerName, oldName)
+	if err != nil {
+		return err
+	}
+	newDir, err := getOverlayDir(providerName, newName)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(oldDir, newDir); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// shellSyntax returns the syntax env prints for a shell: "sh" for POSIX shells
+// such as bash and zsh, or "fish". An empty shell is taken from $SHELL.
+func shellSyntax(shell string) (string, error) {
+	switch shell {
+	case "":
+		if filepath.Base(os.Getenv("SHELL")) == "fish" {
+			return "fish", nil
+		}
+		return "sh", nil
+	case "sh", "fish":
+		return shell, nil
+	}
+	return "", fmt.Errorf("invalid shell '%s': must be \"sh\" or \"fish\"", shell)
+}
+
+// shellExport returns a command setting an environment variable in the given
+// shell syntax
+func shellExport(syntax, name, value string) string {
+	if syntax == "fish" {
+		escaped := strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(value)
+		return fmt.Sprintf("set -gx %s '%s'", name, escaped)
+	}
+	return fmt.Sprintf("export %s='%s'", name, strings.ReplaceAll(value, "'", `'\''`))
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPrepareOverlay(t *testing.T) {
+	_, config, provider := setupSwitchTest(t)
+
+	if _, err := prepareOverlay(provider.Name, "personal", false); err == nil {
+		t.Fatal("Expected an error for a provider without an environment variable")
+	}
+
+	provider.EnvVar = "TEST_CONFIG"
+	config.Providers[provider.Name] = provider
+	if err := config.saveProviders(); err != nil {
+		t.Fatalf("Failed to save providers: %v", err)
+	}
+
+	result, err := prepareOverlay(provider.Name, "personal", false)
+	if err != nil {
+		t.Fatalf("prepareOverlay failed: %v", err)
+	}
+	if !result.Created || result.EnvVar != "TEST_CONFIG" || filepath.Base(result.Path) != filepath.Base(provider.OriginalPath) {
+		t.Errorf("Unexpected overlay %+v", result)
+	}
+	assertFileContent(t, result.Path, "personal")
+	assertFileContent(t, provider.OriginalPath, "work")
+	if info, _ := os.Stat(result.Path); info.Mode().Perm()&0077 != 0 {
+		t.Errorf("Working copy has mode %v, want it private", info.Mode().Perm())
+	}
+
+	// Changes made in a session are kept until the copy is refreshed
+	if err := os.WriteFile(result.Path, []byte("refreshed token"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	result, err = prepareOverlay(provider.Name, "personal", false)
+	if err != nil {
+		t.Fatalf("prepareOverlay failed: %v", err)
+	}
+	if result.Created {
+		t.Error("Expected the existing working copy to be reused")
+	}
+	assertFileContent(t, result.Path, "refreshed token")
+
+	if result, err = prepareOverlay(provider.Name, "personal", true); err != nil {
+		t.Fatalf("prepareOverlay with refresh failed: %v", err)
+	}
+	assertFileContent(t, result.Path, "personal")
+
+	// The working copy goes away with its version
+	removeVersionForce = false
+	if err := runRemoveVersion(removeVersionCmd, []string{provider.Name, "personal"}); err != nil {
+		t.Fatalf("remove-version failed: %v", err)
+	}
+	if _, err := os.Lstat(filepath.Dir(result.Path)); !os.IsNotExist(err) {
+		t.Errorf("Expected the working copy to be removed, got %v", err)
+	}
+}
+
+func TestShellExport(t *testing.T) {
+	tests := []struct {
+		syntax string
+		value  string
+		want   string
+	}{
+		{"sh", "/home/me/.llmctx/x", "export CFG='/home/me/.llmctx/x'"},
+		{"sh", "/it's here", `export CFG='/it'\''s here'`},
+		{"fish", `/it's \here`, `set -gx CFG '/it\'s \\here'`},
+	}
+	for _, tt := range tests {
+		if got := shellExport(tt.syntax, "CFG", tt.value); got != tt.want {
+			t.Errorf("shellExport(%s, %q) = %s, want %s", tt.syntax, tt.value, got, tt.want)
+		}
+	}
+
+	if _, err := shellSyntax("powershell"); err == nil {
+		t.Error("Expected an error for an unsupported shell")
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Everything under ~/.llmctx may contain credentials, so it is only
+// accessible by its owner
+const (
+	privateDirMode  os.FileMode = 0700
+	privateFileMode os.FileMode = 0600
+)
+
+// tightenPermissions removes group and other access from everything under
+// ~/.llmctx, for files written by older versions of llmctx. Symlinks are left
+// alone as their modes are not used.
+func tightenPermissions() error {
+	configDir, err := getConfigDir()
+	if err != nil {
+		return err
+	}
+	return restrictPermissions(configDir)
+}
+
+// restrictPermissions removes group and other access from everything below root
+func restrictPermissions(root string) error {
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.Type()&fs.ModeSymlink != 0 {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if mode := info.Mode().Perm(); mode&0077 != 0 {
+			if err := os.Chmod(path, mode&^0077); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to restrict permissions in '%s': %w", root, err)
+	}
+	return nil
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package main
+
+import "io/fs"
+
+// fileOwner is not available on platforms without Unix ownership
+func fileOwner(info fs.FileInfo) (int, bool) {
+	return 0, false
+}
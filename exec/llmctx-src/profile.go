@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Profile is a named set of provider versions that are switched together
+type Profile struct {
+	Versions map[string]string `json:"versions"` // provider name -> version name
+}
+
+// profileStatus describes how much of a profile is active
+const (
+	profileActive   = "active"   // every member is at the profile's version
+	profilePartial  = "partial"  // some members are
+	profileInactive = "inactive" // none are
+)
+
+// validateProfileName checks a new profile name
+func validateProfileName(name string) error {
+	return validateName("profile", name)
+}
+
+// parseProfileMembers parses provider=version arguments
+func parseProfileMembers(args []string) (map[string]string, error) {
+	members := make(map[string]string, len(args))
+	for _, arg := range args {
+		providerName, versionName, ok := strings.Cut(arg, "=")
+		if !ok || providerName == "" || versionName == "" {
+			return nil, fmt.Errorf("invalid member '%s': expected <provider>=<version>", arg)
+		}
+		if _, exists := members[providerName]; exists {
+			return nil, fmt.Errorf("provider '%s' is listed more than once", providerName)
+		}
+		members[providerName] = versionName
+	}
+	return members, nil
+}
+
+// members returns the provider names of a profile in a stable order
+func (p Profile) members() []string {
+	return slices.Sorted(maps.Keys(p.Versions))
+}
+
+// status reports whether every, some or none of the members of a profile are
+// at the profile's version
+func (p Profile) status(config *ProvidersConfig) string {
+	active := 0
+	for providerName, versionName := range p.Versions {
+		if provider, exists := config.Providers[providerName]; exists && provider.CurrentVersion == versionName {
+			active++
+		}
+	}
+	switch {
+	case active == len(p.Versions):
+		return profileActive
+	case active > 0:
+		return profilePartial
+	default:
+		return profileInactive
+	}
+}
+
+// activeProfiles returns the profiles whose members are all at the profile's version
+func (pc *ProvidersConfig) activeProfiles() []string {
+	var active []string
+	for _, name := range slices.Sorted(maps.Keys(pc.Profiles)) {
+		if pc.Profiles[name].status(pc) == profileActive {
+			active = append(active, name)
+		}
+	}
+	return active
+}
+
+// renameProfileProvider updates the profiles that include a renamed provider
+func (pc *ProvidersConfig) renameProfileProvider(oldName, newName string) {
+	for _, profile := range pc.Profiles {
+		if version, ok := profile.Versions[oldName]; ok {
+			delete(profile.Versions, oldName)
+			profile.Versions[newName] = version
+		}
+	}
+}
+
+// renameProfileVersion updates the profiles that include a renamed version
+func (pc *ProvidersConfig) renameProfileVersion(providerName, oldName, newName string) {
+	for _, profile := range pc.Profiles {
+		if profile.Versions[providerName] == oldName {
+			profile.Versions[providerName] = newName
+		}
+	}
+}
+
+// profilesUsing returns the profiles that switch a provider to the given
+// version, or to any version when versionName is empty
+func (pc *ProvidersConfig) profilesUsing(providerName, versionName string) []string {
+	var names []string
+	for _, name := range slices.Sorted(maps.Keys(pc.Profiles)) {
+		version, ok := pc.Profiles[name].Versions[providerName]
+		if ok && (versionName == "" || version == versionName) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// retentionView returns a provider with the versions its profiles use added
+// to its pins, so retention never deletes a version a profile switches to
+func (pc *ProvidersConfig) retentionView(providerName string) Provider {
+	provider := pc.Providers[providerName]
+	pinned := slices.Clone(provider.Pinned)
+	for _, name := range pc.profilesUsing(providerName, "") {
+		if version := pc.Profiles[name].Versions[providerName]; !slices.Contains(pinned, version) {
+			pinned = append(pinned, version)
+		}
+	}
+	provider.Pinned = pinned
+	return provider
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// setupProfileTest adds a second provider to the switch test setup and a
+// profile switching both providers away from their current versions
+func setupProfileTest(t *testing.T) (string, *ProvidersConfig) {
+	tempDir, config, _ := setupSwitchTest(t)
+
+	other := Provider{Name: "other", OriginalPath: filepath.Join(tempDir, "other.yaml"), Type: "file", CurrentVersion: "home"}
+	for _, version := range []string{"corp", "home"} {
+		if err := os.WriteFile(other.OriginalPath, []byte(version), 0644); err != nil {
+			t.Fatalf("Failed to create live file: %v", err)
+		}
+		if err := saveVersion(other, version); err != nil {
+			t.Fatalf("Failed to store version: %v", err)
+		}
+	}
+	config.Providers[other.Name] = other
+	config.Profiles = map[string]Profile{"office": {Versions: map[string]string{"test-provider": "personal", "other": "corp"}}}
+	if err := config.saveProviders(); err != nil {
+		t.Fatalf("Failed to save providers: %v", err)
+	}
+	return tempDir, config
+}
+
+// assertLive checks the live content and current version of each provider
+func assertLive(t *testing.T, want map[string]string) {
+	t.Helper()
+	config, err := loadProviders()
+	if err != nil {
+		t.Fatalf("loadProviders failed: %v", err)
+	}
+	for name, version := range want {
+		provider := config.Providers[name]
+		if provider.CurrentVersion != version {
+			t.Errorf("Current version of %s = %s, want %s", name, provider.CurrentVersion, version)
+		}
+		content, err := os.ReadFile(provider.OriginalPath)
+		if err != nil || string(content) != version {
+			t.Errorf("Live content of %s = %q (%v), want %q", name, content, err, version)
+		}
+	}
+}
+
+func TestProfileUse(t *testing.T) {
+	_, config := setupProfileTest(t)
+
+	if got := config.Profiles["office"].status(config); got != profileInactive {
+		t.Errorf("Status before use = %s, want %s", got, profileInactive)
+	}
+
+	if err := runProfileUse(profileUseCmd, []string{"office"}); err != nil {
+		t.Fatalf("profile use failed: %v", err)
+	}
+	assertLive(t, map[string]string{"test-provider": "personal", "other": "corp"})
+
+	config, _ = loadProviders()
+	if got := config.activeProfiles(); len(got) != 1 || got[0] != "office" {
+		t.Errorf("Active profiles = %v, want [office]", got)
+	}
+
+	// Each switch can be undone on its own
+	events, _ := loadHistory()
+	if last, ok := lastSwitch(events, "other"); !ok || last.PreviousVersion != "home" {
+		t.Errorf("Last switch of other = %+v, want one from home", last)
+	}
+
+	// Using an active profile changes nothing
+	if err := runProfileUse(profileUseCmd, []string{"office"}); err != nil {
+		t.Fatalf("profile use of active profile failed: %v", err)
+	}
+}
+
+func TestProfileUseRollsBack(t *testing.T) {
+	_, config := setupProfileTest(t)
+
+	// test-provider is switched first, then staging other fails
+	tree, err := versionTree("other", "corp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	objectPath, _ := getObjectPath(tree[""].Digest)
+	if err := os.Remove(objectPath); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runProfileUse(profileUseCmd, []string{"office"}); err == nil {
+		t.Fatal("Expected profile use to fail")
+	}
+	assertLive(t, map[string]string{"test-provider": "work", "other": "home"})
+	if got := config.Profiles["office"].status(config); got != profileInactive {
+		t.Errorf("Status after failed use = %s, want %s", got, profileInactive)
+	}
+}
+
+func TestProfileTransactionRecovery(t *testing.T) {
+	for _, committed := range []bool{false, true} {
+		t.Run(map[bool]string{false: "uncommitted", true: "committed"}[committed], func(t *testing.T) {
+			_, config := setupProfileTest(t)
+
+			// Simulate a crash after both providers were swapped in
+			transaction := &switchTransaction{ID: "txn-1", Committed: committed}
+			if err := transaction.write(); err != nil {
+				t.Fatal(err)
+			}
+			for name, version := range config.Profiles["office"].Versions {
+				if _, err := swapInVersion(config.Providers[name], version, transaction.ID); err != nil {
+					t.Fatalf("swapInVersion failed: %v", err)
+				}
+			}
+
+			if err := recoverInterruptedSwitches(); err != nil {
+				t.Fatalf("recoverInterruptedSwitches failed: %v", err)
+			}
+			if committed {
+				assertLive(t, map[string]string{"test-provider": "personal", "other": "corp"})
+			} else {
+				assertLive(t, map[string]string{"test-provider": "work", "other": "home"})
+			}
+
+			journalDir, _ := getJournalDir()
+			if entries, _ := os.ReadDir(journalDir); len(entries) != 0 {
+				t.Errorf("Expected all journals to be removed, found %d", len(entries))
+			}
+		})
+	}
+}
@@ -1,24 +1,209 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 )
 
+// currentSchemaVersion is the providers.json schema written by this build
+const currentSchemaVersion = 11
+
+// Schema versions whose upgrade changes files besides providers.json
+const (
+	schemaObjectStore        = 5 // stored versions moved into the object store
+	schemaPrivatePermissions = 6 // everything under ~/.llmctx made private
+)
+
+// migration upgrades the raw top-level members of providers.json by one schema version
+type migration func(raw map[string]json.RawMessage) error
+
+// migrations[i] upgrades a file from schema version i to i+1. A new field that
+// older builds would not honor gets a version of its own, so they refuse to
+// write files that use it.
+var migrations = []migration{
+	// Version 0 files predate the schema_version field and need no other changes
+	func(raw map[string]json.RawMessage) error { return nil },
+	// Version 2 adds version metadata (versions) to providers
+	func(raw map[string]json.RawMessage) error { return nil },
+	// Version 3 adds settings, and the auto flag to version metadata
+	func(raw map[string]json.RawMessage) error { return nil },
+	// Version 4 adds pinned and retention to providers
+	func(raw map[string]json.RawMessage) error { return nil },
+	// Version 5 keeps stored versions in the object store; the files are
+	// converted by migrateVersionStore when the upgraded file is saved
+	func(raw map[string]json.RawMessage) error { return nil },
+	// Version 6 makes everything under ~/.llmctx private to its owner; the
+	// permissions are changed by tightenPermissions when the file is saved
+	func(raw map[string]json.RawMessage) error { return nil },
+	// Version 7 adds profiles
+	func(raw map[string]json.RawMessage) error { return nil },
+	// Version 8 adds env_var to providers
+	func(raw map[string]json.RawMessage) error { return nil },
+	// Version 9 adds activation to providers
+	func(raw map[string]json.RawMessage) error { return nil },
+	// Version 10 adds capture_on_leave to providers
+	func(raw map[string]json.RawMessage) error { return nil },
+	// Version 11 adds revision_limit to settings
+	func(raw map[string]json.RawMessage) error { return nil },
+}
+
 // Provider represents a managed configuration provider
 type Provider struct {
 	Name           string `json:"name"`
 	OriginalPath   string `json:"original_path"`
 	Type           string `json:"type"` // "file" or "directory"
 	CurrentVersion string `json:"current_version"`
+	Symlinks       string `json:"symlinks,omitempty"`         // "copy" (default), "follow" or "reject"
+	EnvVar         string `json:"env_var,omitempty"`          // environment variable the tool reads its configuration path from
+	Activation     string `json:"activation,omitempty"`       // "copy" (default) or "symlink"
+	CaptureOnLeave bool   `json:"capture_on_leave,omitempty"` // save live changes into the active version when switching away
+
+	// Versions holds metadata of stored versions, keyed by version name
+	Versions map[string]VersionMeta `json:"versions,omitempty"`
+
+	Pinned    []string         `json:"pinned,omitempty"`    // versions gc never deletes
+	Retention *RetentionPolicy `json:"retention,omitempty"` // limits applied by gc
+
+	// Extra holds fields written by newer versions of llmctx so they survive a save
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// providerFields has the fields of Provider without its JSON methods
+type providerFields Provider
+
+// MarshalJSON encodes the provider, including any preserved unknown fields
+func (p Provider) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(providerFields(p))
+	if err != nil {
+		return nil, err
+	}
+	return appendUnknownFields(data, p.Extra)
+}
+
+// UnmarshalJSON decodes the provider, keeping fields this build does not know
+func (p *Provider) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, (*providerFields)(p)); err != nil {
+		return err
+	}
+	extra, err := unknownFields(data, providerFields{})
+	p.Extra = extra
+	return err
+}
+
+// symlinkPolicy returns how symlinks inside the provider's directory are copied
+func (p Provider) symlinkPolicy() SymlinkPolicy {
+	policy, err := parseSymlinkPolicy(p.Symlinks)
+	if err != nil {
+		return SymlinkReject
+	}
+	return policy
 }
 
 // ProvidersConfig holds all managed providers
 type ProvidersConfig struct {
-	Providers map[string]Provider `json:"providers"`
+	SchemaVersion int                 `json:"schema_version"`
+	Providers     map[string]Provider `json:"providers"`
+	Settings      *Settings           `json:"settings,omitempty"`
+	Profiles      map[string]Profile  `json:"profiles,omitempty"`
+
+	// Extra holds fields written by newer versions of llmctx so they survive a save
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// providersConfigFields has the fields of ProvidersConfig without its JSON methods
+type providersConfigFields ProvidersConfig
+
+// MarshalJSON encodes the config, including any preserved unknown fields
+func (pc ProvidersConfig) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(providersConfigFields(pc))
+	if err != nil {
+		return nil, err
+	}
+	return appendUnknownFields(data, pc.Extra)
+}
+
+// UnmarshalJSON decodes the config, keeping fields this build does not know
+func (pc *ProvidersConfig) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, (*providersConfigFields)(pc)); err != nil {
+		return err
+	}
+	extra, err := unknownFields(data, providersConfigFields{})
+	pc.Extra = extra
+	return err
+}
+
+// unknownFields returns the members of a JSON object that do not map to a field of v
+func unknownFields(data []byte, v any) (map[string]json.RawMessage, error) {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return nil, err
+	}
+
+	// encoding/json matches member names case-insensitively
+	known := make(map[string]bool)
+	t := reflect.TypeOf(v)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		known[strings.ToLower(name)] = true
+	}
+
+	for name, value := range members {
+		if known[strings.ToLower(name)] {
+			delete(members, name)
+			continue
+		}
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, value); err != nil {
+			return nil, err
+		}
+		members[name] = compact.Bytes()
+	}
+	if len(members) == 0 {
+		return nil, nil
+	}
+	return members, nil
+}
+
+// appendUnknownFields adds preserved members to the end of an encoded JSON object
+func appendUnknownFields(data []byte, extra map[string]json.RawMessage) ([]byte, error) {
+	if len(extra) == 0 {
+		return data, nil
+	}
+
+	names := make([]string, 0, len(extra))
+	for name := range extra {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	out := append([]byte{}, data[:len(data)-1]...)
+	for _, name := range names {
+		if len(out) > 1 {
+			out = append(out, ',')
+		}
+		key, err := json.Marshal(name)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, key...)
+		out = append(out, ':')
+		out = append(out, extra[name]...)
+	}
+	return append(out, '}'), nil
 }
 
 // getConfigDir returns the base configuration directory
@@ -39,7 +224,17 @@ func getProvidersFilePath() (string, error) {
 	return filepath.Join(configDir, "providers.json"), nil
 }
 
-// loadProviders loads the providers configuration from disk
+// getBackupFilePath returns the path of the last known good copy of providers.json
+func getBackupFilePath() (string, error) {
+	providersFile, err := getProvidersFilePath()
+	if err != nil {
+		return "", err
+	}
+	return providersFile + ".bak", nil
+}
+
+// loadProviders loads the providers configuration from disk. If providers.json
+// cannot be parsed, the last known good copy is used instead with a warning.
 func loadProviders() (*ProvidersConfig, error) {
 	providersFile, err := getProvidersFilePath()
 	if err != nil {
@@ -51,14 +246,67 @@ func loadProviders() (*ProvidersConfig, error) {
 		return &ProvidersConfig{Providers: make(map[string]Provider)}, nil
 	}
 
-	data, err := os.ReadFile(providersFile)
+	config, err := readProvidersFile(providersFile)
+	if err == nil {
+		return config, nil
+	}
+
+	backupFile, backupErr := getBackupFilePath()
+	if backupErr != nil {
+		return nil, err
+	}
+	backup, backupErr := readProvidersFile(backupFile)
+	if backupErr != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(os.Stderr, "Warning: %v; using last known good copy from '%s'\n", err, backupFile)
+	return backup, nil
+}
+
+// readProvidersFile reads and parses a providers configuration file, migrating
+// older schema versions in memory
+func readProvidersFile(path string) (*ProvidersConfig, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read providers file: %w", err)
 	}
 
+	config, err := parseProviders(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse providers file: %w", err)
+	}
+	return config, nil
+}
+
+// parseProviders decodes providers.json content, applying any migrations needed
+// to bring it up to currentSchemaVersion
+func parseProviders(data []byte) (*ProvidersConfig, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	version, err := schemaVersionOf(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	if version < currentSchemaVersion {
+		for v := version; v < currentSchemaVersion; v++ {
+			if err := migrations[v](raw); err != nil {
+				return nil, fmt.Errorf("failed to migrate from schema version %d: %w", v, err)
+			}
+		}
+		raw["schema_version"] = json.RawMessage(strconv.Itoa(currentSchemaVersion))
+		if data, err = json.Marshal(raw); err != nil {
+			return nil, err
+		}
+	}
+
 	var config ProvidersConfig
 	if err := json.Unmarshal(data, &config); err != nil {
-		return nil, fmt.Errorf("failed to parse providers file: %w", err)
+		return nil, err
 	}
 
 	if config.Providers == nil {
@@ -68,7 +316,98 @@ func loadProviders() (*ProvidersConfig, error) {
 	return &config, nil
 }
 
-// saveProviders saves the providers configuration to disk
+// schemaVersionOf returns the schema version recorded in raw providers.json members
+func schemaVersionOf(raw map[string]json.RawMessage) (int, error) {
+	value, ok := raw["schema_version"]
+	if !ok {
+		return 0, nil
+	}
+	var version int
+	if err := json.Unmarshal(value, &version); err != nil {
+		return 0, fmt.Errorf("invalid schema_version: %w", err)
+	}
+	if version < 0 {
+		return 0, fmt.Errorf("invalid schema_version %d", version)
+	}
+	return version, nil
+}
+
+// upgradeProvidersFile rewrites a providers.json created by an older llmctx in
+// the current schema, keeping the original as providers.json.v<N>.bak
+func upgradeProvidersFile() error {
+	providersFile, err := getProvidersFilePath()
+	if err != nil {
+		return err
+	}
+
+	needsUpgrade := func() ([]byte, int, bool) {
+		data, err := os.ReadFile(providersFile)
+		if err != nil {
+			return nil, 0, false
+		}
+		var raw map[string]json.RawMessage
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return nil, 0, false
+		}
+		version, err := schemaVersionOf(raw)
+		if err != nil || version >= currentSchemaVersion {
+			return nil, 0, false
+		}
+		return data, version, true
+	}
+
+	// Unreadable or corrupt files are left for loadProviders to report
+	if _, _, ok := needsUpgrade(); !ok {
+		return nil
+	}
+
+	unlock, err := lockProviders()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// Another process may have upgraded the file while we waited for the lock
+	data, version, ok := needsUpgrade()
+	if !ok {
+		return nil
+	}
+
+	config, err := parseProviders(data)
+	if err != nil {
+		return fmt.Errorf("failed to migrate providers file: %w", err)
+	}
+
+	// The version files must be converted before the new schema is recorded
+	if version < schemaObjectStore {
+		if err := migrateVersionStore(config); err != nil {
+			return err
+		}
+	}
+
+	if version < schemaPrivatePermissions {
+		if err := tightenPermissions(); err != nil {
+			return err
+		}
+	}
+
+	backupFile := fmt.Sprintf("%s.v%d.bak", providersFile, version)
+	if err := writeFileAtomic(backupFile, data, privateFileMode); err != nil {
+		return fmt.Errorf("failed to back up providers file before migration: %w", err)
+	}
+
+	if err := config.saveProviders(); err != nil {
+		return fmt.Errorf("failed to save migrated providers file: %w", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Upgraded '%s' from schema version %d to %d (previous copy kept at '%s')\n", providersFile, version, currentSchemaVersion, backupFile)
+	return nil
+}
+
+// saveProviders saves the providers configuration to disk. Configurations read
+// from a newer schema version are refused. The file is written
+// to a temporary file and renamed into place, and the result is also kept as
+// the last known good copy.
 func (pc *ProvidersConfig) saveProviders() error {
 	configDir, err := getConfigDir()
 	if err != nil {
@@ -76,10 +415,16 @@ func (pc *ProvidersConfig) saveProviders() error {
 	}
 
 	// Ensure config directory exists
-	if err := os.MkdirAll(configDir, 0755); err != nil {
+	if err := os.MkdirAll(configDir, privateDirMode); err != nil {
 		return fmt.Errorf("failed to create config directory: %w", err)
 	}
 
+	// Writing would drop or misinterpret fields this build does not understand
+	if pc.SchemaVersion > currentSchemaVersion {
+		return fmt.Errorf("providers file uses schema version %d, which is newer than this llmctx supports (%d); upgrade llmctx to modify it", pc.SchemaVersion, currentSchemaVersion)
+	}
+	pc.SchemaVersion = currentSchemaVersion
+
 	providersFile, err := getProvidersFilePath()
 	if err != nil {
 		return err
@@ -90,10 +435,52 @@ func (pc *ProvidersConfig) saveProviders() error {
 		return fmt.Errorf("failed to marshal providers config: %w", err)
 	}
 
-	if err := os.WriteFile(providersFile, data, 0644); err != nil {
+	if err := writeFileAtomic(providersFile, data, privateFileMode); err != nil {
 		return fmt.Errorf("failed to write providers file: %w", err)
 	}
 
+	backupFile, err := getBackupFilePath()
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(backupFile, data, privateFileMode); err != nil {
+		return fmt.Errorf("failed to write providers backup: %w", err)
+	}
+
+	return nil
+}
+
+// writeFileAtomic writes data to a temporary file next to path, syncs it and
+// renames it over path so readers never observe a partially written file
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
 	return nil
 }
 
@@ -109,20 +496,35 @@ func expandPath(path string) (string, error) {
 	return path, nil
 }
 
+// getProviderDir returns the directory holding everything stored for a provider
+func getProviderDir(providerName string) (string, error) {
+	if err := checkPathComponent("provider", providerName); err != nil {
+		return "", err
+	}
+	configDir, err := getConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "providers", providerName), nil
+}
+
 // getVersionDir returns the directory path for storing versions of a provider
 func getVersionDir(providerName string) (string, error) {
-	configDir, err := getConfigDir()
+	providerDir, err := getProviderDir(providerName)
 	if err != nil {
 		return "", err
 	}
-	return filepath.Join(configDir, "providers", providerName, "versions"), nil
+	return filepath.Join(providerDir, "versions"), nil
 }
 
 // getVersionPath returns the full path for a specific version of a provider
 func getVersionPath(providerName, versionName string) (string, error) {
+	if err := checkPathComponent("version", versionName); err != nil {
+		return "", err
+	}
 	versionDir, err := getVersionDir(providerName)
 	if err != nil {
 		return "", err
 	}
 	return filepath.Join(versionDir, versionName), nil
-}
\ No newline at end of file
+}
@@ -1,9 +1,12 @@
 package main
 
 import (
+	"encoding/json"
 	"os"
 	"path/filepath"
+	"strconv"
 	"testing"
+	"time"
 )
 
 func TestExpandPath(t *testing.T) {
@@ -172,4 +175,186 @@ func TestGetVersionPath(t *testing.T) {
 	if versionPath != expected {
 		t.Errorf("getVersionPath() = %q, want %q", versionPath, expected)
 	}
-}
\ No newline at end of file
+}
+
+func TestLoadProvidersFallsBackToBackup(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "llmctx-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Override home directory for testing
+	originalHome := os.Getenv("HOME")
+	os.Setenv("HOME", tempDir)
+	defer os.Setenv("HOME", originalHome)
+
+	config := &ProvidersConfig{
+		Providers: map[string]Provider{
+			"test-provider": {Name: "test-provider", OriginalPath: "/test/path", Type: "file", CurrentVersion: "v1"},
+		},
+	}
+	if err := config.saveProviders(); err != nil {
+		t.Fatalf("saveProviders failed: %v", err)
+	}
+
+	// Simulate a torn write of providers.json
+	providersFile, err := getProvidersFilePath()
+	if err != nil {
+		t.Fatalf("getProvidersFilePath failed: %v", err)
+	}
+	if err := os.WriteFile(providersFile, []byte(`{"providers": {"test-pro`), 0644); err != nil {
+		t.Fatalf("Failed to corrupt providers file: %v", err)
+	}
+
+	loaded, err := loadProviders()
+	if err != nil {
+		t.Fatalf("loadProviders failed: %v", err)
+	}
+	if loaded.Providers["test-provider"].CurrentVersion != "v1" {
+		t.Errorf("Expected provider from backup, got %+v", loaded.Providers)
+	}
+
+	// Without a usable backup the parse error is reported
+	backupFile, err := getBackupFilePath()
+	if err != nil {
+		t.Fatalf("getBackupFilePath failed: %v", err)
+	}
+	if err := os.Remove(backupFile); err != nil {
+		t.Fatalf("Failed to remove backup: %v", err)
+	}
+	if _, err := loadProviders(); err == nil {
+		t.Error("Expected error for corrupt providers file without backup")
+	}
+}
+
+func TestLockProviders(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "llmctx-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Override home directory for testing
+	originalHome := os.Getenv("HOME")
+	os.Setenv("HOME", tempDir)
+	defer os.Setenv("HOME", originalHome)
+
+	originalTimeout := lockTimeout
+	lockTimeout = 200 * time.Millisecond
+	defer func() { lockTimeout = originalTimeout }()
+
+	unlock, err := lockProviders()
+	if err != nil {
+		t.Fatalf("lockProviders failed: %v", err)
+	}
+
+	if _, err := lockProviders(); err == nil {
+		t.Error("Expected second lock to time out while the first is held")
+	}
+
+	unlock()
+
+	unlockAgain, err := lockProviders()
+	if err != nil {
+		t.Fatalf("Expected lock to be available after release: %v", err)
+	}
+	unlockAgain()
+}
+
+func TestProvidersSchemaMigration(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "llmctx-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Override home directory for testing
+	originalHome := os.Getenv("HOME")
+	os.Setenv("HOME", tempDir)
+	defer os.Setenv("HOME", originalHome)
+
+	providersFile, err := getProvidersFilePath()
+	if err != nil {
+		t.Fatalf("getProvidersFilePath failed: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(providersFile), 0755); err != nil {
+		t.Fatalf("Failed to create config dir: %v", err)
+	}
+
+	// A file written before schema_version existed, with fields from the future
+	legacy := `{
+  "providers": {
+    "claude": {"name": "claude", "original_path": "/x", "type": "file", "current_version": "work", "tags": ["a"]}
+  },
+  "hooks": {"post_switch": "echo hi"}
+}`
+	if err := os.WriteFile(providersFile, []byte(legacy), 0644); err != nil {
+		t.Fatalf("Failed to write legacy providers file: %v", err)
+	}
+
+	t.Run("upgrades in place with a backup", func(t *testing.T) {
+		if err := upgradeProvidersFile(); err != nil {
+			t.Fatalf("upgradeProvidersFile failed: %v", err)
+		}
+
+		backup, err := os.ReadFile(providersFile + ".v0.bak")
+		if err != nil {
+			t.Fatalf("Expected backup of the legacy file: %v", err)
+		}
+		if string(backup) != legacy {
+			t.Error("Backup does not match the legacy file")
+		}
+
+		var raw map[string]json.RawMessage
+		data, err := os.ReadFile(providersFile)
+		if err != nil {
+			t.Fatalf("Failed to read upgraded file: %v", err)
+		}
+		if err := json.Unmarshal(data, &raw); err != nil {
+			t.Fatalf("Upgraded file is not valid JSON: %v", err)
+		}
+		if string(raw["schema_version"]) != strconv.Itoa(currentSchemaVersion) {
+			t.Errorf("schema_version = %s, want %d", raw["schema_version"], currentSchemaVersion)
+		}
+	})
+
+	t.Run("preserves unknown fields round-trip", func(t *testing.T) {
+		config, err := loadProviders()
+		if err != nil {
+			t.Fatalf("loadProviders failed: %v", err)
+		}
+		if err := config.saveProviders(); err != nil {
+			t.Fatalf("saveProviders failed: %v", err)
+		}
+
+		reloaded, err := loadProviders()
+		if err != nil {
+			t.Fatalf("loadProviders failed: %v", err)
+		}
+		if string(reloaded.Extra["hooks"]) != `{"post_switch":"echo hi"}` {
+			t.Errorf("Top-level unknown field lost: %v", reloaded.Extra)
+		}
+		if string(reloaded.Providers["claude"].Extra["tags"]) != `["a"]` {
+			t.Errorf("Provider unknown field lost: %v", reloaded.Providers["claude"].Extra)
+		}
+	})
+
+	t.Run("refuses to write a newer schema", func(t *testing.T) {
+		newer := `{"schema_version": 99, "providers": {}}`
+		if err := os.WriteFile(providersFile, []byte(newer), 0644); err != nil {
+			t.Fatalf("Failed to write providers file: %v", err)
+		}
+
+		config, err := loadProviders()
+		if err != nil {
+			t.Fatalf("Expected newer file to remain readable: %v", err)
+		}
+		if err := config.saveProviders(); err == nil {
+			t.Error("Expected saveProviders to refuse a newer schema")
+		}
+	})
+}
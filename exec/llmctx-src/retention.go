@@ -0,0 +1,283 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetentionPolicy limits how many stored versions of a provider gc keeps.
+// Zero values mean no limit, except KeepAuto which falls back to the global
+// auto-snapshot-limit setting.
+type RetentionPolicy struct {
+	KeepAuto int    `json:"keep_auto,omitempty"` // auto-snapshots kept
+	MaxAge   string `json:"max_age,omitempty"`   // versions last saved longer ago are deleted, e.g. "30d"
+	MaxSize  string `json:"max_size,omitempty"`  // space taken by stored versions, shared content counted once, e.g. "500MB"
+
+	// PruneNamed lets max-age and max-size delete versions saved by name, not
+	// only auto-snapshots
+	PruneNamed bool `json:"prune_named,omitempty"`
+}
+
+// Reasons reported by gc for deleting a version
+const (
+	reasonKeepAuto = "keep-auto"
+	reasonMaxAge   = "max-age"
+	reasonMaxSize  = "max-size"
+)
+
+// gcCandidate is a stored version selected for deletion by the retention policy
+type gcCandidate struct {
+	Provider string `json:"provider"`
+	Version  string `json:"version"`
+	Reason   string `json:"reason"`
+	Size     int64  `json:"size"`
+}
+
+// retention returns the provider's retention policy, which may not be stored yet
+func (p Provider) retention() RetentionPolicy {
+	if p.Retention == nil {
+		return RetentionPolicy{}
+	}
+	return *p.Retention
+}
+
+// setRetention stores a retention policy, dropping it when it sets no limits
+func (p *Provider) setRetention(policy RetentionPolicy) {
+	if policy == (RetentionPolicy{}) {
+		p.Retention = nil
+		return
+	}
+	p.Retention = &policy
+}
+
+// keepAuto returns how many auto-snapshots of the provider are kept
+func (p Provider) keepAuto(settings Settings) int {
+	if p.Retention != nil && p.Retention.KeepAuto > 0 {
+		return p.Retention.KeepAuto
+	}
+	return settings.autoSnapshotLimit()
+}
+
+// isPinned reports whether a version is protected from gc
+func (p Provider) isPinned(versionName string) bool {
+	return slices.Contains(p.Pinned, versionName)
+}
+
+// isProtected reports whether a version must never be deleted by retention
+func (p Provider) isProtected(versionName string) bool {
+	return versionName == p.CurrentVersion || p.isPinned(versionName)
+}
+
+// parseAge parses a duration such as "36h", "30d" or "2w"
+func parseAge(value string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if number, ok := strings.CutSuffix(value, suffix); ok {
+			n, err := strconv.Atoi(number)
+			if err != nil || n < 0 {
+				return 0, fmt.Errorf("invalid age '%s'", value)
+			}
+			return time.Duration(n) * unit, nil
+		}
+	}
+
+	age, err := time.ParseDuration(value)
+	if err != nil || age < 0 {
+		return 0, fmt.Errorf("invalid age '%s': use a number followed by h, d or w", value)
+	}
+	return age, nil
+}
+
+// parseSize parses a byte count such as "1048576", "512KB" or "1.5G"
+func parseSize(value string) (int64, error) {
+	units := []struct {
+		suffix string
+		size   float64
+	}{
+		{"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10},
+		{"T", 1 << 40}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1},
+	}
+
+	number, multiplier := strings.ToUpper(strings.TrimSpace(value)), float64(1)
+	for _, unit := range units {
+		if rest, ok := strings.CutSuffix(number, unit.suffix); ok {
+			number, multiplier = strings.TrimSpace(rest), unit.size
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size '%s': use a number optionally followed by KB, MB, GB or TB", value)
+	}
+	return int64(n * multiplier), nil
+}
+
+// excessAutoSnapshots returns the auto-snapshots of a provider older than the
+// newest limit ones, skipping the active and pinned versions
+func excessAutoSnapshots(provider Provider, limit int) []string {
+	snapshots := autoSnapshots(provider)
+	if len(snapshots) <= limit {
+		return nil
+	}
+
+	var names []string
+	for _, name := range snapshots[:len(snapshots)-limit] {
+		if !provider.isProtected(name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// planRetention selects the stored versions of a provider that its retention
+// policy deletes. The active and pinned versions are never selected. Age and
+// size only select auto-snapshots unless the policy opts in to pruning named
+// versions; versions without metadata have no known age and are then only
+// deleted for size.
+func planRetention(provider Provider, settings Settings, now time.Time) ([]gcCandidate, error) {
+	policy := provider.retention()
+
+	var maxAge time.Duration
+	if policy.MaxAge != "" {
+		age, err := parseAge(policy.MaxAge)
+		if err != nil {
+			return nil, err
+		}
+		maxAge = age
+	}
+	var maxSize int64
+	if policy.MaxSize != "" {
+		size, err := parseSize(policy.MaxSize)
+		if err != nil {
+			return nil, err
+		}
+		maxSize = size
+	}
+
+	versions, err := getAvailableVersions(provider.Name)
+	if err != nil {
+		return nil, err
+	}
+	trees := make(map[string]treeManifest)
+	for _, version := range versions {
+		tree, err := versionTree(provider.Name, version)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read version '%s': %w", version, err)
+		}
+		trees[version] = tree
+	}
+
+	// Content shared between versions is stored once, so the total is
+	// recomputed over the remaining versions after every deletion
+	remainingSize := func() int64 {
+		var kept []treeManifest
+		for _, tree := range trees {
+			kept = append(kept, tree)
+		}
+		return storedSize(kept...)
+	}
+
+	var planned []gcCandidate
+	selected := make(map[string]bool)
+	selectVersion := func(version, reason string) {
+		planned = append(planned, gcCandidate{Provider: provider.Name, Version: version, Reason: reason, Size: manifestSize(trees[version])})
+		selected[version] = true
+		delete(trees, version)
+	}
+
+	for _, version := range excessAutoSnapshots(provider, provider.keepAuto(settings)) {
+		if _, stored := trees[version]; stored {
+			selectVersion(version, reasonKeepAuto)
+		}
+	}
+
+	// Remaining deletable versions, auto-snapshots first, then oldest first. A
+	// named version may be someone's only copy of a credential set, so age
+	// and size alone do not delete it.
+	var remaining []string
+	for _, version := range versions {
+		if !selected[version] && !provider.isProtected(version) && (policy.PruneNamed || provider.Versions[version].Auto) {
+			remaining = append(remaining, version)
+		}
+	}
+	sort.SliceStable(remaining, func(i, j int) bool {
+		a, aok := provider.Versions[remaining[i]]
+		b, bok := provider.Versions[remaining[j]]
+		if a.Auto != b.Auto {
+			return a.Auto
+		}
+		if aok != bok {
+			return aok
+		}
+		return a.UpdatedAt.Before(b.UpdatedAt)
+	})
+
+	if maxAge > 0 {
+		for _, version := range remaining {
+			if meta, ok := provider.Versions[version]; ok && now.Sub(meta.UpdatedAt) > maxAge {
+				selectVersion(version, reasonMaxAge)
+			}
+		}
+	}
+
+	if maxSize > 0 {
+		for _, version := range remaining {
+			if remainingSize() <= maxSize {
+				break
+			}
+			if !selected[version] {
+				selectVersion(version, reasonMaxSize)
+			}
+		}
+	}
+
+	return planned, nil
+}
+
+// removeVersions deletes stored versions of a provider, unregisters them and
+// returns the names actually removed. Versions deleted before a failure are
+// still unregistered.
+func removeVersions(config *ProvidersConfig, providerName string, names []string) ([]string, error) {
+	if err := checkProviderIdle(providerName); err != nil {
+		return nil, err
+	}
+	provider := config.Providers[providerName]
+
+	var removed []string
+	var removeErr error
+	for _, name := range names {
+		versionPath, err := getVersionPath(providerName, name)
+		if err == nil {
+			err = removeAll(versionPath)
+		}
+		if err != nil {
+			removeErr = fmt.Errorf("failed to remove version '%s': %w", name, err)
+			break
+		}
+		if err := removeOverlay(providerName, name); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to remove the working copy of '%s': %v\n", name, err)
+		}
+		if err := removeRevisions(providerName, name); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to remove the revisions of '%s': %v\n", name, err)
+		}
+		delete(provider.Versions, name)
+		removed = append(removed, name)
+	}
+	if len(removed) == 0 {
+		return nil, removeErr
+	}
+
+	config.Providers[providerName] = provider
+	if err := config.saveProviders(); err != nil {
+		return nil, fmt.Errorf("failed to save providers config: %w", err)
+	}
+	for _, name := range removed {
+		recordHistory(historyEvent{Action: historyRemoveVersion, Provider: providerName, Version: name})
+	}
+	return removed, removeErr
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestParseAgeAndSize(t *testing.T) {
+	ages := map[string]time.Duration{"36h": 36 * time.Hour, "30d": 30 * 24 * time.Hour, "2w": 14 * 24 * time.Hour, "0": 0}
+	for value, want := range ages {
+		if got, err := parseAge(value); err != nil || got != want {
+			t.Errorf("parseAge(%q) = %v, %v; want %v", value, got, err, want)
+		}
+	}
+	if _, err := parseAge("soon"); err == nil {
+		t.Error("Expected error for an invalid age")
+	}
+
+	sizes := map[string]int64{"100": 100, "512KB": 512 << 10, "1.5G": 3 << 29, "2 mb": 2 << 20}
+	for value, want := range sizes {
+		if got, err := parseSize(value); err != nil || got != want {
+			t.Errorf("parseSize(%q) = %v, %v; want %v", value, got, err, want)
+		}
+	}
+	if _, err := parseSize("-1MB"); err == nil {
+		t.Error("Expected error for a negative size")
+	}
+}
+
+func TestPlanRetention(t *testing.T) {
+	_, config, provider := setupSwitchTest(t)
+
+	// Three auto-snapshots, a day apart, next to the fixture's work and personal versions
+	now := time.Now()
+	for i, name := range []string{"auto-1", "auto-2", "auto-3"} {
+		if _, err := captureVersion(config, &provider, name, ""); err != nil {
+			t.Fatalf("captureVersion failed: %v", err)
+		}
+		meta := provider.Versions[name]
+		meta.Auto = true
+		meta.UpdatedAt = now.Add(time.Duration(i-3) * 24 * time.Hour)
+		meta.CreatedAt = meta.UpdatedAt
+		provider.Versions[name] = meta
+	}
+	provider.Pinned = []string{"auto-1"}
+
+	reasons := func(planned []gcCandidate) map[string]string {
+		got := make(map[string]string)
+		for _, candidate := range planned {
+			got[candidate.Version] = candidate.Reason
+		}
+		return got
+	}
+
+	t.Run("keep-auto skips pinned snapshots", func(t *testing.T) {
+		provider.Retention = &RetentionPolicy{KeepAuto: 1}
+		planned, err := planRetention(provider, config.settings(), now)
+		if err != nil {
+			t.Fatalf("planRetention failed: %v", err)
+		}
+		if got := reasons(planned); len(got) != 1 || got["auto-2"] != reasonKeepAuto {
+			t.Errorf("Planned deletions = %v, want auto-2 for keep-auto", got)
+		}
+	})
+
+	t.Run("max-age ignores versions without metadata", func(t *testing.T) {
+		provider.Retention = &RetentionPolicy{MaxAge: "36h"}
+		planned, err := planRetention(provider, config.settings(), now)
+		if err != nil {
+			t.Fatalf("planRetention failed: %v", err)
+		}
+		if got := reasons(planned); len(got) != 1 || got["auto-2"] != reasonMaxAge {
+			t.Errorf("Planned deletions = %v, want auto-2 for max-age", got)
+		}
+	})
+
+	t.Run("max-size only deletes auto-snapshots by default", func(t *testing.T) {
+		provider.Retention = &RetentionPolicy{MaxSize: "1"}
+		planned, err := planRetention(provider, config.settings(), now)
+		if err != nil {
+			t.Fatalf("planRetention failed: %v", err)
+		}
+		got := reasons(planned)
+		if len(got) != 2 || got["auto-2"] != reasonMaxSize || got["auto-3"] != reasonMaxSize {
+			t.Errorf("Planned deletions = %v, want auto-2 and auto-3 for max-size", got)
+		}
+	})
+
+	t.Run("max-size never deletes the active version", func(t *testing.T) {
+		provider.Retention = &RetentionPolicy{MaxSize: "1", PruneNamed: true}
+		planned, err := planRetention(provider, config.settings(), now)
+		if err != nil {
+			t.Fatalf("planRetention failed: %v", err)
+		}
+		got := reasons(planned)
+		if len(got) != 3 || got["auto-2"] != reasonMaxSize || got["auto-3"] != reasonMaxSize || got["personal"] != reasonMaxSize {
+			t.Errorf("Planned deletions = %v, want auto-2, auto-3 and personal for max-size", got)
+		}
+	})
+
+	t.Run("gc deletes planned versions", func(t *testing.T) {
+		provider.Retention = &RetentionPolicy{KeepAuto: 1}
+		config.Providers[provider.Name] = provider
+		if err := config.saveProviders(); err != nil {
+			t.Fatalf("saveProviders failed: %v", err)
+		}
+
+		if err := runGC(gcCmd, nil); err != nil {
+			t.Fatalf("runGC failed: %v", err)
+		}
+		versionPath, _ := getVersionPath(provider.Name, "auto-2")
+		if _, err := os.Lstat(versionPath); !os.IsNotExist(err) {
+			t.Error("Expected auto-2 to be deleted")
+		}
+
+		config, err := loadProviders()
+		if err != nil {
+			t.Fatalf("loadProviders failed: %v", err)
+		}
+		if _, ok := config.Providers[provider.Name].Versions["auto-2"]; ok {
+			t.Error("Expected metadata of auto-2 to be removed")
+		}
+	})
+}
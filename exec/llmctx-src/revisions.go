@@ -0,0 +1,230 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// getRevisionDir returns the directory holding the earlier contents of a
+// version, one manifest per revision named by its number
+func getRevisionDir(providerName, versionName string) (string, error) {
+	if err := checkPathComponent("version", versionName); err != nil {
+		return "", err
+	}
+	providerDir, err := getProviderDir(providerName)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(providerDir, "revisions", versionName), nil
+}
+
+// versionRevisions returns the numbers of the stored revisions of a version,
+// oldest first
+func versionRevisions(providerName, versionName string) ([]int, error) {
+	revisionDir, err := getRevisionDir(providerName, versionName)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(revisionDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read revisions of '%s': %w", versionName, err)
+	}
+
+	var revisions []int
+	for _, entry := range entries {
+		revision, err := strconv.Atoi(entry.Name())
+		if err == nil && revision > 0 && entry.Type().IsRegular() {
+			revisions = append(revisions, revision)
+		}
+	}
+	sort.Ints(revisions)
+	return revisions, nil
+}
+
+// versionRevision is an earlier content of a version, kept when the version
+// was overwritten
+type versionRevision struct {
+	storedVersion
+	SavedAt    time.Time `json:"saved_at,omitzero"` // when the content was saved into the version
+	ReplacedAt time.Time `json:"replaced_at"`
+	Note       string    `json:"note,omitempty"`
+}
+
+// loadRevision reads a stored revision of a version
+func loadRevision(providerName, versionName string, revision int) (*versionRevision, error) {
+	revisionDir, err := getRevisionDir(providerName, versionName)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(revisionDir, strconv.Itoa(revision)))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("revision %d of version '%s' not found for provider '%s'", revision, versionName, providerName)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var stored versionRevision
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("failed to parse revision %d of version '%s': %w", revision, versionName, err)
+	}
+	return &stored, nil
+}
+
+// writeRevision stores a revision of a version, replacing any previous one of
+// the same number
+func writeRevision(providerName, versionName string, revision int, stored *versionRevision) error {
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal revision: %w", err)
+	}
+
+	revisionDir, err := getRevisionDir(providerName, versionName)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(revisionDir, privateDirMode); err != nil {
+		return fmt.Errorf("failed to create revision directory: %w", err)
+	}
+	if err := writeFileAtomic(filepath.Join(revisionDir, strconv.Itoa(revision)), data, privateFileMode); err != nil {
+		return fmt.Errorf("failed to keep revision %d of '%s': %w", revision, versionName, err)
+	}
+	return nil
+}
+
+// currentRevision returns the number of the content a version holds now: one
+// more than its newest kept revision
+func currentRevision(providerName, versionName string) (int, error) {
+	revisions, err := versionRevisions(providerName, versionName)
+	if err != nil {
+		return 0, err
+	}
+	if len(revisions) == 0 {
+		return 1, nil
+	}
+	return revisions[len(revisions)-1] + 1, nil
+}
+
+// keepRevision stores the content a version holds before it is overwritten as
+// its next revision, and returns the revision's number. Only the manifest is
+// copied; the contents stay shared in the object store. Revisions beyond the
+// configured limit are deleted, oldest first.
+func keepRevision(provider Provider, versionName string, limit int) (int, error) {
+	version, err := loadVersion(provider.Name, versionName)
+	if err != nil {
+		return 0, err
+	}
+	revision, err := currentRevision(provider.Name, versionName)
+	if err != nil {
+		return 0, err
+	}
+
+	stored := versionRevision{storedVersion: *version, ReplacedAt: time.Now().UTC()}
+	if meta, ok := provider.Versions[versionName]; ok {
+		stored.SavedAt = meta.UpdatedAt
+		stored.Note = meta.Note
+	}
+	if err := writeRevision(provider.Name, versionName, revision, &stored); err != nil {
+		return 0, err
+	}
+
+	// Their objects are freed by the next gc
+	revisionDir, err := getRevisionDir(provider.Name, versionName)
+	if err != nil {
+		return revision, err
+	}
+	revisions, err := versionRevisions(provider.Name, versionName)
+	if err != nil {
+		return revision, err
+	}
+	for len(revisions) > limit {
+		if err := os.Remove(filepath.Join(revisionDir, strconv.Itoa(revisions[0]))); err != nil {
+			return revision, fmt.Errorf("failed to delete revision %d of '%s': %w", revisions[0], versionName, err)
+		}
+		revisions = revisions[1:]
+	}
+	return revision, nil
+}
+
+// removeRevisions deletes the revisions of a deleted version
+func removeRevisions(providerName, versionName string) error {
+	revisionDir, err := getRevisionDir(providerName, versionName)
+	if err != nil {
+		return err
+	}
+	return removeAll(revisionDir)
+}
+
+// renameRevisions moves the revisions of a renamed version along with it
+func renameRevisions(providerName, oldName, newName string) error {
+	oldDir, err := getRevisionDir(providerName, oldName)
+	if err != nil {
+		return err
+	}
+	newDir, err := getRevisionDir(providerName, newName)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(oldDir, newDir); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// captureOnLeave saves the live content of a provider with capture-on-leave
+// into its active version before the provider is switched away, keeping the
+// content it replaces as a revision. Only live content that matches no stored
+// version and holds the same files as the active version, with only their
+// contents changed, counts as changes the tool made to it. Anything else may
+// not belong to the version at all and is left to the usual checks, which
+// refuse to switch away from unbacked content. It returns the revision kept,
+// or 0 if nothing was saved. The caller holds the lock.
+func captureOnLeave(config *ProvidersConfig, provider *Provider) (int, error) {
+	if !provider.CaptureOnLeave || provider.CurrentVersion == "" || provider.isLinked() {
+		return 0, nil
+	}
+	current, err := versionTree(provider.Name, provider.CurrentVersion)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	matches, err := findMatchingVersions(*provider)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check current state: %w", err)
+	}
+	if len(matches) > 0 {
+		return 0, nil
+	}
+
+	live, err := hashTree(provider.OriginalPath, provider.Type, provider.symlinkPolicy())
+	if err != nil {
+		return 0, fmt.Errorf("failed to check current state: %w", err)
+	}
+	for _, diff := range compareManifests(current, live).Differences {
+		if diff.Kind != DiffModified || diff.Detail != "content" || current[diff.Path].Mode != live[diff.Path].Mode {
+			return 0, nil
+		}
+	}
+
+	revision, err := captureVersion(config, provider, provider.CurrentVersion, "")
+	if err != nil {
+		return 0, fmt.Errorf("failed to save changes to version '%s' of '%s': %w", provider.CurrentVersion, provider.Name, err)
+	}
+	config.Providers[provider.Name] = *provider
+	if err := config.saveProviders(); err != nil {
+		return 0, fmt.Errorf("failed to save providers config: %w", err)
+	}
+	recordHistory(historyEvent{Action: historyAddVersion, Provider: provider.Name, Version: provider.CurrentVersion})
+	return revision, nil
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// enableCaptureOnLeave turns on capture-on-leave for the switch test provider
+func enableCaptureOnLeave(t *testing.T, config *ProvidersConfig, provider Provider) Provider {
+	t.Helper()
+	provider.CaptureOnLeave = true
+	config.Providers[provider.Name] = provider
+	if err := config.saveProviders(); err != nil {
+		t.Fatalf("Failed to save providers: %v", err)
+	}
+	return provider
+}
+
+// assertRevisionContent checks the content kept in a revision of a file provider
+func assertRevisionContent(t *testing.T, providerName, versionName string, revision int, want string) {
+	t.Helper()
+	stored, err := loadRevision(providerName, versionName, revision)
+	if err != nil {
+		t.Fatalf("Failed to load revision %d of %s: %v", revision, versionName, err)
+	}
+	got, err := readObject(stored.tree()[""].Digest)
+	if err != nil {
+		t.Fatalf("Failed to read revision %d of %s: %v", revision, versionName, err)
+	}
+	if string(got) != want {
+		t.Errorf("Revision %d of %s = %q, want %q", revision, versionName, got, want)
+	}
+}
+
+func TestCaptureOnLeave(t *testing.T) {
+	_, config, provider := setupSwitchTest(t)
+	provider = enableCaptureOnLeave(t, config, provider)
+
+	// A tool refreshed its token while work was active
+	if err := os.WriteFile(provider.OriginalPath, []byte("work-refreshed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := runSetVersion(setVersionCmd, []string{provider.Name, "personal"}); err != nil {
+		t.Fatalf("set-version failed: %v", err)
+	}
+
+	assertLive(t, map[string]string{provider.Name: "personal"})
+	assertVersionContent(t, provider.Name, "work", "work-refreshed")
+	assertRevisionContent(t, provider.Name, "work", 1, "work")
+
+	// Revisions are not versions, and their contents survive gc
+	versions, _ := getAvailableVersions(provider.Name)
+	if len(versions) != 2 {
+		t.Errorf("Expected the revision to be hidden, got versions %v", versions)
+	}
+	if _, _, err := sweepObjects(nil, false); err != nil {
+		t.Fatalf("sweepObjects failed: %v", err)
+	}
+	assertRevisionContent(t, provider.Name, "work", 1, "work")
+
+	// The revisions follow their version
+	config, _ = loadProviders()
+	if _, err := removeVersions(config, provider.Name, []string{"work"}); err != nil {
+		t.Fatalf("removeVersions failed: %v", err)
+	}
+	if revisions, _ := versionRevisions(provider.Name, "work"); len(revisions) != 0 {
+		t.Errorf("Expected the revisions to be removed, got %v", revisions)
+	}
+}
+
+func TestCaptureOnLeaveSkipsOtherVersions(t *testing.T) {
+	_, config, provider := setupSwitchTest(t)
+	provider = enableCaptureOnLeave(t, config, provider)
+
+	// Content of another version was put in place, not changed by the tool
+	if err := os.WriteFile(provider.OriginalPath, []byte("personal"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	revision, err := captureOnLeave(config, &provider)
+	if err != nil {
+		t.Fatalf("captureOnLeave failed: %v", err)
+	}
+	if revision != 0 {
+		t.Errorf("Expected nothing to be captured, got revision %d", revision)
+	}
+	assertVersionContent(t, provider.Name, "work", "work")
+}
+
+// overwriteVersion saves new live content under an existing version name
+func overwriteVersion(t *testing.T, provider Provider, versionName, content string) {
+	t.Helper()
+	if err := os.WriteFile(provider.OriginalPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := runAddVersion(addVersionCmd, []string{provider.Name, versionName}); err != nil {
+		t.Fatalf("add-version failed: %v", err)
+	}
+}
+
+func TestAddVersionKeepsRevisions(t *testing.T) {
+	_, config, provider := setupSwitchTest(t)
+	config.Settings = &Settings{RevisionLimit: 2}
+	if err := config.saveProviders(); err != nil {
+		t.Fatalf("Failed to save providers: %v", err)
+	}
+
+	overwriteVersion(t, provider, "work", "work-2")
+	assertRevisionContent(t, provider.Name, "work", 1, "work")
+
+	// Saving the same content again is not a new revision
+	overwriteVersion(t, provider, "work", "work-2")
+	if current, _ := currentRevision(provider.Name, "work"); current != 2 {
+		t.Errorf("Expected work to be at revision 2, got %d", current)
+	}
+
+	// Only the newest revisions up to the limit are kept
+	overwriteVersion(t, provider, "work", "work-3")
+	overwriteVersion(t, provider, "work", "work-4")
+	revisions, _ := versionRevisions(provider.Name, "work")
+	if len(revisions) != 2 || revisions[0] != 2 || revisions[1] != 3 {
+		t.Errorf("Expected revisions [2 3], got %v", revisions)
+	}
+	assertRevisionContent(t, provider.Name, "work", 3, "work-3")
+}
+
+func TestRestoreRevision(t *testing.T) {
+	_, _, provider := setupSwitchTest(t)
+	overwriteVersion(t, provider, "work", "work-2")
+
+	// The active version is clean, so the live content follows it
+	if err := runRestore(restoreCmd, []string{provider.Name, "work@1"}); err != nil {
+		t.Fatalf("restore failed: %v", err)
+	}
+	assertVersionContent(t, provider.Name, "work", "work")
+	assertRevisionContent(t, provider.Name, "work", 2, "work-2")
+	assertLive(t, map[string]string{provider.Name: "work"})
+
+	// Unsaved live content is left alone
+	if err := os.WriteFile(provider.OriginalPath, []byte("unsaved"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := runRestore(restoreCmd, []string{provider.Name, "work@2"}); err != nil {
+		t.Fatalf("restore failed: %v", err)
+	}
+	assertVersionContent(t, provider.Name, "work", "work-2")
+	assertFileContent(t, provider.OriginalPath, "unsaved")
+
+	if err := runRestore(restoreCmd, []string{provider.Name, "work@9"}); err == nil {
+		t.Error("Expected an unknown revision to be refused")
+	}
+}
+
+func TestParseRevisionSpec(t *testing.T) {
+	if version, revision, err := parseRevisionSpec("work@3"); err != nil || version != "work" || revision != 3 {
+		t.Errorf("parseRevisionSpec(work@3) = %q, %d, %v", version, revision, err)
+	}
+	for _, spec := range []string{"work", "work@", "work@0", "work@x"} {
+		if _, _, err := parseRevisionSpec(spec); err == nil {
+			t.Errorf("Expected parseRevisionSpec(%q) to fail", spec)
+		}
+	}
+}
+
+func TestCaptureOnLeaveRequiresSameFiles(t *testing.T) {
+	_, provider := setupStoreTest(t)
+	provider.CurrentVersion = "v1"
+	provider.CaptureOnLeave = true
+	if err := saveVersion(provider, "v1"); err != nil {
+		t.Fatalf("saveVersion failed: %v", err)
+	}
+	config := &ProvidersConfig{Providers: map[string]Provider{provider.Name: provider}}
+	if err := config.saveProviders(); err != nil {
+		t.Fatalf("Failed to save providers: %v", err)
+	}
+
+	// A file the active version never had is not a change to it
+	extra := filepath.Join(provider.OriginalPath, "other-account.json")
+	if err := os.WriteFile(extra, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	revision, err := captureOnLeave(config, &provider)
+	if err != nil {
+		t.Fatalf("captureOnLeave failed: %v", err)
+	}
+	if revision != 0 {
+		t.Errorf("Expected nothing to be captured, got revision %d", revision)
+	}
+	if tree, _ := versionTree(provider.Name, "v1"); tree["other-account.json"].Path != "" {
+		t.Error("Expected the added file to stay out of the version")
+	}
+	if revisions, _ := versionRevisions(provider.Name, "v1"); len(revisions) != 0 {
+		t.Errorf("Expected no revisions, got %v", revisions)
+	}
+
+	// Changed contents of the same files are captured
+	if err := os.Remove(extra); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(provider.OriginalPath, "token"), []byte("refreshed"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if revision, err := captureOnLeave(config, &provider); err != nil || revision != 1 {
+		t.Errorf("captureOnLeave = %d (%v), want revision 1", revision, err)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// secretMask replaces values that look like credentials
+const secretMask = "********"
+
+// secretAssignment matches values assigned to keys whose names suggest a credential,
+// in YAML, JSON, INI and shell styles (e.g. `api_key: x`, `"token": "x"`, `PASSWORD=x`).
+// A quoted value runs through its closing quote, spaces and escapes included.
+var secretAssignment = regexp.MustCompile(`(?i)((?:api[_-]?key|apikey|token|secret|password|passwd|pwd|credential|private[_-]?key|access[_-]?key|session[_-]?key|auth|bearer)[\w.-]*["']?\s*[:=]\s*)("(?:[^"\\]|\\.)*"?|'[^']*'?|[^\s"',}]+)`)
+
+// privateKeyBlock matches a PEM or OpenSSH private key from its BEGIN line
+// through its END line, or through the end of the text if it has none
+var privateKeyBlock = regexp.MustCompile(`(?s)(-----BEGIN [A-Z0-9 ]*PRIVATE KEY(?: BLOCK)?-----)(.*?)(-----END [A-Z0-9 ]*PRIVATE KEY(?: BLOCK)?-----|\z)`)
+
+// secretTokens matches well-known credential formats wherever they appear
+var secretTokens = regexp.MustCompile(strings.Join([]string{
+	`sk-[A-Za-z0-9_-]{16,}`,                                         // OpenAI / Anthropic style API keys
+	`gh[pousr]_[A-Za-z0-9]{20,}`,                                    // GitHub tokens
+	`github_pat_[A-Za-z0-9_]{20,}`,                                  // GitHub fine-grained tokens
+	`(?:AKIA|ASIA)[0-9A-Z]{16}`,                                     // AWS access key IDs
+	`xox[abprs]-[A-Za-z0-9-]{10,}`,                                  // Slack tokens
+	`AIza[0-9A-Za-z_-]{35}`,                                         // Google API keys
+	`ya29\.[0-9A-Za-z_-]{20,}`,                                      // Google OAuth access tokens
+	`eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`, // JWTs
+}, "|"))
+
+// maskSecrets replaces anything in a line of text that looks like a credential
+func maskSecrets(line string) string {
+	line = secretAssignment.ReplaceAllStringFunc(line, func(match string) string {
+		parts := secretAssignment.FindStringSubmatch(match)
+		key, value := parts[1], parts[2]
+		// Quotes stay so the masked line keeps its shape
+		if quote := value[:1]; quote == `"` || quote == "'" {
+			closing := ""
+			if len(value) > 1 && strings.HasSuffix(value, quote) {
+				closing = quote
+			}
+			return key + quote + secretMask + closing
+		}
+		return key + secretMask
+	})
+	return secretTokens.ReplaceAllString(line, secretMask)
+}
+
+// maskPrivateKeys replaces the body of every private key block in text,
+// keeping its BEGIN and END lines
+func maskPrivateKeys(text string) string {
+	return privateKeyBlock.ReplaceAllStringFunc(text, func(match string) string {
+		parts := privateKeyBlock.FindStringSubmatch(match)
+		body := secretMask
+		if strings.Contains(parts[2], "\n") {
+			body = "\n" + secretMask + "\n"
+		}
+		return parts[1] + body + parts[3]
+	})
+}
+
+// maskSecretsInText masks private keys in text and credentials on every line
+func maskSecretsInText(text string) string {
+	lines := strings.SplitAfter(maskPrivateKeys(text), "\n")
+	for i, line := range lines {
+		lines[i] = maskSecrets(line)
+	}
+	return strings.Join(lines, "")
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// defaultAutoSnapshotLimit is how many auto-snapshots of a provider are kept
+// when no limit is configured
+const defaultAutoSnapshotLimit = 10
+
+// defaultRevisionLimit is how many earlier revisions of a version are kept
+// when no limit is configured
+const defaultRevisionLimit = 10
+
+// Settings holds global defaults stored in providers.json
+type Settings struct {
+	Autosave          bool `json:"autosave,omitempty"`            // snapshot unbacked state before switching
+	AutoSnapshotLimit int  `json:"auto_snapshot_limit,omitempty"` // auto-snapshots kept per provider; 0 uses the default
+	RevisionLimit     int  `json:"revision_limit,omitempty"`      // earlier revisions kept per version; 0 uses the default
+
+	// Extra holds fields written by newer versions of llmctx so they survive a save
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// settingsFields has the fields of Settings without its JSON methods
+type settingsFields Settings
+
+// MarshalJSON encodes the settings, including any preserved unknown fields
+func (s Settings) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(settingsFields(s))
+	if err != nil {
+		return nil, err
+	}
+	return appendUnknownFields(data, s.Extra)
+}
+
+// UnmarshalJSON decodes the settings, keeping fields this build does not know
+func (s *Settings) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, (*settingsFields)(s)); err != nil {
+		return err
+	}
+	extra, err := unknownFields(data, settingsFields{})
+	s.Extra = extra
+	return err
+}
+
+// settings returns the global settings, which may not be stored yet
+func (pc *ProvidersConfig) settings() Settings {
+	if pc.Settings == nil {
+		return Settings{}
+	}
+	return *pc.Settings
+}
+
+// autoSnapshotLimit returns how many auto-snapshots of a provider are kept
+func (s Settings) autoSnapshotLimit() int {
+	if s.AutoSnapshotLimit <= 0 {
+		return defaultAutoSnapshotLimit
+	}
+	return s.AutoSnapshotLimit
+}
+
+// revisionLimit returns how many earlier revisions of a version are kept
+func (s Settings) revisionLimit() int {
+	if s.RevisionLimit <= 0 {
+		return defaultRevisionLimit
+	}
+	return s.RevisionLimit
+}
+
+// settingKey describes a setting that can be changed with the config command.
+// provider is nil for global settings.
+type settingKey struct {
+	name        string
+	description string
+	get         func(config *ProvidersConfig, provider *Provider) string
+	set         func(config *ProvidersConfig, provider *Provider, value string) error
+}
+
+// settingKeys lists the global settings in the order config prints them
+var settingKeys = []settingKey{
+	{
+		name:        "autosave",
+		description: "Save unbacked live state as an auto-snapshot before set-version switches away from it",
+		get: func(config *ProvidersConfig, _ *Provider) string {
+			return strconv.FormatBool(config.settings().Autosave)
+		},
+		set: func(config *ProvidersConfig, _ *Provider, value string) error {
+			enabled, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("invalid value '%s' for autosave: must be true or false", value)
+			}
+			settings := config.settings()
+			settings.Autosave = enabled
+			config.Settings = &settings
+			return nil
+		},
+	},
+	{
+		name:        "auto-snapshot-limit",
+		description: "Number of auto-snapshots kept per provider; older ones are deleted",
+		get: func(config *ProvidersConfig, _ *Provider) string {
+			return strconv.Itoa(config.settings().autoSnapshotLimit())
+		},
+		set: func(config *ProvidersConfig, _ *Provider, value string) error {
+			limit, err := strconv.Atoi(value)
+			if err != nil || limit < 1 {
+				return fmt.Errorf("invalid value '%s' for auto-snapshot-limit: must be a positive number", value)
+			}
+			settings := config.settings()
+			settings.AutoSnapshotLimit = limit
+			config.Settings = &settings
+			return nil
+		},
+	},
+	{
+		name:        "revision-limit",
+		description: "Number of earlier revisions kept per version when it is overwritten; older ones are deleted",
+		get: func(config *ProvidersConfig, _ *Provider) string {
+			return strconv.Itoa(config.settings().revisionLimit())
+		},
+		set: func(config *ProvidersConfig, _ *Provider, value string) error {
+			limit, err := strconv.Atoi(value)
+			if err != nil || limit < 1 {
+				return fmt.Errorf("invalid value '%s' for revision-limit: must be a positive number", value)
+			}
+			settings := config.settings()
+			settings.RevisionLimit = limit
+			config.Settings = &settings
+			return nil
+		},
+	},
+}
+
+// providerSettingKeys lists the per-provider settings. Setting a retention
+// limit to 0 removes it, or for keep-auto falls back to auto-snapshot-limit.
+var providerSettingKeys = []settingKey{
+	{
+		name:        "env-var",
+		description: "Environment variable the tool reads its configuration path from, used by env and shell; none to unset",
+		get: func(_ *ProvidersConfig, provider *Provider) string {
+			return limitValue(provider.EnvVar)
+		},
+		set: func(_ *ProvidersConfig, provider *Provider, value string) error {
+			if value == "none" || value == "" {
+				provider.EnvVar = ""
+				return nil
+			}
+			if err := validateEnvVarName(value); err != nil {
+				return err
+			}
+			provider.EnvVar = value
+			return nil
+		},
+	},
+	{
+		name:        "capture-on-leave",
+		description: "Save live changes into the active version when switching away, keeping its previous content as a revision",
+		get: func(_ *ProvidersConfig, provider *Provider) string {
+			return strconv.FormatBool(provider.CaptureOnLeave)
+		},
+		set: func(_ *ProvidersConfig, provider *Provider, value string) error {
+			enabled, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("invalid value '%s' for capture-on-leave: must be true or false", value)
+			}
+			provider.CaptureOnLeave = enabled
+			return nil
+		},
+	},
+	{
+		name:        "keep-auto",
+		description: "Number of auto-snapshots kept; 0 uses auto-snapshot-limit",
+		get: func(config *ProvidersConfig, provider *Provider) string {
+			return strconv.Itoa(provider.keepAuto(config.settings()))
+		},
+		set: func(_ *ProvidersConfig, provider *Provider, value string) error {
+			limit, err := strconv.Atoi(value)
+			if err != nil || limit < 0 {
+				return fmt.Errorf("invalid value '%s' for keep-auto: must be a number", value)
+			}
+			policy := provider.retention()
+			policy.KeepAuto = limit
+			provider.setRetention(policy)
+			return nil
+		},
+	},
+	{
+		name:        "max-age",
+		description: "Versions last saved longer ago are deleted by gc, e.g. 30d; 0 for no limit",
+		get: func(_ *ProvidersConfig, provider *Provider) string {
+			return limitValue(provider.retention().MaxAge)
+		},
+		set: func(_ *ProvidersConfig, provider *Provider, value string) error {
+			age, err := parseAge(value)
+			if err != nil {
+				return err
+			}
+			policy := provider.retention()
+			policy.MaxAge = ""
+			if age > 0 {
+				policy.MaxAge = value
+			}
+			provider.setRetention(policy)
+			return nil
+		},
+	},
+	{
+		name:        "max-size",
+		description: "gc deletes the oldest versions while stored versions exceed this size, e.g. 500MB; 0 for no limit",
+		get: func(_ *ProvidersConfig, provider *Provider) string {
+			return limitValue(provider.retention().MaxSize)
+		},
+		set: func(_ *ProvidersConfig, provider *Provider, value string) error {
+			size, err := parseSize(value)
+			if err != nil {
+				return err
+			}
+			policy := provider.retention()
+			policy.MaxSize = ""
+			if size > 0 {
+				policy.MaxSize = value
+			}
+			provider.setRetention(policy)
+			return nil
+		},
+	},
+	{
+		name:        "prune-named",
+		description: "Let max-age and max-size delete versions saved by name, not only auto-snapshots",
+		get: func(_ *ProvidersConfig, provider *Provider) string {
+			return strconv.FormatBool(provider.retention().PruneNamed)
+		},
+		set: func(_ *ProvidersConfig, provider *Provider, value string) error {
+			enabled, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("invalid value '%s' for prune-named: must be true or false", value)
+			}
+			policy := provider.retention()
+			policy.PruneNamed = enabled
+			provider.setRetention(policy)
+			return nil
+		},
+	},
+}
+
+// limitValue shows an unset limit as "none"
+func limitValue(value string) string {
+	if value == "" {
+		return "none"
+	}
+	return value
+}
+
+// findSettingKey looks up a setting by name
+func findSettingKey(keys []settingKey, name string) (settingKey, error) {
+	for _, key := range keys {
+		if key.name == name {
+			return key, nil
+		}
+	}
+	return settingKey{}, fmt.Errorf("unknown setting '%s'", name)
+}
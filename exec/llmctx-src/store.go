@@ -0,0 +1,812 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Stored versions are manifests kept at getVersionPath. The contents of their
+// files live once in a content-addressed object store, named by the sha256 of
+// the contents, or by a keyed HMAC when the store is encrypted, so versions
+// that share files share storage.
+
+// storedVersion is the manifest of a stored version
+type storedVersion struct {
+	Type    string      `json:"type"`    // "file" or "directory"
+	Entries []treeEntry `json:"entries"` // sorted by path; a file provider has a single entry ""
+}
+
+// tree returns the manifest entries keyed by path, as hashTree does for live content
+func (v *storedVersion) tree() treeManifest {
+	tree := make(treeManifest, len(v.Entries))
+	for _, entry := range v.Entries {
+		tree[entry.Path] = entry
+	}
+	return tree
+}
+
+// getObjectsDir returns the directory of the content-addressed object store
+func getObjectsDir() (string, error) {
+	configDir, err := getConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "store", "objects"), nil
+}
+
+// getObjectPath returns where the object with the given digest is stored
+func getObjectPath(digest string) (string, error) {
+	if len(digest) != sha256.Size*2 || strings.Trim(digest, "0123456789abcdef") != "" {
+		return "", fmt.Errorf("invalid object digest '%s'", digest)
+	}
+	objectsDir, err := getObjectsDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(objectsDir, digest[:2], digest[2:]), nil
+}
+
+// storeObject copies a file into the object store unless identical content is
+// already stored, and returns the digest of what was stored, taken with
+// newHash. Content is encrypted when a recipient is given.
+func storeObject(src string, newHash func() hash.Hash, recipient *ecdh.PublicKey) (string, error) {
+	// Hash what was read so the digest always matches what is written
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return "", pathError("read", src, err)
+	}
+	digest := contentDigest(newHash, data)
+
+	objectPath, err := getObjectPath(digest)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(objectPath); err == nil {
+		return digest, nil
+	}
+
+	if recipient != nil {
+		if data, err = encryptObject(data, recipient); err != nil {
+			return "", pathError("encrypt", src, err)
+		}
+	}
+	if err := writeObject(objectPath, data); err != nil {
+		return "", err
+	}
+	return digest, nil
+}
+
+// writeObject writes object data to objectPath through a temporary file in
+// the object store, which sweepObjects removes if the write is interrupted
+func writeObject(objectPath string, data []byte) error {
+	objectsDir, err := getObjectsDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(objectPath), privateDirMode); err != nil {
+		return fmt.Errorf("failed to create object store: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(objectsDir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create object: %w", err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write object: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write object: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write object: %w", err)
+	}
+	if err := os.Rename(tmpPath, objectPath); err != nil {
+		return fmt.Errorf("failed to store object: %w", err)
+	}
+	return nil
+}
+
+// objectDigest returns the digest an object is stored under from its path
+func objectDigest(objectsDir, path string) (string, error) {
+	rel, err := filepath.Rel(objectsDir, path)
+	if err != nil {
+		return "", err
+	}
+	return strings.Replace(filepath.ToSlash(rel), "/", "", 1), nil
+}
+
+// copyObject writes the object with the given digest to dst, which must not
+// exist, and fails if the stored content no longer matches its digest
+func copyObject(digest, dst string) error {
+	data, err := readObject(digest)
+	if err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return pathError("create", dst, err)
+	}
+	if _, err := out.Write(data); err != nil {
+		out.Close()
+		return pathError("write", dst, err)
+	}
+	if err := out.Close(); err != nil {
+		return pathError("write", dst, err)
+	}
+	return nil
+}
+
+// readObject returns the contents of the object with the given digest,
+// decrypting it if needed
+func readObject(digest string) ([]byte, error) {
+	objectPath, err := getObjectPath(digest)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(objectPath)
+	if err != nil {
+		return nil, pathError("read", objectPath, err)
+	}
+	// Plain objects are named by their sha256 even in an encrypted store,
+	// until encrypt has renamed them
+	newHash := sha256.New
+	if isEncryptedObject(data) {
+		key, err := unlockStoreKey()
+		if err != nil {
+			return nil, err
+		}
+		if data, err = decryptObject(data, key); err != nil {
+			return nil, pathError("decrypt", objectPath, err)
+		}
+		if newHash, err = newContentHash(); err != nil {
+			return nil, err
+		}
+	}
+	if contentDigest(newHash, data) != digest {
+		return nil, pathError("verify", objectPath, errors.New("stored object is corrupt"))
+	}
+	return data, nil
+}
+
+// encryptObjects encrypts every unencrypted object in the store and returns
+// how many were encrypted. Encrypted objects are named by the keyed digest of
+// newContentHash, so each one is written under its new name, the manifests of
+// all versions and revisions are renamed to it, and only then is the plain
+// object deleted; an interrupted run can simply be repeated. The caller holds
+// the lock and refreshes the version metadata afterwards.
+func encryptObjects(recipient *ecdh.PublicKey) (int, error) {
+	objectsDir, err := getObjectsDir()
+	if err != nil {
+		return 0, err
+	}
+	newHash, err := newContentHash()
+	if err != nil {
+		return 0, err
+	}
+
+	renamed := make(map[string]string)
+	err = filepath.WalkDir(objectsDir, func(path string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && path == objectsDir {
+			return filepath.SkipDir
+		}
+		if err != nil || d.IsDir() || strings.HasPrefix(d.Name(), ".tmp-") {
+			return err
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return pathError("read", path, err)
+		}
+		if isEncryptedObject(data) {
+			return nil
+		}
+		digest, err := objectDigest(objectsDir, path)
+		if err != nil {
+			return err
+		}
+		if contentDigest(sha256.New, data) != digest {
+			return pathError("verify", path, errors.New("stored object is corrupt"))
+		}
+
+		keyed := contentDigest(newHash, data)
+		keyedPath, err := getObjectPath(keyed)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(keyedPath); os.IsNotExist(err) {
+			encrypted, err := encryptObject(data, recipient)
+			if err != nil {
+				return pathError("encrypt", path, err)
+			}
+			if err := writeObject(keyedPath, encrypted); err != nil {
+				return err
+			}
+		}
+		renamed[digest] = keyed
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	err = eachStoredVersion(func(providerName, versionName string) error {
+		version, err := loadVersion(providerName, versionName)
+		if err != nil {
+			return err
+		}
+		if rekeyEntries(version.Entries, renamed, newHash) {
+			versionPath, err := getVersionPath(providerName, versionName)
+			if err != nil {
+				return err
+			}
+			if err := writeVersionManifest(versionPath, version); err != nil {
+				return err
+			}
+		}
+
+		revisions, err := versionRevisions(providerName, versionName)
+		if err != nil {
+			return err
+		}
+		for _, revision := range revisions {
+			stored, err := loadRevision(providerName, versionName, revision)
+			if err != nil {
+				return err
+			}
+			if rekeyEntries(stored.Entries, renamed, newHash) {
+				if err := writeRevision(providerName, versionName, revision, stored); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to rename contents in manifests: %w", err)
+	}
+
+	for digest := range renamed {
+		objectPath, err := getObjectPath(digest)
+		if err != nil {
+			return 0, err
+		}
+		if err := os.Remove(objectPath); err != nil {
+			return 0, fmt.Errorf("failed to remove plain object: %w", err)
+		}
+	}
+	return len(renamed), nil
+}
+
+// rekeyEntries points manifest entries at the renamed objects of their
+// contents and takes the digests of symlink targets with newHash. It reports
+// whether any entry changed.
+func rekeyEntries(entries []treeEntry, renamed map[string]string, newHash func() hash.Hash) bool {
+	changed := false
+	for i, entry := range entries {
+		digest := entry.Digest
+		switch entry.Kind {
+		case entryFile:
+			if keyed, ok := renamed[digest]; ok {
+				digest = keyed
+			}
+		case entrySymlink:
+			digest = contentDigest(newHash, []byte(entry.Target))
+		}
+		if digest != entry.Digest {
+			entries[i].Digest = digest
+			changed = true
+		}
+	}
+	return changed
+}
+
+// ingestTree stores the live content at root in the object store and returns
+// its manifest. Symlinks are handled by the provider's policy as when hashing.
+func ingestTree(root, pathType string, policy SymlinkPolicy) (*storedVersion, error) {
+	tree, err := hashTree(root, pathType, policy)
+	if err != nil {
+		return nil, err
+	}
+
+	recipient, err := storeRecipient()
+	if err != nil {
+		return nil, err
+	}
+	newHash, err := newContentHash()
+	if err != nil {
+		return nil, err
+	}
+
+	version := &storedVersion{Type: pathType, Entries: make([]treeEntry, 0, len(tree))}
+	for _, entry := range tree {
+		if entry.Kind == entryFile {
+			path := filepath.Join(root, filepath.FromSlash(entry.Path))
+			digest, err := storeObject(path, newHash, recipient)
+			if err != nil {
+				return nil, err
+			}
+			if digest != entry.Digest {
+				return nil, pathError("store", path, errors.New("file changed while it was being saved"))
+			}
+		}
+		version.Entries = append(version.Entries, entry)
+	}
+	sort.Slice(version.Entries, func(i, j int) bool { return version.Entries[i].Path < version.Entries[j].Path })
+	return version, nil
+}
+
+// writeVersionManifest stores a manifest at path, replacing any previous one
+func writeVersionManifest(path string, version *storedVersion) error {
+	if err := os.MkdirAll(filepath.Dir(path), privateDirMode); err != nil {
+		return fmt.Errorf("failed to create version directory: %w", err)
+	}
+	data, err := json.MarshalIndent(version, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal version manifest: %w", err)
+	}
+	if err := writeFileAtomic(path, data, privateFileMode); err != nil {
+		return fmt.Errorf("failed to write version manifest: %w", err)
+	}
+	return nil
+}
+
+// readVersionManifest reads the manifest stored at path
+func readVersionManifest(path string) (*storedVersion, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var version storedVersion
+	if err := json.Unmarshal(data, &version); err != nil {
+		return nil, fmt.Errorf("failed to parse version manifest '%s': %w", path, err)
+	}
+	return &version, nil
+}
+
+// saveVersion stores the live content of a provider as a version, replacing
+// any version of the same name
+func saveVersion(provider Provider, versionName string) error {
+	versionPath, err := getVersionPath(provider.Name, versionName)
+	if err != nil {
+		return fmt.Errorf("failed to get version path: %w", err)
+	}
+	version, err := ingestTree(provider.OriginalPath, provider.Type, provider.symlinkPolicy())
+	if err != nil {
+		return fmt.Errorf("failed to copy current state to version storage: %w", err)
+	}
+	return writeVersionManifest(versionPath, version)
+}
+
+// versionNotFoundError reports a version that is not stored. It matches
+// fs.ErrNotExist with errors.Is.
+type versionNotFoundError struct {
+	provider string
+	version  string
+}
+
+func (e *versionNotFoundError) Error() string {
+	return fmt.Sprintf("version '%s' not found for provider '%s'", e.version, e.provider)
+}
+
+func (e *versionNotFoundError) Is(target error) bool {
+	return target == fs.ErrNotExist
+}
+
+// loadVersion reads the manifest of a stored version
+func loadVersion(providerName, versionName string) (*storedVersion, error) {
+	versionPath, err := getVersionPath(providerName, versionName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get version path: %w", err)
+	}
+	version, err := readVersionManifest(versionPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, &versionNotFoundError{provider: providerName, version: versionName}
+	}
+	return version, err
+}
+
+// versionTree returns the hashed tree of a stored version for comparisons
+func versionTree(providerName, versionName string) (treeManifest, error) {
+	version, err := loadVersion(providerName, versionName)
+	if err != nil {
+		return nil, err
+	}
+	return version.tree(), nil
+}
+
+// materializeVersion recreates a stored version at dst with the contents,
+// modes, modification times and symlinks it was saved with. dst must not
+// exist yet; if restoring fails part way, whatever was written is removed.
+func materializeVersion(providerName, versionName, dst string) error {
+	version, err := loadVersion(providerName, versionName)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Lstat(dst); err == nil {
+		return pathError("create", dst, fs.ErrExist)
+	} else if !os.IsNotExist(err) {
+		return pathError("stat", dst, err)
+	}
+
+	if err := restoreEntries(version, dst); err != nil {
+		removeAll(dst)
+		return err
+	}
+	return nil
+}
+
+// restoreEntries writes the entries of a manifest below dst. Parents sort
+// before their children, so every directory exists before it is filled.
+func restoreEntries(version *storedVersion, dst string) error {
+	var dirs []treeEntry
+	for _, entry := range version.Entries {
+		path := filepath.Join(dst, filepath.FromSlash(entry.Path))
+
+		switch entry.Kind {
+		case entryDirectory:
+			if err := os.Mkdir(path, 0700); err != nil {
+				return pathError("create", path, err)
+			}
+			dirs = append(dirs, entry)
+		case entryFile:
+			if err := copyObject(entry.Digest, path); err != nil {
+				return err
+			}
+			if err := os.Chmod(path, entry.Mode); err != nil {
+				return pathError("chmod", path, err)
+			}
+			if err := os.Chtimes(path, entry.ModTime, entry.ModTime); err != nil {
+				return pathError("chtimes", path, err)
+			}
+		case entrySymlink:
+			if err := os.Symlink(entry.Target, path); err != nil {
+				return pathError("symlink", path, err)
+			}
+		default:
+			return pathError("restore", path, fmt.Errorf("unsupported entry kind '%s'", entry.Kind))
+		}
+	}
+
+	// Apply directory modes and times last, deepest first, as copyDir does
+	for i := len(dirs) - 1; i >= 0; i-- {
+		path := filepath.Join(dst, filepath.FromSlash(dirs[i].Path))
+		if err := os.Chmod(path, dirs[i].Mode); err != nil {
+			return pathError("chmod", path, err)
+		}
+		if err := os.Chtimes(path, dirs[i].ModTime, dirs[i].ModTime); err != nil {
+			return pathError("chtimes", path, err)
+		}
+	}
+	return nil
+}
+
+// objectSizes returns the size of every object referenced by the given trees
+func objectSizes(trees ...treeManifest) map[string]int64 {
+	sizes := make(map[string]int64)
+	for _, tree := range trees {
+		for _, entry := range tree {
+			if entry.Kind == entryFile {
+				sizes[entry.Digest] = entry.Size
+			}
+		}
+	}
+	return sizes
+}
+
+// storedSize returns the space taken by the objects of the given trees, counting
+// content shared between them once
+func storedSize(trees ...treeManifest) int64 {
+	var size int64
+	for _, objectSize := range objectSizes(trees...) {
+		size += objectSize
+	}
+	return size
+}
+
+// eachStoredVersion calls fn with every stored version of every provider
+// directory, including providers that were removed without purging their
+// versions, but passes over directories still holding plain copies
+func eachStoredVersion(fn func(providerName, versionName string) error) error {
+	configDir, err := getConfigDir()
+	if err != nil {
+		return err
+	}
+	providerDirs, err := os.ReadDir(filepath.Join(configDir, "providers"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read providers directory: %w", err)
+	}
+
+	for _, providerDir := range providerDirs {
+		if !providerDir.IsDir() {
+			continue
+		}
+		name := providerDir.Name()
+		// Plain copies left by a removed provider use no objects
+		legacy, err := isLegacyVersionDir(name)
+		if err != nil {
+			return err
+		}
+		if legacy {
+			continue
+		}
+		versions, err := getAvailableVersions(name)
+		if err != nil {
+			return err
+		}
+		for _, version := range versions {
+			if err := fn(name, version); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// referencedObjects returns the digests of all objects used by stored versions
+// and their revisions, except the versions listed in skip (provider name -> version names)
+func referencedObjects(skip map[string][]string) (map[string]bool, error) {
+	referenced := make(map[string]bool)
+	err := eachStoredVersion(func(name, version string) error {
+		if slices.Contains(skip[name], version) {
+			return nil
+		}
+		tree, err := versionTree(name, version)
+		if err != nil {
+			return fmt.Errorf("failed to read version '%s' of '%s': %w", version, name, err)
+		}
+		for digest := range objectSizes(tree) {
+			referenced[digest] = true
+		}
+
+		// Earlier revisions of the version share its objects
+		revisions, err := versionRevisions(name, version)
+		if err != nil {
+			return err
+		}
+		for _, revision := range revisions {
+			stored, err := loadRevision(name, version, revision)
+			if err != nil {
+				return fmt.Errorf("failed to read revision %d of version '%s' of '%s': %w", revision, version, name, err)
+			}
+			for digest := range objectSizes(stored.tree()) {
+				referenced[digest] = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return referenced, nil
+}
+
+// sweepObjects deletes objects no stored version references, along with
+// temporary files left by interrupted saves, and returns how many objects were
+// deleted and their total size. With dryRun nothing is deleted, and the
+// versions in skip are treated as already deleted. The caller holds the lock.
+func sweepObjects(skip map[string][]string, dryRun bool) (int, int64, error) {
+	referenced, err := referencedObjects(skip)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	objectsDir, err := getObjectsDir()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	count, freed := 0, int64(0)
+	err = filepath.WalkDir(objectsDir, func(path string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && path == objectsDir {
+			return filepath.SkipDir
+		}
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		digest, err := objectDigest(objectsDir, path)
+		if err != nil {
+			return err
+		}
+		isTemp := strings.HasPrefix(d.Name(), ".tmp-")
+		if !isTemp && referenced[digest] {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if !isTemp {
+			count++
+			freed += info.Size()
+		}
+		if dryRun {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("failed to remove object: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return count, freed, err
+	}
+	return count, freed, nil
+}
+
+// storeMarker is created in a provider's version directory once its versions
+// have been moved into the object store
+const storeMarker = ".llmctx-store"
+
+// isLegacyVersionDir reports whether a provider's version directory still
+// holds plain copies rather than manifests. A plain copy is a directory, or a
+// file that is not a manifest; a manifest that is merely damaged does not count.
+func isLegacyVersionDir(providerName string) (bool, error) {
+	versionDir, err := getVersionDir(providerName)
+	if err != nil {
+		return false, err
+	}
+	if _, err := os.Lstat(filepath.Join(versionDir, storeMarker)); err == nil {
+		return false, nil
+	}
+	entries, err := os.ReadDir(versionDir)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read version directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		if entry.IsDir() {
+			return true, nil
+		}
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(versionDir, entry.Name()))
+		if err != nil {
+			return false, err
+		}
+		var version storedVersion
+		if err := json.Unmarshal(data, &version); err != nil {
+			if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+				return true, nil
+			}
+		} else if version.Type == "" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// migrateVersionStore moves the versions of every provider from plain copies
+// into the object store, including the versions of providers that were
+// removed without purging them. Each provider's version directory is converted
+// next to the original and swapped in with renames, so an interrupted
+// migration is resumed or redone by the next invocation. The caller holds the
+// lock.
+func migrateVersionStore(config *ProvidersConfig) error {
+	for _, name := range sortedProviderNames(config) {
+		if err := migrateProviderVersions(config.Providers[name]); err != nil {
+			return fmt.Errorf("failed to migrate versions of '%s': %w", name, err)
+		}
+	}
+
+	configDir, err := getConfigDir()
+	if err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(filepath.Join(configDir, "providers"))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read providers directory: %w", err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if _, exists := config.Providers[name]; exists || !entry.IsDir() || checkPathComponent("provider", name) != nil {
+			continue
+		}
+		if err := migrateProviderVersions(Provider{Name: name}); err != nil {
+			return fmt.Errorf("failed to migrate versions of removed provider '%s': %w", name, err)
+		}
+	}
+	return nil
+}
+
+// migrateProviderVersions converts the plain copies of one provider's versions
+// into manifests. Without a type, as for a removed provider, each version's
+// type is taken from the copy itself.
+func migrateProviderVersions(provider Provider) error {
+	versionDir, err := getVersionDir(provider.Name)
+	if err != nil {
+		return err
+	}
+	convertedDir := versionDir + ".converted"
+	legacyDir := versionDir + ".legacy"
+
+	// Finish a swap that was interrupted after the old directory was moved aside
+	if _, err := os.Lstat(legacyDir); err == nil {
+		if _, err := os.Lstat(versionDir); os.IsNotExist(err) {
+			if err := os.Rename(convertedDir, versionDir); err != nil {
+				return fmt.Errorf("failed to swap in converted versions: %w", err)
+			}
+		}
+		return removeAll(legacyDir)
+	}
+
+	if _, err := os.Lstat(filepath.Join(versionDir, storeMarker)); err == nil {
+		return nil
+	}
+	entries, err := os.ReadDir(versionDir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read version directory: %w", err)
+	}
+
+	if err := removeAll(convertedDir); err != nil {
+		return fmt.Errorf("failed to remove partial conversion: %w", err)
+	}
+	if err := os.MkdirAll(convertedDir, privateDirMode); err != nil {
+		return fmt.Errorf("failed to create version directory: %w", err)
+	}
+
+	// Versions were copied with the provider's symlink policy already applied,
+	// so links found in them now are kept as links
+	for _, entry := range entries {
+		if !entry.IsDir() && !entry.Type().IsRegular() {
+			continue
+		}
+		pathType := provider.Type
+		if pathType == "" {
+			pathType = "file"
+			if entry.IsDir() {
+				pathType = "directory"
+			}
+		}
+		version, err := ingestTree(filepath.Join(versionDir, entry.Name()), pathType, SymlinkCopy)
+		if err != nil {
+			return fmt.Errorf("failed to store version '%s': %w", entry.Name(), err)
+		}
+		if err := writeVersionManifest(filepath.Join(convertedDir, entry.Name()), version); err != nil {
+			return err
+		}
+	}
+	if err := os.WriteFile(filepath.Join(convertedDir, storeMarker), nil, privateFileMode); err != nil {
+		return fmt.Errorf("failed to mark converted versions: %w", err)
+	}
+
+	if err := os.Rename(versionDir, legacyDir); err != nil {
+		return fmt.Errorf("failed to move old versions aside: %w", err)
+	}
+	if err := os.Rename(convertedDir, versionDir); err != nil {
+		return fmt.Errorf("failed to swap in converted versions: %w", err)
+	}
+	return removeAll(legacyDir)
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// setupStoreTest creates a directory provider under a temporary HOME
+func setupStoreTest(t *testing.T) (string, Provider) {
+	tempDir := setupTestHome(t)
+	liveDir := filepath.Join(tempDir, "config")
+	if err := os.MkdirAll(filepath.Join(liveDir, "nested"), 0755); err != nil {
+		t.Fatalf("Failed to create test directory: %v", err)
+	}
+	files := map[string]string{"settings.json": "{}", "token": "secret", "nested/notes.txt": "notes"}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(liveDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+	if err := os.Chmod(filepath.Join(liveDir, "token"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("settings.json", filepath.Join(liveDir, "link.json")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+	old := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, name := range []string{"token", "nested"} {
+		if err := os.Chtimes(filepath.Join(liveDir, name), old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return tempDir, Provider{Name: "store-provider", OriginalPath: liveDir, Type: "directory"}
+}
+
+func TestVersionStoreRoundTrip(t *testing.T) {
+	tempDir, provider := setupStoreTest(t)
+
+	if err := saveVersion(provider, "v1"); err != nil {
+		t.Fatalf("saveVersion failed: %v", err)
+	}
+
+	restored := filepath.Join(tempDir, "restored")
+	if err := materializeVersion(provider.Name, "v1", restored); err != nil {
+		t.Fatalf("materializeVersion failed: %v", err)
+	}
+
+	// Contents, modes and link targets match, and so do modification times
+	result, err := comparePaths(provider.OriginalPath, restored, "directory", SymlinkCopy)
+	if err != nil {
+		t.Fatalf("comparePaths failed: %v", err)
+	}
+	if !result.Equal {
+		t.Errorf("Restored version differs from the original: %+v", result.Differences)
+	}
+	for _, name := range []string{"token", "nested"} {
+		want, _ := os.Stat(filepath.Join(provider.OriginalPath, name))
+		got, err := os.Stat(filepath.Join(restored, name))
+		if err != nil {
+			t.Fatalf("Failed to stat restored %s: %v", name, err)
+		}
+		if !got.ModTime().Equal(want.ModTime()) {
+			t.Errorf("Modification time of %s = %v, want %v", name, got.ModTime(), want.ModTime())
+		}
+	}
+
+	t.Run("refuses an existing destination", func(t *testing.T) {
+		if err := materializeVersion(provider.Name, "v1", restored); err == nil {
+			t.Error("Expected error when the destination exists")
+		}
+	})
+
+	t.Run("detects corrupt objects", func(t *testing.T) {
+		tree, err := versionTree(provider.Name, "v1")
+		if err != nil {
+			t.Fatalf("versionTree failed: %v", err)
+		}
+		objectPath, _ := getObjectPath(tree["token"].Digest)
+		if err := os.WriteFile(objectPath, []byte("tampered"), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		dst := filepath.Join(tempDir, "corrupt")
+		if err := materializeVersion(provider.Name, "v1", dst); err == nil {
+			t.Error("Expected error for a corrupt object")
+		}
+		if _, err := os.Lstat(dst); !os.IsNotExist(err) {
+			t.Error("Expected partial restore to be removed")
+		}
+	})
+}
+
+func TestVersionStoreDeduplicates(t *testing.T) {
+	_, provider := setupStoreTest(t)
+
+	if err := saveVersion(provider, "v1"); err != nil {
+		t.Fatalf("saveVersion failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(provider.OriginalPath, "token"), []byte("rotated"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveVersion(provider, "v2"); err != nil {
+		t.Fatalf("saveVersion failed: %v", err)
+	}
+
+	countObjects := func() int {
+		objectsDir, _ := getObjectsDir()
+		count := 0
+		filepath.Walk(objectsDir, func(path string, info os.FileInfo, err error) error {
+			if err == nil && info.Mode().IsRegular() {
+				count++
+			}
+			return nil
+		})
+		return count
+	}
+
+	// Three files in v1, and only the changed token is new in v2
+	if got := countObjects(); got != 4 {
+		t.Errorf("Stored %d objects, want 4", got)
+	}
+
+	versionPath, _ := getVersionPath(provider.Name, "v1")
+	if err := os.Remove(versionPath); err != nil {
+		t.Fatal(err)
+	}
+
+	count, freed, err := sweepObjects(nil, true)
+	if err != nil {
+		t.Fatalf("sweepObjects failed: %v", err)
+	}
+	if count != 1 || freed != int64(len("secret")) {
+		t.Errorf("Dry run would free %d objects (%d bytes), want 1 (%d bytes)", count, freed, len("secret"))
+	}
+	if got := countObjects(); got != 4 {
+		t.Errorf("Dry run deleted objects: %d left, want 4", got)
+	}
+
+	if _, _, err := sweepObjects(nil, false); err != nil {
+		t.Fatalf("sweepObjects failed: %v", err)
+	}
+	if got := countObjects(); got != 3 {
+		t.Errorf("%d objects left after sweep, want 3", got)
+	}
+	assertDirRestores(t, provider, "v2")
+}
+
+func TestMigrateVersionStore(t *testing.T) {
+	_, provider := setupStoreTest(t)
+
+	// A plain copy of the live directory, as stored before the object store
+	versionPath, err := getVersionPath(provider.Name, "legacy")
+	if err != nil {
+		t.Fatalf("getVersionPath failed: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(versionPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := copyPath(provider.OriginalPath, versionPath, "directory", SymlinkCopy); err != nil {
+		t.Fatalf("copyPath failed: %v", err)
+	}
+
+	providersFile, _ := getProvidersFilePath()
+	legacy := `{"schema_version": 1, "providers": {"store-provider": {"name": "store-provider", "original_path": "` +
+		provider.OriginalPath + `", "type": "directory", "current_version": "legacy"}}}`
+	if err := os.WriteFile(providersFile, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Versions of a provider that was removed without --purge
+	removedPath, _ := getVersionPath("removed-provider", "legacy")
+	if err := os.MkdirAll(filepath.Dir(removedPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := copyPath(provider.OriginalPath, removedPath, "directory", SymlinkCopy); err != nil {
+		t.Fatalf("copyPath failed: %v", err)
+	}
+
+	// An earlier attempt that was interrupted while converting
+	versionDir, _ := getVersionDir(provider.Name)
+	if err := os.MkdirAll(versionDir+".converted", 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := upgradeProvidersFile(); err != nil {
+		t.Fatalf("upgradeProvidersFile failed: %v", err)
+	}
+
+	for _, leftover := range []string{versionDir + ".converted", versionDir + ".legacy"} {
+		if _, err := os.Lstat(leftover); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed", leftover)
+		}
+	}
+	versions, err := getAvailableVersions(provider.Name)
+	if err != nil || len(versions) != 1 || versions[0] != "legacy" {
+		t.Fatalf("Versions after migration = %v (%v), want [legacy]", versions, err)
+	}
+	assertDirRestores(t, provider, "legacy")
+	assertDirRestores(t, Provider{Name: "removed-provider", OriginalPath: provider.OriginalPath}, "legacy")
+
+	// Running the migration again must not treat manifests as plain copies
+	config, err := loadProviders()
+	if err != nil {
+		t.Fatalf("loadProviders failed: %v", err)
+	}
+	if err := migrateVersionStore(config); err != nil {
+		t.Fatalf("migrateVersionStore failed: %v", err)
+	}
+	assertDirRestores(t, provider, "legacy")
+}
+
+func TestLegacyVersionsOfRemovedProvider(t *testing.T) {
+	_, provider := setupStoreTest(t)
+	if err := saveVersion(provider, "v1"); err != nil {
+		t.Fatalf("saveVersion failed: %v", err)
+	}
+
+	// A provider removed without --purge before the object store existed
+	legacyPath, err := getVersionPath("removed-provider", "old")
+	if err != nil {
+		t.Fatalf("getVersionPath failed: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(legacyPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(legacyPath, []byte("token: plain"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if legacy, err := isLegacyVersionDir("removed-provider"); err != nil || !legacy {
+		t.Fatalf("isLegacyVersionDir = %v (%v), want true", legacy, err)
+	}
+	if legacy, err := isLegacyVersionDir(provider.Name); err != nil || legacy {
+		t.Fatalf("isLegacyVersionDir(%s) = %v (%v), want false", provider.Name, legacy, err)
+	}
+
+	// gc passes the plain copies over and keeps the objects in use
+	if _, _, err := sweepObjects(nil, false); err != nil {
+		t.Fatalf("sweepObjects failed: %v", err)
+	}
+	assertDirRestores(t, provider, "v1")
+
+	// doctor converts them
+	config := &ProvidersConfig{Providers: map[string]Provider{provider.Name: provider}}
+	findings, err := checkStore(config)
+	if err != nil {
+		t.Fatalf("checkStore failed: %v", err)
+	}
+	if len(findings) != 1 || findings[0].Provider != "removed-provider" || findings[0].fix == nil {
+		t.Fatalf("Expected one fixable finding for the removed provider, got %v", findings)
+	}
+	if err := findings[0].fix(config); err != nil {
+		t.Fatalf("fix failed: %v", err)
+	}
+	version, err := loadVersion("removed-provider", "old")
+	if err != nil || version.Type != "file" {
+		t.Fatalf("loadVersion after conversion = %v (%v), want a file version", version, err)
+	}
+	content, err := readObject(version.tree()[""].Digest)
+	if err != nil || string(content) != "token: plain" {
+		t.Errorf("Converted content = %q (%v), want %q", content, err, "token: plain")
+	}
+}
+
+// assertDirRestores checks that a stored version restores to the provider's live directory
+func assertDirRestores(t *testing.T, provider Provider, versionName string) {
+	t.Helper()
+	dst := filepath.Join(t.TempDir(), "restored")
+	if err := materializeVersion(provider.Name, versionName, dst); err != nil {
+		t.Fatalf("materializeVersion failed: %v", err)
+	}
+	result, err := comparePaths(provider.OriginalPath, dst, "directory", SymlinkCopy)
+	if err != nil {
+		t.Fatalf("comparePaths failed: %v", err)
+	}
+	if !result.Equal {
+		t.Errorf("Version %s does not restore the live directory: %+v", versionName, result.Differences)
+	}
+}
@@ -0,0 +1,502 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Phases of a version switch as recorded in its journal
+const (
+	phaseStaging  = "staging"  // the target version is being copied next to the live path
+	phaseSwapping = "swapping" // the live path is being renamed aside and replaced by the staged copy
+	phaseSwapped  = "swapped"  // the staged copy is live; providers.json may not be updated yet
+
+	// phaseRollingBack means the switch is being undone; recovery only ever
+	// finishes undoing it, as the displaced content may be the only copy left
+	phaseRollingBack = "rolling-back"
+)
+
+// switchJournal records an in-progress set-version on disk so that a switch
+// interrupted by a crash or kill can be completed or rolled back later
+type switchJournal struct {
+	Provider      string    `json:"provider"`
+	FromVersion   string    `json:"from_version"`
+	ToVersion     string    `json:"to_version"`
+	OriginalPath  string    `json:"original_path"`
+	StagingPath   string    `json:"staging_path"`
+	DisplacedPath string    `json:"displaced_path"`
+	Phase         string    `json:"phase"`
+	StartedAt     time.Time `json:"started_at"`
+
+	// Transaction is set when the switch is part of a switchTransaction
+	Transaction string `json:"transaction,omitempty"`
+}
+
+// switchTransaction groups the switches of several providers, which are then
+// completed or rolled back together. Its journal is written as committed once
+// every member has been swapped in; until then recovery rolls back all of them.
+type switchTransaction struct {
+	ID        string    `json:"id"`
+	Committed bool      `json:"committed"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// transactionSuffix names transaction journals in the journal directory
+const transactionSuffix = ".txn"
+
+// getJournalDir returns the directory holding journals of in-progress switches
+func getJournalDir() (string, error) {
+	configDir, err := getConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "journal"), nil
+}
+
+// getJournalPath returns the journal file used while switching a provider
+func getJournalPath(providerName string) (string, error) {
+	if err := checkPathComponent("provider", providerName); err != nil {
+		return "", err
+	}
+	journalDir, err := getJournalDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(journalDir, providerName+".json"), nil
+}
+
+// getTransactionPath returns the journal file of a switch transaction
+func getTransactionPath(id string) (string, error) {
+	if err := checkPathComponent("transaction", id); err != nil {
+		return "", err
+	}
+	journalDir, err := getJournalDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(journalDir, id+transactionSuffix), nil
+}
+
+// stagingPathFor returns where a new version is staged before being swapped in
+func stagingPathFor(originalPath string) string {
+	return originalPath + ".llmctx-staging"
+}
+
+// displacedPathFor returns where the live content is kept while it is replaced
+func displacedPathFor(originalPath string) string {
+	return originalPath + ".llmctx-displaced"
+}
+
+// write persists the journal, replacing any previous state for the provider
+func (j *switchJournal) write(phase string) error {
+	j.Phase = phase
+
+	journalPath, err := getJournalPath(j.Provider)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(journalPath), privateDirMode); err != nil {
+		return fmt.Errorf("failed to create journal directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal journal: %w", err)
+	}
+
+	if err := writeFileAtomic(journalPath, data, privateFileMode); err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	return nil
+}
+
+// remove deletes the journal once the switch has been completed or undone
+func (j *switchJournal) remove() error {
+	journalPath, err := getJournalPath(j.Provider)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(journalPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove journal: %w", err)
+	}
+	return nil
+}
+
+// write persists the transaction journal
+func (t *switchTransaction) write() error {
+	transactionPath, err := getTransactionPath(t.ID)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(transactionPath), privateDirMode); err != nil {
+		return fmt.Errorf("failed to create journal directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal journal: %w", err)
+	}
+
+	if err := writeFileAtomic(transactionPath, data, privateFileMode); err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	return nil
+}
+
+// remove deletes the transaction journal. Once it is gone, any member switch
+// left behind is rolled back by recovery.
+func (t *switchTransaction) remove() error {
+	transactionPath, err := getTransactionPath(t.ID)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(transactionPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove journal: %w", err)
+	}
+	return nil
+}
+
+// transactionCommitted reports whether a transaction reached its commit point
+func transactionCommitted(id string) (bool, error) {
+	transactionPath, err := getTransactionPath(id)
+	if err != nil {
+		return false, err
+	}
+	data, err := os.ReadFile(transactionPath)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read journal: %w", err)
+	}
+	var transaction switchTransaction
+	if err := json.Unmarshal(data, &transaction); err != nil {
+		return false, fmt.Errorf("failed to parse journal '%s': %w", transactionPath, err)
+	}
+	return transaction.Committed, nil
+}
+
+// switchVersion replaces the live content of a provider with a stored version.
+// The version is first staged next to the live path, then swapped in with
+// renames; the displaced live content is only deleted once the swap and the
+// update of providers.json have both succeeded.
+func switchVersion(config *ProvidersConfig, provider Provider, versionName string) error {
+	if err := checkProviderIdle(provider.Name); err != nil {
+		return err
+	}
+
+	journal, err := swapInVersion(provider, versionName, "")
+	if err != nil {
+		return err
+	}
+
+	// Update current version in config
+	provider.CurrentVersion = versionName
+	config.Providers[provider.Name] = provider
+
+	if err := config.saveProviders(); err != nil {
+		rollbackSwitch(journal)
+		return fmt.Errorf("failed to save providers config: %w", err)
+	}
+
+	return finishSwitch(journal)
+}
+
+// swapInVersion stages a stored version next to the live path and swaps it in.
+// On success the displaced live content and the journal are left for the
+// caller to finish or roll back; on failure everything is already undone.
+func swapInVersion(provider Provider, versionName, transaction string) (*switchJournal, error) {
+	return swapIn(provider, versionName, transaction, func(stagingPath string) error {
+		return stageVersion(provider, versionName, stagingPath)
+	})
+}
+
+// swapIn does the work of swapInVersion, with stage writing the content that
+// replaces the live path of the provider at the staging path
+func swapIn(provider Provider, versionName, transaction string, stage func(stagingPath string) error) (*switchJournal, error) {
+	journal := &switchJournal{
+		Provider:      provider.Name,
+		FromVersion:   provider.CurrentVersion,
+		ToVersion:     versionName,
+		OriginalPath:  provider.OriginalPath,
+		StagingPath:   stagingPathFor(provider.OriginalPath),
+		DisplacedPath: displacedPathFor(provider.OriginalPath),
+		StartedAt:     time.Now().UTC(),
+		Transaction:   transaction,
+	}
+
+	// A displaced copy without a journal may be the only copy of someone's data
+	if _, err := os.Lstat(journal.DisplacedPath); err == nil {
+		return nil, fmt.Errorf("'%s' already exists; move it away before switching versions", journal.DisplacedPath)
+	}
+	// A staging copy is always ours and safe to discard
+	if err := discardStaged(journal.StagingPath); err != nil {
+		return nil, fmt.Errorf("failed to remove stale staging copy: %w", err)
+	}
+
+	// Ensure parent directories exist
+	if err := os.MkdirAll(filepath.Dir(provider.OriginalPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create parent directories: %w", err)
+	}
+
+	if err := journal.write(phaseStaging); err != nil {
+		return nil, err
+	}
+
+	// Stage the version next to the live path so the swap is a same-filesystem rename
+	if err := stage(journal.StagingPath); err != nil {
+		journal.remove()
+		return nil, fmt.Errorf("failed to stage version: %w", err)
+	}
+
+	if err := journal.write(phaseSwapping); err != nil {
+		discardStaged(journal.StagingPath)
+		journal.remove()
+		return nil, err
+	}
+
+	if err := os.Rename(provider.OriginalPath, journal.DisplacedPath); err != nil && !os.IsNotExist(err) {
+		discardStaged(journal.StagingPath)
+		journal.remove()
+		return nil, fmt.Errorf("failed to move current content aside: %w", err)
+	}
+
+	if err := os.Rename(journal.StagingPath, provider.OriginalPath); err != nil {
+		rollbackSwitch(journal)
+		return nil, fmt.Errorf("failed to swap in version: %w", err)
+	}
+
+	if err := journal.write(phaseSwapped); err != nil {
+		rollbackSwitch(journal)
+		return nil, err
+	}
+	return journal, nil
+}
+
+// switchVersions switches several providers (provider name -> version) as one
+// transaction: either all of them end up at their new version, or all of them
+// keep their current content, even if llmctx is interrupted part way.
+func switchVersions(config *ProvidersConfig, targets map[string]string) error {
+	for name := range targets {
+		if err := checkProviderIdle(name); err != nil {
+			return err
+		}
+	}
+
+	transaction := &switchTransaction{
+		ID:        fmt.Sprintf("txn-%d", time.Now().UnixNano()),
+		StartedAt: time.Now().UTC(),
+	}
+	if err := transaction.write(); err != nil {
+		return err
+	}
+
+	var journals []*switchJournal
+	rollback := func() error {
+		// Without the transaction journal recovery rolls back any member left behind
+		errs := []error{transaction.remove()}
+		for i := len(journals) - 1; i >= 0; i-- {
+			errs = append(errs, rollbackSwitch(journals[i]))
+		}
+		return errors.Join(errs...)
+	}
+
+	names := slices.Sorted(maps.Keys(targets))
+	for _, name := range names {
+		journal, err := swapInVersion(config.Providers[name], targets[name], transaction.ID)
+		if err != nil {
+			return errors.Join(fmt.Errorf("failed to switch '%s': %w", name, err), rollback())
+		}
+		journals = append(journals, journal)
+	}
+
+	transaction.Committed = true
+	if err := transaction.write(); err != nil {
+		return errors.Join(err, rollback())
+	}
+
+	previous := make(map[string]string, len(names))
+	for _, name := range names {
+		provider := config.Providers[name]
+		previous[name] = provider.CurrentVersion
+		provider.CurrentVersion = targets[name]
+		config.Providers[name] = provider
+	}
+	if err := config.saveProviders(); err != nil {
+		for name, version := range previous {
+			provider := config.Providers[name]
+			provider.CurrentVersion = version
+			config.Providers[name] = provider
+		}
+		return errors.Join(fmt.Errorf("failed to save providers config: %w", err), rollback())
+	}
+
+	// The transaction journal must outlive its members, or recovery would
+	// roll back the ones not finished yet
+	for _, journal := range journals {
+		if err := finishSwitch(journal); err != nil {
+			return err
+		}
+	}
+	return transaction.remove()
+}
+
+// finishSwitch discards the displaced content of a completed switch
+func finishSwitch(journal *switchJournal) error {
+	if err := discardStaged(journal.DisplacedPath); err != nil {
+		return fmt.Errorf("failed to remove previous content at '%s': %w", journal.DisplacedPath, err)
+	}
+	return journal.remove()
+}
+
+// rollbackSwitch puts the displaced live content back in place and discards
+// anything staged or swapped in by the switch. The journal is marked first, so
+// an interrupted rollback is finished by recovery rather than rolled forward.
+func rollbackSwitch(journal *switchJournal) error {
+	if err := journal.write(phaseRollingBack); err != nil {
+		return err
+	}
+	if _, err := os.Lstat(journal.DisplacedPath); err == nil {
+		if err := discardStaged(journal.OriginalPath); err != nil {
+			return fmt.Errorf("failed to remove partially switched content: %w", err)
+		}
+		if err := os.Rename(journal.DisplacedPath, journal.OriginalPath); err != nil {
+			return fmt.Errorf("failed to restore previous content from '%s': %w", journal.DisplacedPath, err)
+		}
+	}
+	if err := discardStaged(journal.StagingPath); err != nil {
+		return fmt.Errorf("failed to remove staging copy: %w", err)
+	}
+	return journal.remove()
+}
+
+// recoverInterruptedSwitches completes or rolls back switches whose journal was
+// left behind by a previous invocation that did not finish
+func recoverInterruptedSwitches() error {
+	journalDir, err := getJournalDir()
+	if err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(journalDir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read journal directory: %w", err)
+	}
+
+	var names, transactions, leases []string
+	for _, entry := range entries {
+		switch {
+		case entry.IsDir():
+		case strings.HasSuffix(entry.Name(), ".json"):
+			names = append(names, entry.Name())
+		case strings.HasSuffix(entry.Name(), transactionSuffix):
+			transactions = append(transactions, entry.Name())
+		case strings.HasSuffix(entry.Name(), leaseSuffix):
+			leases = append(leases, entry.Name())
+		}
+	}
+	if len(names) == 0 && len(transactions) == 0 && len(leases) == 0 {
+		return nil
+	}
+	sort.Strings(names)
+
+	unlock, err := lockProviders()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	for _, name := range names {
+		// Another process may have recovered the journal while we waited for the lock
+		data, err := os.ReadFile(filepath.Join(journalDir, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read journal: %w", err)
+		}
+
+		var journal switchJournal
+		if err := json.Unmarshal(data, &journal); err != nil {
+			return fmt.Errorf("failed to parse journal '%s': %w", name, err)
+		}
+
+		if err := recoverSwitch(&journal); err != nil {
+			return fmt.Errorf("failed to recover interrupted switch of '%s': %w", journal.Provider, err)
+		}
+	}
+
+	// Every member has been completed or rolled back
+	for _, name := range transactions {
+		if err := os.Remove(filepath.Join(journalDir, name)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove journal: %w", err)
+		}
+	}
+
+	// Put back providers switched by an exec that died; leases of running ones stay
+	for _, name := range leases {
+		if err := recoverLease(filepath.Join(journalDir, name)); err != nil {
+			return fmt.Errorf("failed to recover interrupted exec: %w", err)
+		}
+	}
+	return nil
+}
+
+// recoverSwitch completes a switch that got as far as swapping in the new
+// content and rolls back any switch that did not
+func recoverSwitch(journal *switchJournal) error {
+	_, stagingErr := os.Lstat(journal.StagingPath)
+	staged := stagingErr == nil
+
+	// The second rename happened if the staged copy is gone during a swap
+	rollForward := journal.Phase == phaseSwapped || (journal.Phase == phaseSwapping && !staged)
+
+	// Members of a transaction are completed only if all of them were swapped in
+	if journal.Transaction != "" && journal.Phase != phaseRollingBack {
+		committed, err := transactionCommitted(journal.Transaction)
+		if err != nil {
+			return err
+		}
+		rollForward = committed
+	}
+
+	if rollForward {
+		config, err := loadProviders()
+		if err != nil {
+			return err
+		}
+		if provider, exists := config.Providers[journal.Provider]; exists && provider.CurrentVersion != journal.ToVersion {
+			provider.CurrentVersion = journal.ToVersion
+			config.Providers[journal.Provider] = provider
+			if err := config.saveProviders(); err != nil {
+				return fmt.Errorf("failed to save providers config: %w", err)
+			}
+		}
+		recordHistory(historyEvent{
+			Time:            journal.StartedAt,
+			Action:          historySetVersion,
+			Provider:        journal.Provider,
+			Version:         journal.ToVersion,
+			PreviousVersion: journal.FromVersion,
+		})
+		fmt.Fprintf(os.Stderr, "Recovered interrupted switch: '%s' is now at version '%s'\n", journal.Provider, journal.ToVersion)
+		return finishSwitch(journal)
+	}
+
+	if err := rollbackSwitch(journal); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Rolled back interrupted switch: '%s' remains at version '%s'\n", journal.Provider, journal.FromVersion)
+	return nil
+}
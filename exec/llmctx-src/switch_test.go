@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// setupTestHome points HOME at a temporary directory for the rest of the test
+// and returns it
+func setupTestHome(t *testing.T) string {
+	t.Helper()
+	home := t.TempDir()
+	// Copies of read-only directories must be made writable to be removed
+	t.Cleanup(func() { removeAll(home) })
+	t.Setenv("HOME", home)
+	return home
+}
+
+// setupSwitchTest creates a file provider with two stored versions under a temporary HOME
+func setupSwitchTest(t *testing.T) (string, *ProvidersConfig, Provider) {
+	tempDir := setupTestHome(t)
+	livePath := filepath.Join(tempDir, "config.yaml")
+	provider := Provider{
+		Name:           "test-provider",
+		OriginalPath:   livePath,
+		Type:           "file",
+		CurrentVersion: "work",
+	}
+
+	// Store each version from the live file, ending with the active one
+	for _, version := range []string{"personal", "work"} {
+		if err := os.WriteFile(livePath, []byte(version), 0644); err != nil {
+			t.Fatalf("Failed to create live file: %v", err)
+		}
+		if err := saveVersion(provider, version); err != nil {
+			t.Fatalf("Failed to store version: %v", err)
+		}
+	}
+
+	config := &ProvidersConfig{Providers: map[string]Provider{provider.Name: provider}}
+	if err := config.saveProviders(); err != nil {
+		t.Fatalf("Failed to save providers: %v", err)
+	}
+	return tempDir, config, provider
+}
+
+// assertVersionContent checks the content stored for a version of a file provider
+func assertVersionContent(t *testing.T, providerName, versionName, want string) {
+	t.Helper()
+	tree, err := versionTree(providerName, versionName)
+	if err != nil {
+		t.Fatalf("Failed to load version %s: %v", versionName, err)
+	}
+	got, err := readObject(tree[""].Digest)
+	if err != nil {
+		t.Fatalf("Failed to read version %s: %v", versionName, err)
+	}
+	if string(got) != want {
+		t.Errorf("Content of version %s = %q, want %q", versionName, string(got), want)
+	}
+}
+
+func assertFileContent(t *testing.T, path, want string) {
+	t.Helper()
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	if string(got) != want {
+		t.Errorf("Content of %s = %q, want %q", path, string(got), want)
+	}
+}
+
+func TestSwitchVersion(t *testing.T) {
+	_, config, provider := setupSwitchTest(t)
+
+	if err := switchVersion(config, provider, "personal"); err != nil {
+		t.Fatalf("switchVersion failed: %v", err)
+	}
+
+	assertFileContent(t, provider.OriginalPath, "personal")
+	for _, leftover := range []string{stagingPathFor(provider.OriginalPath), displacedPathFor(provider.OriginalPath)} {
+		if _, err := os.Lstat(leftover); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be cleaned up", leftover)
+		}
+	}
+
+	journalPath, _ := getJournalPath(provider.Name)
+	if _, err := os.Stat(journalPath); !os.IsNotExist(err) {
+		t.Error("Expected journal to be removed after a successful switch")
+	}
+
+	loaded, err := loadProviders()
+	if err != nil {
+		t.Fatalf("loadProviders failed: %v", err)
+	}
+	if loaded.Providers[provider.Name].CurrentVersion != "personal" {
+		t.Errorf("CurrentVersion = %q, want %q", loaded.Providers[provider.Name].CurrentVersion, "personal")
+	}
+}
+
+func TestSwitchVersionRollsBackWhenSaveFails(t *testing.T) {
+	tempDir, config, provider := setupSwitchTest(t)
+
+	// Make providers.json unwritable by replacing it with a directory
+	providersFile := filepath.Join(tempDir, ".llmctx", "providers.json")
+	if err := os.Remove(providersFile); err != nil {
+		t.Fatalf("Failed to remove providers file: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(providersFile, "blocker"), 0755); err != nil {
+		t.Fatalf("Failed to create blocker: %v", err)
+	}
+
+	if err := switchVersion(config, provider, "personal"); err == nil {
+		t.Fatal("Expected switchVersion to fail when providers.json cannot be written")
+	}
+
+	assertFileContent(t, provider.OriginalPath, "work")
+	if _, err := os.Lstat(displacedPathFor(provider.OriginalPath)); !os.IsNotExist(err) {
+		t.Error("Expected displaced content to be moved back")
+	}
+}
+
+func TestRecoverInterruptedSwitches(t *testing.T) {
+	t.Run("rolls back a switch interrupted mid-swap", func(t *testing.T) {
+		_, _, provider := setupSwitchTest(t)
+
+		journal := &switchJournal{
+			Provider:      provider.Name,
+			FromVersion:   "work",
+			ToVersion:     "personal",
+			OriginalPath:  provider.OriginalPath,
+			StagingPath:   stagingPathFor(provider.OriginalPath),
+			DisplacedPath: displacedPathFor(provider.OriginalPath),
+		}
+		if err := os.WriteFile(journal.StagingPath, []byte("personal"), 0644); err != nil {
+			t.Fatalf("Failed to create staging copy: %v", err)
+		}
+		if err := os.Rename(provider.OriginalPath, journal.DisplacedPath); err != nil {
+			t.Fatalf("Failed to displace live file: %v", err)
+		}
+		if err := journal.write(phaseSwapping); err != nil {
+			t.Fatalf("Failed to write journal: %v", err)
+		}
+
+		if err := recoverInterruptedSwitches(); err != nil {
+			t.Fatalf("recoverInterruptedSwitches failed: %v", err)
+		}
+
+		assertFileContent(t, provider.OriginalPath, "work")
+		if _, err := os.Lstat(journal.StagingPath); !os.IsNotExist(err) {
+			t.Error("Expected staging copy to be removed")
+		}
+	})
+
+	t.Run("completes a switch interrupted before saving", func(t *testing.T) {
+		_, _, provider := setupSwitchTest(t)
+
+		journal := &switchJournal{
+			Provider:      provider.Name,
+			FromVersion:   "work",
+			ToVersion:     "personal",
+			OriginalPath:  provider.OriginalPath,
+			StagingPath:   stagingPathFor(provider.OriginalPath),
+			DisplacedPath: displacedPathFor(provider.OriginalPath),
+		}
+		if err := os.Rename(provider.OriginalPath, journal.DisplacedPath); err != nil {
+			t.Fatalf("Failed to displace live file: %v", err)
+		}
+		if err := os.WriteFile(provider.OriginalPath, []byte("personal"), 0644); err != nil {
+			t.Fatalf("Failed to write swapped file: %v", err)
+		}
+		if err := journal.write(phaseSwapped); err != nil {
+			t.Fatalf("Failed to write journal: %v", err)
+		}
+
+		if err := recoverInterruptedSwitches(); err != nil {
+			t.Fatalf("recoverInterruptedSwitches failed: %v", err)
+		}
+
+		assertFileContent(t, provider.OriginalPath, "personal")
+		if _, err := os.Lstat(journal.DisplacedPath); !os.IsNotExist(err) {
+			t.Error("Expected displaced content to be removed")
+		}
+		loaded, err := loadProviders()
+		if err != nil {
+			t.Fatalf("loadProviders failed: %v", err)
+		}
+		if loaded.Providers[provider.Name].CurrentVersion != "personal" {
+			t.Errorf("CurrentVersion = %q, want %q", loaded.Providers[provider.Name].CurrentVersion, "personal")
+		}
+	})
+
+	for _, committed := range []bool{false, true} {
+		t.Run(fmt.Sprintf("finishes a rollback interrupted before restoring the displaced content (committed transaction: %v)", committed), func(t *testing.T) {
+			_, config, provider := setupSwitchTest(t)
+
+			// Even a committed transaction is not completed once a member is being undone
+			id := ""
+			if committed {
+				transaction := &switchTransaction{ID: "txn-1", Committed: true}
+				if err := transaction.write(); err != nil {
+					t.Fatalf("Failed to write transaction: %v", err)
+				}
+				id = transaction.ID
+			}
+			journal, err := swapInVersion(config.Providers[provider.Name], "personal", id)
+			if err != nil {
+				t.Fatalf("swapInVersion failed: %v", err)
+			}
+
+			// Crash inside rollbackSwitch, after the swapped-in content was discarded
+			if err := journal.write(phaseRollingBack); err != nil {
+				t.Fatalf("Failed to write journal: %v", err)
+			}
+			if err := discardStaged(journal.OriginalPath); err != nil {
+				t.Fatalf("Failed to discard swapped-in content: %v", err)
+			}
+
+			if err := recoverInterruptedSwitches(); err != nil {
+				t.Fatalf("recoverInterruptedSwitches failed: %v", err)
+			}
+
+			assertLive(t, map[string]string{provider.Name: "work"})
+			if _, err := os.Lstat(journal.DisplacedPath); !os.IsNotExist(err) {
+				t.Error("Expected displaced content to be moved back")
+			}
+		})
+	}
+}